@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -14,18 +16,56 @@ import (
 )
 
 func main() {
+	// 执行命令前的初始化进程复用本二进制（见 workspace/execinit_linux.go）
+	workspace.MaybeRunExecInit()
 	if err := run(); err != nil {
 		_, _ = os.Stderr.WriteString(fmt.Sprintf("Error: %v\n", err))
 		os.Exit(1)
 	}
 }
 
-// loadConfigAndLogger 从配置文件和环境变量加载配置，验证并创建日志器。
-func loadConfigAndLogger() (*config.Config, log.Logger, error) {
-	cfg, err := config.LoadConfig()
+// cliFlags 命令行参数（优先级高于配置文件与环境变量）
+type cliFlags struct {
+	configPath string
+	transport  string
+	httpAddr   string
+	insecure   bool
+}
+
+// parseFlags 解析命令行参数
+func parseFlags(args []string) (*cliFlags, error) {
+	f := &cliFlags{}
+	fs := flag.NewFlagSet("agentcode-mcp", flag.ContinueOnError)
+	fs.StringVar(&f.configPath, "config", "", "path to config file (default: search standard locations)")
+	fs.StringVar(&f.transport, "transport", "", "transport: stdio, http (streamable HTTP) or sse")
+	fs.StringVar(&f.httpAddr, "http-addr", "", "listen address for http/sse transport, e.g. 127.0.0.1:8765")
+	fs.BoolVar(&f.insecure, "http-allow-insecure", false, "allow listening on a non-loopback address without an auth token")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// apply 将命令行参数覆盖到配置（启动与热重载时都要应用）
+func (f *cliFlags) apply(cfg *config.Config) {
+	if f.transport != "" {
+		cfg.Transport = f.transport
+	}
+	if f.httpAddr != "" {
+		cfg.HTTP.Addr = f.httpAddr
+	}
+	if f.insecure {
+		cfg.HTTP.AllowInsecure = true
+	}
+}
+
+// loadConfigAndLogger 从配置文件和环境变量加载配置，应用命令行覆盖，验证并创建日志器。
+func loadConfigAndLogger(flags *cliFlags) (*config.Config, log.Logger, error) {
+	cfg, err := config.LoadConfigFrom(flags.configPath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	flags.apply(cfg)
 
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
@@ -38,7 +78,14 @@ func loadConfigAndLogger() (*config.Config, log.Logger, error) {
 
 // run 初始化并运行服务，处理中断信号。
 func run() error {
-	cfg, logger, err := loadConfigAndLogger()
+	flags, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cfg, logger, err := loadConfigAndLogger(flags)
 	if err != nil {
 		return err
 	}
@@ -50,8 +97,8 @@ func run() error {
 		logger.Info(context.Background(), "Using environment variables only")
 	}
 
-	// 创建本地工作区
-	ws, err := workspace.NewOSWorkspace(cfg)
+	// 创建本地工作区（支持多个命名工作区）
+	workspaces, err := workspace.NewRegistry(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create workspace: %w", err)
 	}
@@ -60,14 +107,50 @@ func run() error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// 创建 MCP 服务器
-	server, err := mcp.NewServer(ws, logger)
+	// 按传输方式创建 MCP 服务器
+	var server *mcp.Server
+	if cfg.Transport == "stdio" {
+		server, err = mcp.NewServer(workspaces, logger)
+	} else {
+		server, err = mcp.NewHTTPServer(workspaces, logger, mcp.HTTPOptions{
+			Mode:          cfg.Transport,
+			Addr:          cfg.HTTP.Addr,
+			AuthToken:     cfg.HTTP.AuthToken,
+			AllowInsecure: cfg.HTTP.AllowInsecure,
+		})
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create mcp server: %w", err)
 	}
+	if err := server.DisableTools(mcp.DisabledTools(cfg)); err != nil {
+		return fmt.Errorf("failed to apply disabled_tools: %w", err)
+	}
 
-	// 启动 stdio 循环（阻塞直到上下文取消）
-	if err := server.RunSTDIO(ctx); err != nil {
+	// 监视配置文件，修改后热更新策略（失败不影响服务运行）
+	if cfg.ConfigFile != "" {
+		reloader, err := config.NewReloader(cfg, cfg.ConfigFile)
+		if err != nil {
+			logger.Warn(ctx, "Config hot reload disabled", "error", err)
+		} else {
+			reloader.RegisterCallback(func(oldCfg, newCfg *config.Config) error {
+				flags.apply(newCfg)
+				return server.ApplyConfig(oldCfg, newCfg)
+			})
+			if err := reloader.Start(); err != nil {
+				logger.Warn(ctx, "Config hot reload disabled", "error", err)
+			} else {
+				defer reloader.Stop()
+			}
+		}
+	}
+
+	// 运行服务器（阻塞直到上下文取消）
+	if cfg.Transport == "stdio" {
+		err = server.RunSTDIO(ctx)
+	} else {
+		err = server.RunHTTP(ctx)
+	}
+	if err != nil {
 		return fmt.Errorf("mcp server error: %w", err)
 	}
 
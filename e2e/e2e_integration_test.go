@@ -2,13 +2,17 @@ package e2e
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestEndToEnd_MCPProcess 端到端测试：构建二进制并通过 MCP 协议交互
@@ -132,3 +136,180 @@ func TestEndToEnd_MCPProcess(t *testing.T) {
 	t.Log("✅ end-to-end test passed")
 }
 
+// TestEndToEnd_HTTPTransport 端到端测试：以 Streamable HTTP 模式启动并校验认证与工具调用
+func TestEndToEnd_HTTPTransport(t *testing.T) {
+	tmpDir := t.TempDir()
+	binName := "opencode-mcp-e2e-http"
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+	binPath := filepath.Join(tmpDir, binName)
+
+	buildCmd := exec.Command("go", "build", "-o", binPath, "./cmd/opencode-mcp")
+	buildCmd.Dir = ".."
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, string(out))
+	}
+
+	// 取一个空闲端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cmd := exec.Command(binPath, "--transport", "http", "--http-addr", addr)
+	cmd.Env = append(os.Environ(), "MCP_HTTP_AUTH_TOKEN=e2e-secret")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	url := "http://" + addr + "/mcp"
+	do := func(method, token, session string, req map[string]interface{}) (*http.Response, error) {
+		var body []byte
+		if req != nil {
+			body, _ = json.Marshal(req)
+		}
+		httpReq, _ := http.NewRequest(method, url, bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Accept", "application/json, text/event-stream")
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+		if session != "" {
+			httpReq.Header.Set("Mcp-Session-Id", session)
+		}
+		return http.DefaultClient.Do(httpReq)
+	}
+	post := func(token string, req map[string]interface{}) (*http.Response, error) {
+		return do(http.MethodPost, token, "", req)
+	}
+
+	initReq := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "initialize",
+		"params":  map[string]interface{}{},
+	}
+
+	// 等待服务就绪
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = post("", initReq); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("server not reachable: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	// 1. initialize
+	resp, err = post("e2e-secret", initReq)
+	if err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	var initResp struct {
+		ID     int                    `json:"id"`
+		Result map[string]interface{} `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&initResp)
+	resp.Body.Close()
+	if initResp.ID != 1 || initResp.Result["protocolVersion"] == nil {
+		t.Fatalf("unexpected initialize response: %+v", initResp)
+	}
+	session := resp.Header.Get("Mcp-Session-Id")
+	if session == "" {
+		t.Fatal("initialize response missing Mcp-Session-Id")
+	}
+
+	// 2. workspace.health（请求 ID 应原样返回）
+	healthReq := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      42,
+		"method":  "tools/call",
+		"params": map[string]interface{}{
+			"name":      "workspace.health",
+			"arguments": map[string]interface{}{},
+		},
+	}
+	resp, err = do(http.MethodPost, "e2e-secret", session, healthReq)
+	if err != nil {
+		t.Fatalf("workspace.health failed: %v", err)
+	}
+	var healthResp struct {
+		ID     int `json:"id"`
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&healthResp)
+	resp.Body.Close()
+	if healthResp.ID != 42 || len(healthResp.Result.Content) == 0 || !strings.Contains(healthResp.Result.Content[0].Text, `"version"`) {
+		t.Fatalf("unexpected health response: %+v", healthResp)
+	}
+
+	// 3. 会话校验：缺少会话 ID 返回 400，未登记的会话 ID（POST / GET / DELETE）返回 404
+	expectStatus := func(what string, want int, method, session string, req map[string]interface{}) {
+		t.Helper()
+		resp, err := do(method, "e2e-secret", session, req)
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", what, want, resp.StatusCode)
+		}
+	}
+	expectStatus("post without session", http.StatusBadRequest, http.MethodPost, "", healthReq)
+	expectStatus("post with unknown session", http.StatusNotFound, http.MethodPost, "client-chosen-id", healthReq)
+	expectStatus("get with unknown session", http.StatusNotFound, http.MethodGet, "client-chosen-id", nil)
+	expectStatus("delete unknown session", http.StatusNotFound, http.MethodDelete, "client-chosen-id", nil)
+
+	// 4. DELETE 结束会话，之后该会话 ID 不再可用
+	expectStatus("delete session", http.StatusOK, http.MethodDelete, session, nil)
+	expectStatus("post after delete", http.StatusNotFound, http.MethodPost, session, healthReq)
+}
+
+// TestEndToEnd_HTTPRefusesInsecureBind 非本机地址且未设置 Token 时拒绝启动
+func TestEndToEnd_HTTPRefusesInsecureBind(t *testing.T) {
+	binPath := filepath.Join(t.TempDir(), "opencode-mcp-e2e-insecure")
+	if runtime.GOOS == "windows" {
+		binPath += ".exe"
+	}
+	buildCmd := exec.Command("go", "build", "-o", binPath, "./cmd/opencode-mcp")
+	buildCmd.Dir = ".."
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, string(out))
+	}
+
+	cmd := exec.Command(binPath, "--transport", "http", "--http-addr", "0.0.0.0:0")
+	cmd.Env = append(os.Environ(), "MCP_HTTP_AUTH_TOKEN=")
+	done := make(chan error, 1)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected non-zero exit")
+		}
+		if !strings.Contains(stderr.String(), "without an auth token") {
+			t.Errorf("unexpected error output: %s", stderr.String())
+		}
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("server started on 0.0.0.0 without an auth token")
+	}
+}
@@ -5,6 +5,8 @@ go 1.22
 require (
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/metoro-io/mcp-golang v0.16.0
+	golang.org/x/sys v0.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -18,6 +20,4 @@ require (
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
-	golang.org/x/sys v0.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,42 +1,174 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ProviderConfig 定义单个 AI 提供商配置
 type ProviderConfig struct {
-	Name           string   `json:"name"`
-	APIKey         string   `json:"api_key"`
-	BaseURL        string   `json:"base_url"`
-	DefaultModel   string   `json:"default_model"`
-	FallbackModels []string `json:"fallback_models"`
+	Name           string   `json:"name" yaml:"name"`
+	APIKey         string   `json:"api_key" yaml:"api_key"`
+	BaseURL        string   `json:"base_url" yaml:"base_url"`
+	DefaultModel   string   `json:"default_model" yaml:"default_model"`
+	FallbackModels []string `json:"fallback_models" yaml:"fallback_models"`
 }
 
 // AIConfig AI 模型提供商配置
 type AIConfig struct {
-	Providers       map[string]ProviderConfig `json:"providers"` // 键: "openai", "anthropic", "deepseek", "openrouter" 等
-	DefaultProvider string                    `json:"default_provider"`
+	Providers       map[string]ProviderConfig `json:"providers" yaml:"providers"` // 键: "openai", "anthropic", "deepseek", "openrouter" 等
+	DefaultProvider string                    `json:"default_provider" yaml:"default_provider"`
 }
 
 // Config 完整配置结构（完全本地模式）
 type Config struct {
-	RootDir              string   `json:"root_dir"` // 工作区根目录（空则使用当前目录）
-	AI                   AIConfig `json:"ai"`
-	LogLevel             string   `json:"log_level"`
-	MaxSearchResults     int      `json:"max_search_results"`
-	MaxFileBytes         int64    `json:"max_file_bytes"`
-	BuildTimeout         int64    `json:"build_timeout_seconds"`  // 构建超时时间（秒）
-	AllowedBuildCommands []string `json:"allowed_build_commands"` // 允许的构建命令列表（白名单）
-	AllowedPaths         []string `json:"allowed_paths"`          // 允许操作的目录白名单（空表示不限制）
-	BlockedExtensions    []string `json:"blocked_extensions"`     // 拦截的文件扩展名黑名单
-	LowResourceMode      bool     `json:"low_resource_mode"`      // 低功耗模式（针对树莓派）
-	ConfigFile           string   `json:"-"`                      // 记住配置文件来源
+	RootDir              string            `json:"root_dir"` // 工作区根目录（空则使用当前目录）
+	AI                   AIConfig          `json:"ai"`
+	LogLevel             string            `json:"log_level"`
+	MaxSearchResults     int               `json:"max_search_results"`
+	MaxFileBytes         int64             `json:"max_file_bytes"`
+	BuildTimeout         int64             `json:"build_timeout_seconds"`  // 构建超时时间（秒）
+	AllowedBuildCommands []string          `json:"allowed_build_commands"` // 允许的构建命令列表（白名单）
+	CommandPolicies      []CommandPolicy   `json:"command_policies"`       // 按可执行文件的参数级策略（优先于 AllowedBuildCommands）
+	AllowedPaths         []string          `json:"allowed_paths"`          // 允许操作的目录白名单（空表示不限制）
+	BlockedExtensions    []string          `json:"blocked_extensions"`     // 拦截的文件扩展名黑名单
+	LowResourceMode      bool              `json:"low_resource_mode"`      // 低功耗模式（针对树莓派）
+	Transport            string            `json:"transport"`              // 传输方式："stdio"（默认）、"http"（Streamable HTTP）或 "sse"
+	HTTP                 HTTPConfig        `json:"http"`                   // HTTP/SSE 传输配置
+	Workspaces           []WorkspaceConfig `json:"workspaces"`             // 命名工作区（空则只有 RootDir 对应的默认工作区）
+	AllowClientRoots     bool              `json:"allow_client_roots"`     // 接受位于已配置工作区之外的 MCP 客户端 root（默认忽略）
+	DisabledTools        []string          `json:"disabled_tools"`         // 不对外暴露的工具名（如 "workspace.write_file"），支持热重载
+	MaxJobs              int               `json:"max_jobs"`               // 同时运行的后台任务上限
+	ResourceLimits       ResourceLimits    `json:"resource_limits"`        // 执行命令时的资源限制（见 ExecLimits）
+	Sandbox              SandboxConfig     `json:"sandbox"`                // 命令沙箱（见 SandboxFor）
+	Env                  EnvPolicy         `json:"env"`                    // 执行命令时的环境变量策略（见 EnvFor）
+	History              HistoryConfig     `json:"history"`                // 编辑日志（workspace.history / undo / redo，见 HistoryLimits）
+	Files                FilesConfig       `json:"files"`                  // 文件管理工具（make_dir / move / copy / delete）
+	ConfigFile           string            `json:"-"`                      // 记住配置文件来源
+}
+
+// WorkspaceConfig 命名工作区配置，列表字段为空时继承全局配置
+type WorkspaceConfig struct {
+	Name                 string          `json:"name" yaml:"name"`                                     // 工作区名称，工具通过 workspace 参数引用
+	RootDir              string          `json:"root_dir" yaml:"root_dir"`                             // 工作区根目录
+	AllowedPaths         []string        `json:"allowed_paths" yaml:"allowed_paths"`                   // 允许操作的目录白名单
+	BlockedExtensions    []string        `json:"blocked_extensions" yaml:"blocked_extensions"`         // 拦截的文件扩展名黑名单
+	AllowedBuildCommands []string        `json:"allowed_build_commands" yaml:"allowed_build_commands"` // 允许的构建命令列表
+	CommandPolicies      []CommandPolicy `json:"command_policies" yaml:"command_policies"`             // 参数级命令策略
+}
+
+// CommandPolicy 单个可执行文件的参数级策略
+// 参数模式支持 * 与 ? 通配；以 - 开头的模式同时匹配 -flag、--flag 与 -flag=value 形式
+type CommandPolicy struct {
+	Command     string   `json:"command" yaml:"command"`         // 可执行文件名，如 "go"
+	Subcommands []string `json:"subcommands" yaml:"subcommands"` // 允许的子命令（可多词，如 "mod tidy"），为空表示不限制
+	AllowArgs   []string `json:"allow_args" yaml:"allow_args"`   // 子命令之后的每个参数必须匹配其一，为空表示不限制
+	DenyArgs    []string `json:"deny_args" yaml:"deny_args"`     // 任一参数匹配即拒绝，如 "-exec"、"-toolexec"
+	MaxArgs     int      `json:"max_args" yaml:"max_args"`       // 参数总数上限（含子命令），0 表示不限制
+}
+
+// ResourceLimits 执行命令时对进程设置的 rlimit，0 表示不限制（仅 Linux 生效，子进程继承）
+type ResourceLimits struct {
+	CPUSeconds     uint64 `json:"cpu_seconds" yaml:"cpu_seconds"`           // RLIMIT_CPU，CPU 时间（秒）
+	AddressSpaceMB uint64 `json:"address_space_mb" yaml:"address_space_mb"` // RLIMIT_AS，虚拟地址空间（MB）
+	OpenFiles      uint64 `json:"open_files" yaml:"open_files"`             // RLIMIT_NOFILE，打开文件数
+	MaxProcesses   uint64 `json:"max_processes" yaml:"max_processes"`       // RLIMIT_NPROC，按用户统计的进程数
+	FileSizeMB     uint64 `json:"file_size_mb" yaml:"file_size_mb"`         // RLIMIT_FSIZE，单个输出文件大小（MB）
+}
+
+// lowResourceLimits LowResourceMode 下未配置的资源限制使用的默认值（针对 512MB 内存的树莓派）
+var lowResourceLimits = ResourceLimits{
+	CPUSeconds:     600,
+	AddressSpaceMB: 1024,
+	OpenFiles:      1024,
+	MaxProcesses:   256,
+	FileSizeMB:     256,
+}
+
+// HistoryConfig 编辑日志配置：写入类工具的每次修改都记录修改前后的快照，可撤销与重做
+type HistoryConfig struct {
+	Disabled   bool   `json:"disabled" yaml:"disabled"`       // 不记录编辑历史
+	Dir        string `json:"dir" yaml:"dir"`                 // 日志目录（须为工作区外的绝对路径），每个工作区一个子目录
+	MaxEntries int    `json:"max_entries" yaml:"max_entries"` // 每个工作区保留的编辑条数，0 表示使用默认值
+	MaxMB      int64  `json:"max_mb" yaml:"max_mb"`           // 每个工作区快照占用的空间上限（MB），0 表示使用默认值
+}
+
+// 编辑日志的默认保留限制；LowResourceMode 下使用更小的值
+const (
+	DefaultHistoryMaxEntries     = 200
+	DefaultHistoryMaxMB          = 128
+	lowResourceHistoryMaxEntries = 50
+	lowResourceHistoryMaxMB      = 16
+)
+
+// FilesConfig 文件管理工具配置
+type FilesConfig struct {
+	DisableDelete bool   `json:"disable_delete" yaml:"disable_delete"` // 禁止 workspace.delete、workspace.move，以及 copy 覆盖已有文件
+	TrashDir      string `json:"trash_dir" yaml:"trash_dir"`           // delete 的 trash 选项移入的目录（须为工作区外的绝对路径），每个工作区一个子目录
+}
+
+// SandboxConfig 命令沙箱配置（仅 Linux，基于非特权用户命名空间）
+// 沙箱内除工作区根目录与 WritablePaths 外整个文件系统只读，/tmp 为私有 tmpfs，能力集被清空
+type SandboxConfig struct {
+	Enabled       bool          `json:"enabled" yaml:"enabled"`               // 是否在沙箱中执行命令
+	AllowNetwork  bool          `json:"allow_network" yaml:"allow_network"`   // 是否允许访问网络（否则只有回环接口）
+	WritablePaths []string      `json:"writable_paths" yaml:"writable_paths"` // 工作区外额外可写的路径（如 ~/.cache/go-build），不存在的路径忽略
+	Commands      []SandboxRule `json:"commands" yaml:"commands"`             // 按可执行文件覆盖以上设置
+}
+
+// SandboxRule 单个可执行文件的沙箱设置，未设置的字段沿用 SandboxConfig 的值
+type SandboxRule struct {
+	Command       string   `json:"command" yaml:"command"`               // 可执行文件名，如 "go"
+	Enabled       *bool    `json:"enabled" yaml:"enabled"`               // 覆盖 SandboxConfig.Enabled
+	AllowNetwork  *bool    `json:"allow_network" yaml:"allow_network"`   // 覆盖 SandboxConfig.AllowNetwork（如 go mod download 需要网络）
+	WritablePaths []string `json:"writable_paths" yaml:"writable_paths"` // 追加到 SandboxConfig.WritablePaths
+}
+
+// EnvPolicy 执行命令时的环境变量策略，变量名模式支持 * 与 ? 通配
+// 命令的环境依次由 Passthrough 继承、Set 注入、调用方传入（secure_exec 的 env 参数）组成，最后移除匹配 Redact 的变量
+type EnvPolicy struct {
+	Passthrough    []string          `json:"passthrough" yaml:"passthrough"`           // 从服务进程环境继承的变量，为空时使用 DefaultEnvPassthrough
+	Set            map[string]string `json:"set" yaml:"set"`                           // 固定注入的变量，如 GOFLAGS、GOCACHE
+	Redact         []string          `json:"redact" yaml:"redact"`                     // 始终移除的变量，与 DefaultEnvRedact 合并
+	AllowCallerEnv []string          `json:"allow_caller_env" yaml:"allow_caller_env"` // 调用方允许传入的变量，为空表示不允许
+	Commands       []EnvRule         `json:"commands" yaml:"commands"`                 // 按可执行文件追加设置
+}
+
+// EnvRule 单个可执行文件的环境变量设置，在 EnvPolicy 基础上追加
+type EnvRule struct {
+	Command        string            `json:"command" yaml:"command"`                   // 可执行文件名，如 "go"
+	Passthrough    []string          `json:"passthrough" yaml:"passthrough"`           // 追加到 EnvPolicy.Passthrough
+	Set            map[string]string `json:"set" yaml:"set"`                           // 覆盖 EnvPolicy.Set 中的同名变量
+	AllowCallerEnv []string          `json:"allow_caller_env" yaml:"allow_caller_env"` // 追加到 EnvPolicy.AllowCallerEnv
+}
+
+// DefaultEnvPassthrough 未配置 env.passthrough 时继承的变量
+var DefaultEnvPassthrough = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_*", "TZ", "TERM", "TMPDIR",
+	"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOPROXY", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOFLAGS", "GOTOOLCHAIN", "CGO_ENABLED",
+}
+
+// DefaultEnvRedact 始终移除的变量（API Key、Token 等凭据）
+var DefaultEnvRedact = []string{"AI_*", "*_API_KEY", "*_TOKEN", "*SECRET*", "*PASSWORD*"}
+
+// HTTPConfig HTTP/SSE 传输配置
+type HTTPConfig struct {
+	Addr      string `json:"addr" yaml:"addr"`             // 监听地址（默认仅本机 127.0.0.1:8765）
+	AuthToken string `json:"auth_token" yaml:"auth_token"` // 可选 Bearer Token，非空时所有请求必须携带 Authorization 头
+	// AllowInsecure 允许在非本机地址上不设 AuthToken 监听（默认拒绝启动）
+	AllowInsecure bool `json:"allow_insecure" yaml:"allow_insecure"`
 }
 
 // 默认值
@@ -44,8 +176,14 @@ const (
 	DefaultLogLevel         = "info"
 	DefaultMaxSearchResults = 50
 	DefaultMaxFileBytes     = 1024 * 1024 // 1 MB
+	DefaultTransport        = "stdio"
+	DefaultHTTPAddr         = "127.0.0.1:8765"
+	DefaultMaxJobs          = 4
 )
 
+// 支持的传输方式
+var validTransports = []string{"stdio", "http", "sse"}
+
 // 预置的提供商配置模板
 var builtinProviders = map[string]ProviderConfig{
 	"gemini": {
@@ -76,10 +214,27 @@ var builtinProviders = map[string]ProviderConfig{
 
 // LoadConfig 加载配置（环境变量 + 配置文件）
 func LoadConfig() (*Config, error) {
-	// 1. 从默认位置加载配置文件（如果存在）
+	return LoadConfigFrom("")
+}
+
+// LoadConfigFrom 加载配置，path 非空时只读取该文件（命令行 --config），否则按默认位置查找
+func LoadConfigFrom(path string) (*Config, error) {
 	cfg := &Config{}
 	cfg.setDefaults()
 
+	if path != "" {
+		if err := loadFromFile(path, cfg); err != nil {
+			return nil, fmt.Errorf("failed to load config from %s: %w", path, err)
+		}
+		cfg.ConfigFile = path
+		applyEnvOverrides(cfg)
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	// 1. 从默认位置加载配置文件（如果存在）
 	configPaths := []string{
 		"./config.json",
 		"./config.yaml",
@@ -132,6 +287,13 @@ func (c *Config) setDefaults() {
 	c.AllowedPaths = []string{} // 空表示不限制（生产环境应配置）
 	c.BlockedExtensions = []string{".env", ".key", ".pem", ".crt", ".cer", ".p12", ".pfx", ".jks", ".keystore"}
 	c.LowResourceMode = false
+	c.MaxJobs = DefaultMaxJobs
+	c.History = HistoryConfig{Dir: DefaultStateDir("history")}
+	c.Files = FilesConfig{TrashDir: DefaultStateDir("trash")}
+
+	// 传输默认使用 stdio，HTTP 仅监听本机
+	c.Transport = DefaultTransport
+	c.HTTP = HTTPConfig{Addr: DefaultHTTPAddr}
 
 	// 初始化 AI 配置，包含预置提供商
 	c.AI = AIConfig{
@@ -151,28 +313,123 @@ func loadFromFile(path string, cfg *Config) error {
 		return err
 	}
 
+	var partial fileConfig
 	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
-		// 需要 yaml 支持，暂时用 JSON 替代或使用 go-yaml（此处简化）
-		return fmt.Errorf("yaml support not yet implemented, use json")
-	}
-
-	// JSON
-	var partial struct {
-		AI                   AIConfig `json:"ai"`
-		LogLevel             string   `json:"log_level"`
-		MaxSearchResults     int      `json:"max_search_results"`
-		MaxFileBytes         int64    `json:"max_file_bytes"`
-		BuildTimeout         int64    `json:"build_timeout_seconds"`
-		AllowedBuildCommands []string `json:"allowed_build_commands"`
-		AllowedPaths         []string `json:"allowed_paths"`
-		BlockedExtensions    []string `json:"blocked_extensions"`
-		LowResourceMode      bool     `json:"low_resource_mode"`
-	}
-	if err := json.Unmarshal(data, &partial); err != nil {
-		return err
+		if err := decodeYAML(data, &partial); err != nil {
+			return err
+		}
+	} else {
+		unknown, err := decodeJSON(data, &partial)
+		if err != nil {
+			return err
+		}
+		if len(unknown) > 0 {
+			log.Printf("[config] warning: %s: ignoring unknown keys: %s", path, strings.Join(unknown, ", "))
+		}
+	}
+	mergeFileConfig(&partial, cfg)
+	return nil
+}
+
+// fileConfig 配置文件中可出现的字段（JSON 与 YAML 共用，未出现的字段保留默认值）
+type fileConfig struct {
+	RootDir              string            `json:"root_dir" yaml:"root_dir"`
+	AI                   AIConfig          `json:"ai" yaml:"ai"`
+	LogLevel             string            `json:"log_level" yaml:"log_level"`
+	MaxSearchResults     int               `json:"max_search_results" yaml:"max_search_results"`
+	MaxFileBytes         int64             `json:"max_file_bytes" yaml:"max_file_bytes"`
+	BuildTimeout         int64             `json:"build_timeout_seconds" yaml:"build_timeout_seconds"`
+	AllowedBuildCommands []string          `json:"allowed_build_commands" yaml:"allowed_build_commands"`
+	CommandPolicies      []CommandPolicy   `json:"command_policies" yaml:"command_policies"`
+	AllowedPaths         []string          `json:"allowed_paths" yaml:"allowed_paths"`
+	BlockedExtensions    []string          `json:"blocked_extensions" yaml:"blocked_extensions"`
+	LowResourceMode      bool              `json:"low_resource_mode" yaml:"low_resource_mode"`
+	Transport            string            `json:"transport" yaml:"transport"`
+	HTTP                 HTTPConfig        `json:"http" yaml:"http"`
+	Workspaces           []WorkspaceConfig `json:"workspaces" yaml:"workspaces"`
+	AllowClientRoots     bool              `json:"allow_client_roots" yaml:"allow_client_roots"`
+	DisabledTools        []string          `json:"disabled_tools" yaml:"disabled_tools"`
+	MaxJobs              int               `json:"max_jobs" yaml:"max_jobs"`
+	ResourceLimits       ResourceLimits    `json:"resource_limits" yaml:"resource_limits"`
+	Sandbox              *SandboxConfig    `json:"sandbox" yaml:"sandbox"`
+	Env                  *EnvPolicy        `json:"env" yaml:"env"`
+	History              HistoryConfig     `json:"history" yaml:"history"`
+	Files                FilesConfig       `json:"files" yaml:"files"`
+}
+
+// decodeYAML 严格解析 YAML：未知字段报错并带行号（如 "line 3: field allowed_build_comands not found"）
+func decodeYAML(data []byte, out *fileConfig) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid yaml: %w", err)
+	}
+	return nil
+}
+
+// decodeJSON 解析 JSON 并返回未知字段的路径（如 "sandbox.alow_network"）
+// 与 YAML 不同，JSON 配置保持宽松：已有配置中多余或遗留的字段只由调用方记录警告，不影响启动与热重载
+func decodeJSON(data []byte, out *fileConfig) ([]string, error) {
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	var unknown []string
+	collectUnknownKeys(raw, reflect.TypeOf(out).Elem(), "", &unknown)
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+// collectUnknownKeys 对照类型 t 的 json 标签遍历解析结果 v，把未知字段的路径追加到 unknown
+func collectUnknownKeys(v any, t reflect.Type, prefix string, unknown *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := make(map[string]reflect.Type, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for key, val := range obj {
+			ft, ok := fields[key]
+			if !ok {
+				*unknown = append(*unknown, prefix+key)
+				continue
+			}
+			collectUnknownKeys(val, ft, prefix+key+".", unknown)
+		}
+	case reflect.Map:
+		if obj, ok := v.(map[string]any); ok {
+			for key, val := range obj {
+				collectUnknownKeys(val, t.Elem(), prefix+key+".", unknown)
+			}
+		}
+	case reflect.Slice:
+		if list, ok := v.([]any); ok {
+			for i, val := range list {
+				collectUnknownKeys(val, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i), unknown)
+			}
+		}
 	}
+}
 
+// mergeFileConfig 将文件中设置的字段合并到 cfg（提供商配置按字段部分更新）
+func mergeFileConfig(partial *fileConfig, cfg *Config) {
 	// 合并配置（保留未设置的默认值）
+	if partial.RootDir != "" {
+		cfg.RootDir = partial.RootDir
+	}
 	if partial.LogLevel != "" {
 		cfg.LogLevel = partial.LogLevel
 	}
@@ -218,6 +475,9 @@ func loadFromFile(path string, cfg *Config) error {
 	if len(partial.AllowedBuildCommands) > 0 {
 		cfg.AllowedBuildCommands = partial.AllowedBuildCommands
 	}
+	if len(partial.CommandPolicies) > 0 {
+		cfg.CommandPolicies = partial.CommandPolicies
+	}
 	// 安全配置
 	if len(partial.AllowedPaths) > 0 {
 		cfg.AllowedPaths = partial.AllowedPaths
@@ -228,8 +488,61 @@ func loadFromFile(path string, cfg *Config) error {
 	if partial.LowResourceMode {
 		cfg.LowResourceMode = partial.LowResourceMode
 	}
-
-	return nil
+	// 传输配置
+	if partial.Transport != "" {
+		cfg.Transport = partial.Transport
+	}
+	if partial.HTTP.Addr != "" {
+		cfg.HTTP.Addr = partial.HTTP.Addr
+	}
+	if partial.HTTP.AuthToken != "" {
+		cfg.HTTP.AuthToken = partial.HTTP.AuthToken
+	}
+	if partial.HTTP.AllowInsecure {
+		cfg.HTTP.AllowInsecure = true
+	}
+	// 命名工作区
+	if len(partial.Workspaces) > 0 {
+		cfg.Workspaces = partial.Workspaces
+	}
+	if partial.AllowClientRoots {
+		cfg.AllowClientRoots = true
+	}
+	if len(partial.DisabledTools) > 0 {
+		cfg.DisabledTools = partial.DisabledTools
+	}
+	if partial.MaxJobs > 0 {
+		cfg.MaxJobs = partial.MaxJobs
+	}
+	mergeLimit(&cfg.ResourceLimits.CPUSeconds, partial.ResourceLimits.CPUSeconds)
+	mergeLimit(&cfg.ResourceLimits.AddressSpaceMB, partial.ResourceLimits.AddressSpaceMB)
+	mergeLimit(&cfg.ResourceLimits.OpenFiles, partial.ResourceLimits.OpenFiles)
+	mergeLimit(&cfg.ResourceLimits.MaxProcesses, partial.ResourceLimits.MaxProcesses)
+	mergeLimit(&cfg.ResourceLimits.FileSizeMB, partial.ResourceLimits.FileSizeMB)
+	if partial.Sandbox != nil {
+		cfg.Sandbox = *partial.Sandbox
+	}
+	if partial.Env != nil {
+		cfg.Env = *partial.Env
+	}
+	if partial.History.Disabled {
+		cfg.History.Disabled = true
+	}
+	if partial.History.Dir != "" {
+		cfg.History.Dir = partial.History.Dir
+	}
+	if partial.History.MaxEntries != 0 {
+		cfg.History.MaxEntries = partial.History.MaxEntries
+	}
+	if partial.History.MaxMB != 0 {
+		cfg.History.MaxMB = partial.History.MaxMB
+	}
+	if partial.Files.DisableDelete {
+		cfg.Files.DisableDelete = true
+	}
+	if partial.Files.TrashDir != "" {
+		cfg.Files.TrashDir = partial.Files.TrashDir
+	}
 }
 
 // applyEnvOverrides 应用环境变量覆盖配置（本地模式）
@@ -258,6 +571,17 @@ func applyEnvOverrides(cfg *Config) {
 	}
 	// AllowedBuildCommands 不支持环境变量（通常是列表），从配置文件读取
 
+	// 传输配置（Token 推荐通过环境变量注入，避免写入配置文件）
+	if v := os.Getenv("MCP_TRANSPORT"); v != "" {
+		cfg.Transport = v
+	}
+	if v := os.Getenv("MCP_HTTP_ADDR"); v != "" {
+		cfg.HTTP.Addr = v
+	}
+	if v := os.Getenv("MCP_HTTP_AUTH_TOKEN"); v != "" {
+		cfg.HTTP.AuthToken = v
+	}
+
 	// AI 提供商特定环境变量（可选）
 	// 格式：AI_<PROVIDER>_API_KEY, AI_<PROVIDER>_DEFAULT_MODEL
 	for providerName := range cfg.AI.Providers {
@@ -291,9 +615,48 @@ func (c *Config) Validate() error {
 	if c.BuildTimeout <= 0 {
 		errs = append(errs, &configError{field: "BuildTimeout", message: "must be positive"})
 	}
+	if c.MaxJobs <= 0 {
+		errs = append(errs, &configError{field: "MaxJobs", message: "must be positive"})
+	}
 	if len(c.AllowedBuildCommands) == 0 {
 		errs = append(errs, &configError{field: "AllowedBuildCommands", message: "cannot be empty"})
 	}
+	errs = append(errs, validatePolicies("CommandPolicies", c.CommandPolicies)...)
+	errs = append(errs, validateSandboxRules("Sandbox.Commands", c.Sandbox.Commands)...)
+	errs = append(errs, validateEnvPolicy("Env", c.Env)...)
+	if c.History.Dir != "" && !filepath.IsAbs(c.History.Dir) {
+		errs = append(errs, &configError{field: "History.Dir", message: "must be an absolute path"})
+	}
+	if c.History.MaxEntries < 0 {
+		errs = append(errs, &configError{field: "History.MaxEntries", message: "cannot be negative"})
+	}
+	if c.History.MaxMB < 0 {
+		errs = append(errs, &configError{field: "History.MaxMB", message: "cannot be negative"})
+	}
+	if c.Files.TrashDir != "" && !filepath.IsAbs(c.Files.TrashDir) {
+		errs = append(errs, &configError{field: "Files.TrashDir", message: "must be an absolute path"})
+	}
+	if !containsString(validTransports, c.Transport) {
+		errs = append(errs, &configError{field: "Transport", message: "must be one of " + strings.Join(validTransports, ", ")})
+	}
+	if c.Transport != "stdio" && c.HTTP.Addr == "" {
+		errs = append(errs, &configError{field: "HTTP.Addr", message: "cannot be empty for http/sse transport"})
+	}
+	seen := make(map[string]bool)
+	for i, ws := range c.Workspaces {
+		field := fmt.Sprintf("Workspaces[%d]", i)
+		switch {
+		case strings.TrimSpace(ws.Name) == "":
+			errs = append(errs, &configError{field: field, message: "name cannot be empty"})
+		case seen[ws.Name]:
+			errs = append(errs, &configError{field: field, message: fmt.Sprintf("duplicate name %q", ws.Name)})
+		}
+		seen[ws.Name] = true
+		if strings.TrimSpace(ws.RootDir) == "" {
+			errs = append(errs, &configError{field: field, message: "root_dir cannot be empty"})
+		}
+		errs = append(errs, validatePolicies(field+".CommandPolicies", ws.CommandPolicies)...)
+	}
 
 	if len(errs) == 0 {
 		return nil
@@ -301,6 +664,206 @@ func (c *Config) Validate() error {
 	return &validationError{errors: errs}
 }
 
+// validatePolicies 检查命令策略：命令名非空且不重复，max_args 非负
+func validatePolicies(field string, policies []CommandPolicy) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, p := range policies {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		name := strings.TrimSpace(p.Command)
+		switch {
+		case name == "":
+			errs = append(errs, &configError{field: f, message: "command cannot be empty"})
+		case strings.ContainsAny(name, " \t"):
+			errs = append(errs, &configError{field: f, message: fmt.Sprintf("command %q must be a single executable name", name)})
+		case seen[name]:
+			errs = append(errs, &configError{field: f, message: fmt.Sprintf("duplicate command %q", name)})
+		}
+		seen[name] = true
+		if p.MaxArgs < 0 {
+			errs = append(errs, &configError{field: f, message: "max_args cannot be negative"})
+		}
+	}
+	return errs
+}
+
+// validateSandboxRules 检查沙箱规则：命令名非空且不重复
+func validateSandboxRules(field string, rules []SandboxRule) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, r := range rules {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		name := strings.TrimSpace(r.Command)
+		switch {
+		case name == "":
+			errs = append(errs, &configError{field: f, message: "command cannot be empty"})
+		case strings.ContainsAny(name, " \t"):
+			errs = append(errs, &configError{field: f, message: fmt.Sprintf("command %q must be a single executable name", name)})
+		case seen[name]:
+			errs = append(errs, &configError{field: f, message: fmt.Sprintf("duplicate command %q", name)})
+		}
+		seen[name] = true
+	}
+	return errs
+}
+
+// validateEnvPolicy 检查环境变量策略：注入的变量名合法，按命令的规则命令名非空且不重复
+func validateEnvPolicy(field string, p EnvPolicy) []error {
+	var errs []error
+	checkSet := func(f string, set map[string]string) {
+		for name := range set {
+			if name == "" || strings.ContainsAny(name, "= \t") {
+				errs = append(errs, &configError{field: f, message: fmt.Sprintf("invalid variable name %q", name)})
+			}
+		}
+	}
+	checkSet(field+".Set", p.Set)
+	seen := make(map[string]bool)
+	for i, r := range p.Commands {
+		f := fmt.Sprintf("%s.Commands[%d]", field, i)
+		name := strings.TrimSpace(r.Command)
+		switch {
+		case name == "":
+			errs = append(errs, &configError{field: f, message: "command cannot be empty"})
+		case seen[name]:
+			errs = append(errs, &configError{field: f, message: fmt.Sprintf("duplicate command %q", name)})
+		}
+		seen[name] = true
+		checkSet(f+".Set", r.Set)
+	}
+	return errs
+}
+
+// mergeLimit 文件中设置了非 0 值时覆盖
+func mergeLimit(dst *uint64, v uint64) {
+	if v > 0 {
+		*dst = v
+	}
+}
+
+// ExecLimits 返回执行命令时实际使用的资源限制：LowResourceMode 下未配置（为 0）的项使用更严格的默认值
+func (c *Config) ExecLimits() ResourceLimits {
+	l := c.ResourceLimits
+	if !c.LowResourceMode {
+		return l
+	}
+	for _, f := range []struct {
+		dst *uint64
+		def uint64
+	}{
+		{&l.CPUSeconds, lowResourceLimits.CPUSeconds},
+		{&l.AddressSpaceMB, lowResourceLimits.AddressSpaceMB},
+		{&l.OpenFiles, lowResourceLimits.OpenFiles},
+		{&l.MaxProcesses, lowResourceLimits.MaxProcesses},
+		{&l.FileSizeMB, lowResourceLimits.FileSizeMB},
+	} {
+		if *f.dst == 0 {
+			*f.dst = f.def
+		}
+	}
+	return l
+}
+
+// HistoryLimits 返回编辑日志实际使用的设置：未配置（为 0）的保留限制使用默认值，LowResourceMode 下默认值更小
+func (c *Config) HistoryLimits() HistoryConfig {
+	h := c.History
+	if h.MaxEntries == 0 {
+		h.MaxEntries = DefaultHistoryMaxEntries
+		if c.LowResourceMode {
+			h.MaxEntries = lowResourceHistoryMaxEntries
+		}
+	}
+	if h.MaxMB == 0 {
+		h.MaxMB = DefaultHistoryMaxMB
+		if c.LowResourceMode {
+			h.MaxMB = lowResourceHistoryMaxMB
+		}
+	}
+	return h
+}
+
+// DefaultStateDir 编辑日志、回收站等状态数据的默认目录：$XDG_STATE_HOME/agentcode-mcp/<name>，
+// 未设置时为 ~/.local/state/agentcode-mcp/<name>；无法确定主目录时为空（不启用）
+func DefaultStateDir(name string) string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "agentcode-mcp", name)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "agentcode-mcp", name)
+}
+
+// SandboxFor 返回执行某个可执行文件时实际使用的沙箱设置（已应用 Sandbox.Commands 中的覆盖，Commands 为空）
+func (c *Config) SandboxFor(command string) SandboxConfig {
+	sb := c.Sandbox
+	sb.Commands = nil
+	sb.WritablePaths = append([]string{}, c.Sandbox.WritablePaths...)
+	for _, r := range c.Sandbox.Commands {
+		if strings.TrimSpace(r.Command) != command {
+			continue
+		}
+		if r.Enabled != nil {
+			sb.Enabled = *r.Enabled
+		}
+		if r.AllowNetwork != nil {
+			sb.AllowNetwork = *r.AllowNetwork
+		}
+		sb.WritablePaths = append(sb.WritablePaths, r.WritablePaths...)
+		break
+	}
+	return sb
+}
+
+// EnvFor 返回执行某个可执行文件时实际使用的环境变量策略（已应用默认列表与 Env.Commands，Commands 为空）
+func (c *Config) EnvFor(command string) EnvPolicy {
+	p := EnvPolicy{
+		Passthrough:    append([]string{}, c.Env.Passthrough...),
+		Set:            make(map[string]string, len(c.Env.Set)),
+		Redact:         append(append([]string{}, DefaultEnvRedact...), c.Env.Redact...),
+		AllowCallerEnv: append([]string{}, c.Env.AllowCallerEnv...),
+	}
+	if len(p.Passthrough) == 0 {
+		p.Passthrough = append(p.Passthrough, DefaultEnvPassthrough...)
+	}
+	for k, v := range c.Env.Set {
+		p.Set[k] = v
+	}
+	for _, r := range c.Env.Commands {
+		if strings.TrimSpace(r.Command) != command {
+			continue
+		}
+		p.Passthrough = append(p.Passthrough, r.Passthrough...)
+		for k, v := range r.Set {
+			p.Set[k] = v
+		}
+		p.AllowCallerEnv = append(p.AllowCallerEnv, r.AllowCallerEnv...)
+		break
+	}
+	return p
+}
+
+// ForWorkspace 返回应用了命名工作区设置的配置副本（列表字段为空时沿用全局值）
+func (c *Config) ForWorkspace(ws WorkspaceConfig) *Config {
+	out := *c
+	out.RootDir = ws.RootDir
+	if len(ws.AllowedPaths) > 0 {
+		out.AllowedPaths = ws.AllowedPaths
+	}
+	if len(ws.BlockedExtensions) > 0 {
+		out.BlockedExtensions = ws.BlockedExtensions
+	}
+	if len(ws.AllowedBuildCommands) > 0 {
+		out.AllowedBuildCommands = ws.AllowedBuildCommands
+	}
+	if len(ws.CommandPolicies) > 0 {
+		out.CommandPolicies = ws.CommandPolicies
+	}
+	out.Workspaces = nil
+	return &out
+}
+
 // createPlaceholderConfig 创建占位配置文件到用户主目录
 func createPlaceholderConfig(cfg *Config) error {
 	home, err := os.UserHomeDir()
@@ -325,6 +888,9 @@ func createPlaceholderConfig(cfg *Config) error {
 		AllowedPaths:         []string{},
 		BlockedExtensions:    []string{".env", ".key", ".pem", ".crt", ".cer", ".p12", ".pfx", ".jks", ".keystore"},
 		LowResourceMode:      false,
+		Transport:            DefaultTransport,
+		HTTP:                 HTTPConfig{Addr: DefaultHTTPAddr},
+		MaxJobs:              DefaultMaxJobs,
 	}
 
 	data, err := json.MarshalIndent(placeholder, "", "  ")
@@ -355,6 +921,15 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	valStr := os.Getenv(key)
 	if valStr == "" {
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFrom_YAML(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `log_level: debug
+allowed_build_commands: [go, make]
+blocked_extensions: [.env]
+resource_limits:
+  open_files: 256
+ai:
+  providers:
+    deepseek:
+      api_key: sk-test
+`)
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "debug" || strings.Join(cfg.AllowedBuildCommands, ",") != "go,make" || cfg.ResourceLimits.OpenFiles != 256 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	// 提供商配置按字段部分更新，其余沿用内置模板
+	p := cfg.AI.Providers["deepseek"]
+	if p.APIKey != "sk-test" || p.BaseURL != builtinProviders["deepseek"].BaseURL {
+		t.Errorf("deepseek provider = %+v", p)
+	}
+	if cfg.ConfigFile != path {
+		t.Errorf("ConfigFile = %q, want %q", cfg.ConfigFile, path)
+	}
+}
+
+func TestLoadConfigFrom_UnknownKeys(t *testing.T) {
+	// YAML：未知字段报错并带行号
+	path := writeConfig(t, "config.yaml", "log_level: info\n\nallowed_build_comands: [go]\n")
+	_, err := LoadConfigFrom(path)
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "allowed_build_comands") {
+		t.Errorf("expected unknown field error with line number, got %v", err)
+	}
+	path = writeConfig(t, "config.yml", "history:\n  dir: /tmp/h\n  max_entrys: 10\n")
+	if _, err := LoadConfigFrom(path); err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "max_entrys") {
+		t.Errorf("expected nested unknown field error, got %v", err)
+	}
+
+}
+
+func TestLoadConfigFrom_JSONUnknownKeys(t *testing.T) {
+	// JSON 保持宽松：未知字段只记录警告，其余字段照常生效
+	path := writeConfig(t, "config.json", `{"log_level": "debug", "allowed_build_comands": ["go"], "sandbox": {"enabled": true, "alow_network": true}}`)
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("unknown json keys should not be fatal: %v", err)
+	}
+	if cfg.LogLevel != "debug" || !cfg.Sandbox.Enabled {
+		t.Errorf("known fields not applied: %+v", cfg)
+	}
+	if !strings.Contains(logs.String(), "allowed_build_comands, sandbox.alow_network") {
+		t.Errorf("expected warning listing unknown keys, got %q", logs.String())
+	}
+
+	var partial fileConfig
+	unknown, err := decodeJSON([]byte(`{"workspaces": [{"name": "a", "rot_dir": "/x"}], "ai": {"providers": {"openai": {"apikey": "k"}}}}`), &partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(unknown, ","); got != "ai.providers.openai.apikey,workspaces[0].rot_dir" {
+		t.Errorf("unknown keys = %s", got)
+	}
+
+	// 语法错误仍然报错
+	path = writeConfig(t, "config.json", `{"log_level": `)
+	if _, err := LoadConfigFrom(path); err == nil || !strings.Contains(err.Error(), "invalid json") {
+		t.Errorf("expected syntax error, got %v", err)
+	}
+}
+
+func TestLoadConfig_Placeholder(t *testing.T) {
+	// 首次运行生成的占位配置在严格解析下可以再次加载
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// 避开当前目录下的 config.json / config.yaml
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if _, err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(home, ".config", "agentcode-mcp", "config.json")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("placeholder not created: %v", err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("loading placeholder: %v", err)
+	}
+	if cfg.ConfigFile != path {
+		t.Errorf("ConfigFile = %q, want %q", cfg.ConfigFile, path)
+	}
+}
@@ -5,11 +5,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// reloadDebounce 合并短时间内的多次文件事件（编辑器保存时通常会触发 写入+重命名+创建）
+const reloadDebounce = 200 * time.Millisecond
+
 // ReloadCallback 配置重载时的回调函数类型
 type ReloadCallback func(oldCfg, newCfg *Config) error
 
@@ -22,6 +27,7 @@ type Reloader struct {
 	callbacks []ReloadCallback
 	stopChan  chan struct{}
 	running   bool
+	reloadMu  sync.Mutex // 保证重载与回调串行执行
 }
 
 // NewReloader 创建配置重载器
@@ -83,9 +89,13 @@ func (r *Reloader) Stop() error {
 
 // watchLoop 监视文件系统事件的主循环
 func (r *Reloader) watchLoop() {
+	var debounce *time.Timer
 	for {
 		select {
 		case <-r.stopChan:
+			if debounce != nil {
+				debounce.Stop()
+			}
 			return
 		case event, ok := <-r.watcher.Events:
 			if !ok {
@@ -98,16 +108,18 @@ func (r *Reloader) watchLoop() {
 
 			// 只处理写入和重命名/创建事件
 			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
-				// 延迟一小段时间确保文件完全写入
-				// 实际中可能需要更复杂的处理，这里简单 sleep
-				// 为避免阻塞事件循环，在单独 goroutine 中执行重载
-				go func() {
+				// 延迟一小段时间确保文件完全写入，并把连续事件合并为一次重载
+				// 定时器在单独 goroutine 中执行重载，不阻塞事件循环
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(reloadDebounce, func() {
 					if err := r.reload(); err != nil {
 						log.Printf("[config] reload failed: %v", err)
 					} else {
 						log.Printf("[config] reloaded successfully from %s", r.configPath)
 					}
-				}()
+				})
 			}
 
 		case err, ok := <-r.watcher.Errors:
@@ -121,6 +133,9 @@ func (r *Reloader) watchLoop() {
 
 // reload 执行配置重载
 func (r *Reloader) reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
 	// 1. 加载新配置
 	newCfg := &Config{}
 	newCfg.setDefaults()
@@ -130,9 +145,10 @@ func (r *Reloader) reload() error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// 2. 应用环境变量覆盖（可选：是否需要？热重载通常只从文件加载）
-	// 如果希望在热重载时也应用环境变量，取消下面这行
-	// applyEnvOverrides(newCfg)
+	newCfg.ConfigFile = r.configPath
+
+	// 2. 应用环境变量覆盖（与启动时保持一致，否则通过环境变量注入的值会在重载后丢失）
+	applyEnvOverrides(newCfg)
 
 	// 3. 验证新配置
 	if err := newCfg.Validate(); err != nil {
@@ -143,10 +159,11 @@ func (r *Reloader) reload() error {
 	r.mu.Lock()
 	oldCfg := r.cfg
 	r.cfg = newCfg
+	callbacks := append([]ReloadCallback(nil), r.callbacks...)
 	r.mu.Unlock()
 
 	// 5. 触发回调（让各个组件更新自己的状态）
-	for _, cb := range r.callbacks {
+	for _, cb := range callbacks {
 		if err := cb(oldCfg, newCfg); err != nil {
 			log.Printf("[config] callback failed: %v", err)
 		}
@@ -178,3 +195,47 @@ func (r *Reloader) ConfigPath() string {
 func (r *Reloader) Running() bool {
 	return r.running
 }
+
+// Diff 列出两份配置之间的差异（用于重载日志），敏感字段只提示已变更
+func Diff(oldCfg, newCfg *Config) []string {
+	var changes []string
+	add := func(name string, oldVal, newVal any) {
+		if !reflect.DeepEqual(oldVal, newVal) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, oldVal, newVal))
+		}
+	}
+	add("root_dir", oldCfg.RootDir, newCfg.RootDir)
+	add("log_level", oldCfg.LogLevel, newCfg.LogLevel)
+	add("max_search_results", oldCfg.MaxSearchResults, newCfg.MaxSearchResults)
+	add("max_file_bytes", oldCfg.MaxFileBytes, newCfg.MaxFileBytes)
+	add("build_timeout_seconds", oldCfg.BuildTimeout, newCfg.BuildTimeout)
+	add("allowed_build_commands", oldCfg.AllowedBuildCommands, newCfg.AllowedBuildCommands)
+	if !reflect.DeepEqual(oldCfg.CommandPolicies, newCfg.CommandPolicies) {
+		changes = append(changes, fmt.Sprintf("command_policies: %d -> %d entries (changed)", len(oldCfg.CommandPolicies), len(newCfg.CommandPolicies)))
+	}
+	add("allowed_paths", oldCfg.AllowedPaths, newCfg.AllowedPaths)
+	add("blocked_extensions", oldCfg.BlockedExtensions, newCfg.BlockedExtensions)
+	add("low_resource_mode", oldCfg.LowResourceMode, newCfg.LowResourceMode)
+	add("resource_limits", oldCfg.ResourceLimits, newCfg.ResourceLimits)
+	if !reflect.DeepEqual(oldCfg.Sandbox, newCfg.Sandbox) {
+		changes = append(changes, fmt.Sprintf("sandbox: enabled %v -> %v (changed)", oldCfg.Sandbox.Enabled, newCfg.Sandbox.Enabled))
+	}
+	if !reflect.DeepEqual(oldCfg.Env, newCfg.Env) {
+		changes = append(changes, "env: changed")
+	}
+	add("history", oldCfg.History, newCfg.History)
+	add("files", oldCfg.Files, newCfg.Files)
+	add("disabled_tools", oldCfg.DisabledTools, newCfg.DisabledTools)
+	add("max_jobs", oldCfg.MaxJobs, newCfg.MaxJobs)
+	add("allow_client_roots", oldCfg.AllowClientRoots, newCfg.AllowClientRoots)
+	add("transport", oldCfg.Transport, newCfg.Transport)
+	add("http.addr", oldCfg.HTTP.Addr, newCfg.HTTP.Addr)
+	add("http.allow_insecure", oldCfg.HTTP.AllowInsecure, newCfg.HTTP.AllowInsecure)
+	if oldCfg.HTTP.AuthToken != newCfg.HTTP.AuthToken {
+		changes = append(changes, "http.auth_token: changed")
+	}
+	if !reflect.DeepEqual(oldCfg.Workspaces, newCfg.Workspaces) {
+		changes = append(changes, fmt.Sprintf("workspaces: %d -> %d entries (changed)", len(oldCfg.Workspaces), len(newCfg.Workspaces)))
+	}
+	return changes
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	oldCfg := &Config{RootDir: "/a", LogLevel: "info", AllowedBuildCommands: []string{"go"}}
+	oldCfg.HTTP.AuthToken = "secret-1"
+	newCfg := &Config{RootDir: "/b", LogLevel: "debug", AllowedBuildCommands: []string{"go", "make"}}
+	newCfg.HTTP.AuthToken = "secret-2"
+	newCfg.CommandPolicies = []CommandPolicy{{Command: "go"}}
+
+	changes := Diff(oldCfg, newCfg)
+	want := []string{
+		"root_dir: /a -> /b",
+		"log_level: info -> debug",
+		"allowed_build_commands: [go] -> [go make]",
+		"command_policies: 0 -> 1 entries (changed)",
+		"http.auth_token: changed",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Diff =\n%s\nwant\n%s", strings.Join(changes, "\n"), strings.Join(want, "\n"))
+	}
+	// 敏感字段不出现在日志中
+	if strings.Contains(strings.Join(changes, " "), "secret") {
+		t.Error("Diff leaks auth token")
+	}
+	if changes := Diff(oldCfg, oldCfg); len(changes) != 0 {
+		t.Errorf("Diff of identical configs = %v", changes)
+	}
+}
+
+func TestReloader_DebouncesWrites(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"log_level":"info"}`)
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(cfg, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	r.RegisterCallback(func(oldCfg, newCfg *Config) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, oldCfg.LogLevel+"->"+newCfg.LogLevel)
+		return nil
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// 连续多次写入只触发一次重载，且读到的是最后一次写入的内容
+	for _, level := range []string{"warn", "error", "debug"} {
+		if err := os.WriteFile(path, []byte(`{"log_level":"`+level+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for r.GetConfig().LogLevel != "debug" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(2 * reloadDebounce)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 1 || calls[0] != "info->debug" {
+		t.Errorf("callbacks = %v, want one info->debug reload", calls)
+	}
+}
+
+func TestReloader_InvalidConfigKeepsOld(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"log_level":"info"}`)
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(cfg, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.watcher.Close()
+	called := false
+	r.RegisterCallback(func(oldCfg, newCfg *Config) error {
+		called = true
+		return nil
+	})
+
+	if err := os.WriteFile(path, []byte(`{"log_level":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Fatal("expected error for malformed config")
+	}
+	if called || r.GetConfig() != cfg {
+		t.Error("malformed config replaced the current one")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 )
 
 // Logger 接口定义
@@ -44,15 +45,19 @@ var stringLevels = map[string]Level{
 
 // StdLogger 基于标准库 log 的默认实现
 type StdLogger struct {
-	level Level
+	level atomic.Int32 // 当前级别，可通过 SetLevel 在运行中修改（配置热重载）
 }
 
 // NewStdLogger 解析日志级别字符串，不合法时回退到 info；返回线程安全的 Logger 实例，将日志输出到 stderr。
 func NewStdLogger(levelStr string) Logger {
-	lvl := parseLevel(levelStr)
-	return &StdLogger{
-		level: lvl,
-	}
+	l := &StdLogger{}
+	l.SetLevel(levelStr)
+	return l
+}
+
+// SetLevel 修改日志级别，不合法时回退到 info；并发安全
+func (l *StdLogger) SetLevel(levelStr string) {
+	l.level.Store(int32(parseLevel(levelStr)))
 }
 
 // parseLevel 解析日志级别字符串（忽略大小写），未知时返回 LevelInfo
@@ -66,7 +71,7 @@ func parseLevel(s string) Level {
 
 // shouldLog 判断给定级别是否满足当前日志级别
 func (l *StdLogger) shouldLog(level Level) bool {
-	return level >= l.level
+	return level >= Level(l.level.Load())
 }
 
 // formatMessage 格式化日志消息，包含键值对
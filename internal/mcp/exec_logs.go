@@ -0,0 +1,50 @@
+package mcp
+
+import "sync"
+
+// maxTrackedExecLogs 记录归属的执行日志个数上限（远大于 exec-logs 目录中保留的日志数）
+const maxTrackedExecLogs = 1024
+
+// execLogOwner 执行日志的归属：产生日志的客户端会话与工作区
+type execLogOwner struct {
+	client    string
+	workspace string
+}
+
+// execLogOwners 记录本进程产生的执行日志属于哪个客户端的哪个工作区。
+// 日志 ID 会出现在工具输出中，HTTP 模式下多个会话共用同一个日志目录，
+// read_exec_log 只允许读取本会话在同一工作区中产生的日志（与 progress 通知只发给发起方一致）。
+type execLogOwners struct {
+	mu     sync.Mutex
+	owners map[string]execLogOwner
+	order  []string // 按记录顺序，超过上限时淘汰最早的记录
+}
+
+func newExecLogOwners() *execLogOwners {
+	return &execLogOwners{owners: make(map[string]execLogOwner)}
+}
+
+// add 记录日志 logID 属于客户端 client 的工作区 ws
+func (o *execLogOwners) add(logID, client, ws string) {
+	if logID == "" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.owners[logID]; !ok {
+		o.order = append(o.order, logID)
+	}
+	o.owners[logID] = execLogOwner{client: client, workspace: ws}
+	for len(o.order) > maxTrackedExecLogs {
+		delete(o.owners, o.order[0])
+		o.order = o.order[1:]
+	}
+}
+
+// owns 判断日志 logID 是否由客户端 client 在工作区 ws 中产生
+func (o *execLogOwners) owns(logID, client, ws string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	owner, ok := o.owners[logID]
+	return ok && owner == execLogOwner{client: client, workspace: ws}
+}
@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// callTool 以会话 session 调用工具，返回响应原文
+func callTool(t *testing.T, url, session, name, args string) string {
+	t.Helper()
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, name, args)
+	resp := doMCP(t, http.MethodPost, url, session, "application/json", body)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return string(data)
+}
+
+func TestReadExecLog_ScopedToSessionAndWorkspace(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := testReloadConfig(t)
+	cfg.AllowedBuildCommands = []string{"echo"}
+	cfg.BuildTimeout = 5
+	cfg.Workspaces = []config.WorkspaceConfig{
+		{Name: "main", RootDir: t.TempDir()},
+		{Name: "other", RootDir: t.TempDir()},
+	}
+	_, url := newReloadTestServer(t, cfg)
+	sessA := initSession(t, url)
+	sessB := initSession(t, url)
+
+	out := callTool(t, url, sessA, "workspace.secure_exec", `{"command":"echo","args":["secret-output"]}`)
+	m := regexp.MustCompile(`Log: ([0-9a-f]{16})`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("no log id in secure_exec output: %s", out)
+	}
+	read := func(session, workspace string) string {
+		return callTool(t, url, session, "workspace.read_exec_log", fmt.Sprintf(`{"logId":%q,"workspace":%q}`, m[1], workspace))
+	}
+
+	if got := read(sessA, ""); !strings.Contains(got, "secret-output") {
+		t.Errorf("owner cannot read its log: %s", got)
+	}
+	if got := read(sessA, "main"); !strings.Contains(got, "secret-output") {
+		t.Errorf("owner cannot read its log by workspace name: %s", got)
+	}
+	if got := read(sessA, "other"); strings.Contains(got, "secret-output") || !strings.Contains(got, "not found") {
+		t.Errorf("log readable from another workspace: %s", got)
+	}
+	if got := read(sessB, ""); strings.Contains(got, "secret-output") || !strings.Contains(got, "not found") {
+		t.Errorf("log readable from another session: %s", got)
+	}
+}
@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"opencode-go-mcp/internal/log"
+	"opencode-go-mcp/internal/workspace"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// 本文件在底层传输层之上补充 mcp-golang 未实现的协议能力（它没有提供服务端向客户端发请求、也不能配置 capabilities）：
+//  1. roots：拦截 initialize 请求记录客户端是否支持 roots；收到 notifications/initialized 或
+//     notifications/roots/list_changed 后发送 roots/list，并拦截其响应（不交给 Protocol），见 roots.go。
+//     以上状态按客户端会话（clientID）分别记录，HTTP 模式下每个会话只能设置自己的 root。
+//  2. tools.listChanged：改写 initialize 响应中的 capabilities，声明工具列表会变化（热重载可能启用/禁用工具），
+//     对应的 notifications/tools/list_changed 由 mcp.Server 在注册/注销工具时发送。
+//  3. progress：把 tools/call 的 _meta.progressToken 放入请求 context，见 progress.go。
+
+// serverRequestIDBase 服务端主动发出的请求 ID 起点，避免与 Protocol 自身的 ID 混淆
+const serverRequestIDBase = 1 << 40
+
+// extTransport 包装底层传输层，其余方法直接委托
+type extTransport struct {
+	transport.Transport
+	registry *workspace.Registry
+	logger   log.Logger
+	nextID   atomic.Int64
+
+	mu             sync.Mutex
+	rootsSupported map[string]bool                // 按客户端记录是否声明了 roots 能力
+	pendingRoots   map[transport.RequestId]string // 等待响应的 roots/list 请求 → 发往的客户端
+	initializeID   map[transport.RequestId]bool   // 等待改写响应的 initialize 请求
+}
+
+func newExtTransport(inner transport.Transport, registry *workspace.Registry, logger log.Logger) *extTransport {
+	return &extTransport{
+		Transport:      inner,
+		registry:       registry,
+		logger:         logger,
+		rootsSupported: make(map[string]bool),
+		pendingRoots:   make(map[transport.RequestId]string),
+		initializeID:   make(map[transport.RequestId]bool),
+	}
+}
+
+// Send 在发送前改写 initialize 响应的 capabilities
+func (t *extTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	if message.Type == transport.BaseMessageTypeJSONRPCResponseType && t.take(t.initializeID, message.JsonRpcResponse.Id) {
+		resp := *message.JsonRpcResponse
+		resp.Result = withToolsListChanged(resp.Result)
+		message = transport.NewBaseMessageResponse(&resp)
+	}
+	return t.Transport.Send(ctx, message)
+}
+
+// SetMessageHandler 在交给 Protocol 之前拦截扩展相关消息
+func (t *extTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.Transport.SetMessageHandler(func(ctx context.Context, message *transport.BaseJsonRpcMessage) {
+		if t.intercept(ctx, message) {
+			return
+		}
+		handler(t.withProgress(ctx, message), message)
+	})
+}
+
+// intercept 处理扩展相关消息，返回 true 表示消息已被消费
+func (t *extTransport) intercept(ctx context.Context, message *transport.BaseJsonRpcMessage) bool {
+	client := clientID(ctx)
+	switch message.Type {
+	case transport.BaseMessageTypeJSONRPCRequestType:
+		if message.JsonRpcRequest.Method == "initialize" {
+			var params struct {
+				Capabilities struct {
+					Roots json.RawMessage `json:"roots"`
+				} `json:"capabilities"`
+			}
+			_ = json.Unmarshal(message.JsonRpcRequest.Params, &params)
+			t.mu.Lock()
+			t.rootsSupported[client] = len(params.Capabilities.Roots) > 0 && string(params.Capabilities.Roots) != "null"
+			t.initializeID[message.JsonRpcRequest.Id] = true
+			t.mu.Unlock()
+		}
+
+	case transport.BaseMessageTypeJSONRPCNotificationType:
+		switch message.JsonRpcNotification.Method {
+		case "notifications/initialized":
+			t.mu.Lock()
+			supported := t.rootsSupported[client]
+			t.mu.Unlock()
+			if supported {
+				go t.requestRoots(client)
+			}
+		case "notifications/roots/list_changed":
+			go t.requestRoots(client)
+			return true
+		}
+
+	case transport.BaseMessageTypeJSONRPCResponseType:
+		if pending, ok := t.takeRoots(message.JsonRpcResponse.Id, client); pending {
+			if ok {
+				t.handleRootsResult(client, message.JsonRpcResponse.Result)
+			}
+			return true
+		}
+
+	case transport.BaseMessageTypeJSONRPCErrorType:
+		if pending, ok := t.takeRoots(message.JsonRpcError.Id, client); pending {
+			if ok {
+				t.logger.Warn(context.Background(), "roots/list failed", "error", message.JsonRpcError.Error.Message)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// take 取出并移除 set 中的 id，返回其是否存在
+func (t *extTransport) take(set map[transport.RequestId]bool, id transport.RequestId) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !set[id] {
+		return false
+	}
+	delete(set, id)
+	return true
+}
+
+// takeRoots 判断 id 是否为等待响应的 roots/list 请求（pending），
+// 仅当它发往 client 时才取出并返回 ok（其他客户端的应答被丢弃，请求继续等待）
+func (t *extTransport) takeRoots(id transport.RequestId, client string) (pending, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	to, pending := t.pendingRoots[id]
+	if !pending || to != client {
+		return pending, false
+	}
+	delete(t.pendingRoots, id)
+	return true, true
+}
+
+// forgetClient 释放客户端会话的状态（HTTP 会话结束时调用）
+func (t *extTransport) forgetClient(client string) {
+	t.mu.Lock()
+	delete(t.rootsSupported, client)
+	for id, to := range t.pendingRoots {
+		if to == client {
+			delete(t.pendingRoots, id)
+		}
+	}
+	t.mu.Unlock()
+	t.registry.RemoveClient(client)
+}
+
+// withToolsListChanged 将 initialize 结果中的 capabilities.tools.listChanged 置为 true
+func withToolsListChanged(result json.RawMessage) json.RawMessage {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(result, &body); err != nil {
+		return result
+	}
+	var caps map[string]json.RawMessage
+	if err := json.Unmarshal(body["capabilities"], &caps); err != nil {
+		return result
+	}
+	caps["tools"] = json.RawMessage(`{"listChanged":true}`)
+	capsJSON, err := json.Marshal(caps)
+	if err != nil {
+		return result
+	}
+	body["capabilities"] = capsJSON
+	patched, err := json.Marshal(body)
+	if err != nil {
+		return result
+	}
+	return patched
+}
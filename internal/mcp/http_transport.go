@@ -0,0 +1,721 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"opencode-go-mcp/internal/log"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// 本文件实现 HTTP 类传输层（同一个 Transport 可同时服务多个客户端）：
+//  1. Streamable HTTP（/mcp）：POST 一条 JSON-RPC 消息，请求的响应直接作为 HTTP 响应体返回；
+//     GET 打开 SSE 流接收服务端通知；DELETE 结束会话。
+//  2. 旧版 HTTP+SSE（/sse + /message）：GET /sse 建立事件流并下发 endpoint，
+//     客户端 POST /message?sessionId=... 发送消息，响应通过事件流返回。
+//  3. 会话只能由服务端创建（Streamable HTTP 的 initialize、旧版的 GET /sse）并记录在会话表中。
+//     其余请求必须携带已登记的会话 ID（缺失 400、未知 404），事件流归属于所在会话，
+//     会话结束（DELETE 或旧版事件流断开）时关闭事件流并取消该会话中进行中的请求。
+//  4. mcp-golang 的 Protocol 以请求 ID 区分并发请求，多个客户端的 ID 可能重复，
+//     因此每个请求进入时都会被改写为全局唯一的内部 ID，发送响应时再还原。
+//  5. 处理请求期间发出的通知（如携带命令输出的 progress）只发给发起请求的会话：
+//     优先写入该请求的响应流（客户端 Accept 含 text/event-stream 时 POST 响应升级为 SSE），
+//     否则写入会话的事件流；其余通知（如 tools/list_changed）广播到所有事件流。
+//  6. 会话数量有上限：没有进行中的请求或打开的事件流、且空闲超过 sessionTTL 的会话会被回收，
+//     达到 maxSessions 时新的 initialize 返回 503，避免重连或恶意客户端让会话表无限增长。
+
+const (
+	// sessionHeader Streamable HTTP 会话 ID 头
+	sessionHeader = "Mcp-Session-Id"
+	// maxHTTPMessageBytes 单条消息的最大字节数
+	maxHTTPMessageBytes = 4 * 1024 * 1024
+	// sseBufferSize 每个事件流的待发送消息缓冲
+	sseBufferSize = 64
+	// defaultSessionTTL 会话空闲（没有进行中的请求和打开的事件流）多久后回收
+	defaultSessionTTL = 30 * time.Minute
+	// defaultMaxSessions 同时存在的会话上限
+	defaultMaxSessions = 256
+)
+
+// pendingRequest 等待响应的请求
+type pendingRequest struct {
+	originalID transport.RequestId
+	session    *httpSession
+	respond    func(data []byte)
+	notify     func(data []byte) // 写入该请求的响应流，为 nil 时通知发往会话事件流
+}
+
+// requestRouteKey context 键，值为请求的内部 ID，用于把处理请求期间的通知路由回发起方
+type requestRouteKey struct{}
+
+// sessionKey context 键，值为会话 ID；客户端 root 等按客户端隔离的状态以此区分
+type sessionKey struct{}
+
+// clientID 返回消息所属的客户端会话 ID，stdio 传输只有一个客户端，返回 ""
+func clientID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	return id
+}
+
+// httpSession 服务端创建的客户端会话
+type httpSession struct {
+	id     string
+	ctx    context.Context // 会话生命周期，会话结束时取消
+	cancel context.CancelFunc
+	stream *sseStream // 当前打开的事件流，可为 nil；由 httpTransport.mu 保护
+
+	// 以下字段由 httpTransport.mu 保护
+	active   int       // 正在处理的 HTTP 请求数（含打开的事件流），大于 0 时不会过期
+	lastUsed time.Time // 最近一个请求结束的时间
+}
+
+// sseStream 一个打开的 SSE 事件流（Streamable HTTP 的 GET 流或旧版 /sse 会话）
+type sseStream struct {
+	id     string // 所属会话 ID
+	events chan []byte
+	done   chan struct{}
+	once   sync.Once
+}
+
+// send 将一条 JSON-RPC 消息写入事件流，流已关闭时丢弃
+func (s *sseStream) send(data []byte) {
+	select {
+	case s.events <- data:
+	case <-s.done:
+	}
+}
+
+// trySend 非阻塞写入事件流，缓冲已满或流已关闭时返回 false
+func (s *sseStream) trySend(data []byte) bool {
+	select {
+	case s.events <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *sseStream) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// httpTransport 实现 transport.Transport
+type httpTransport struct {
+	logger    log.Logger
+	authToken string
+
+	mu        sync.Mutex
+	onMessage func(ctx context.Context, message *transport.BaseJsonRpcMessage)
+	onError   func(error)
+	onClose   func()
+	pending   map[transport.RequestId]*pendingRequest
+	sessions  map[string]*httpSession
+
+	onSessionClose func(id string) // 会话结束时调用，可为 nil
+
+	sessionTTL  time.Duration // 空闲会话的回收时间
+	maxSessions int           // 同时存在的会话上限
+
+	nextID atomic.Int64
+}
+
+// newHTTPTransport 创建 HTTP 传输层，authToken 非空时要求 Bearer 认证
+func newHTTPTransport(logger log.Logger, authToken string) *httpTransport {
+	return &httpTransport{
+		logger:    logger,
+		authToken: authToken,
+		pending:   make(map[transport.RequestId]*pendingRequest),
+		sessions:  make(map[string]*httpSession),
+
+		sessionTTL:  defaultSessionTTL,
+		maxSessions: defaultMaxSessions,
+	}
+}
+
+// Start 实现 transport.Transport；HTTP 监听由 Server.RunHTTP 负责，这里无需额外动作
+func (t *httpTransport) Start(ctx context.Context) error {
+	return nil
+}
+
+// Send 实现 transport.Transport：响应路由回对应请求，请求处理期间的通知发给发起方，
+// context 带会话 ID 的消息（如 roots/list）只发给该会话，其余通知广播到所有事件流
+func (t *httpTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	switch message.Type {
+	case transport.BaseMessageTypeJSONRPCResponseType:
+		resp := *message.JsonRpcResponse
+		p, err := t.takePending(resp.Id)
+		if err != nil {
+			return err
+		}
+		resp.Id = p.originalID
+		data, err := json.Marshal(&resp)
+		if err != nil {
+			return fmt.Errorf("failed to marshal response: %w", err)
+		}
+		p.respond(data)
+		return nil
+
+	case transport.BaseMessageTypeJSONRPCErrorType:
+		errResp := *message.JsonRpcError
+		p, err := t.takePending(errResp.Id)
+		if err != nil {
+			return err
+		}
+		errResp.Id = p.originalID
+		data, err := json.Marshal(&errResp)
+		if err != nil {
+			return fmt.Errorf("failed to marshal error response: %w", err)
+		}
+		p.respond(data)
+		return nil
+
+	default:
+		data, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		if id, ok := ctx.Value(requestRouteKey{}).(transport.RequestId); ok {
+			t.sendToRequester(id, data)
+			return nil
+		}
+		if id := clientID(ctx); id != "" {
+			t.sendToSession(id, data)
+			return nil
+		}
+		t.broadcast(data)
+		return nil
+	}
+}
+
+// Close 实现 transport.Transport：结束所有会话并通知 Protocol（会取消进行中的请求）
+func (t *httpTransport) Close() error {
+	t.mu.Lock()
+	sessions := make([]*httpSession, 0, len(t.sessions))
+	for _, sess := range t.sessions {
+		sessions = append(sessions, sess)
+	}
+	t.mu.Unlock()
+	for _, sess := range sessions {
+		t.closeSession(sess)
+	}
+
+	t.mu.Lock()
+	handler := t.onClose
+	t.mu.Unlock()
+	if handler != nil {
+		handler()
+	}
+	return nil
+}
+
+// closeStreams 关闭所有事件流（会话保留），用于优雅关闭时让长连接尽快结束
+func (t *httpTransport) closeStreams() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sess := range t.sessions {
+		if sess.stream != nil {
+			sess.stream.close()
+			sess.stream = nil
+		}
+	}
+}
+
+// newSession 回收过期会话后创建并登记新会话；会话数已达上限时写出 503 并返回 nil
+// 返回的会话已计入一个进行中的请求，调用方处理完后须调用 release
+func (t *httpTransport) newSession(w http.ResponseWriter) *httpSession {
+	t.expireSessions()
+
+	id := newSessionID()
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), sessionKey{}, id))
+	sess := &httpSession{id: id, ctx: ctx, cancel: cancel, active: 1}
+	t.mu.Lock()
+	if len(t.sessions) >= t.maxSessions {
+		t.mu.Unlock()
+		cancel()
+		t.logger.Warn(context.Background(), "Refusing new session: too many sessions", "max", t.maxSessions)
+		http.Error(w, "too many sessions", http.StatusServiceUnavailable)
+		return nil
+	}
+	t.sessions[sess.id] = sess
+	t.mu.Unlock()
+	return sess
+}
+
+// lookupSession 按 ID 查找会话；ID 缺失或未登记时写出 400 / 404 并返回 nil
+// 找到的会话已计入一个进行中的请求，调用方处理完后须调用 release
+func (t *httpTransport) lookupSession(w http.ResponseWriter, id string) *httpSession {
+	if id == "" {
+		http.Error(w, "missing "+sessionHeader, http.StatusBadRequest)
+		return nil
+	}
+	t.mu.Lock()
+	sess := t.sessions[id]
+	if sess != nil {
+		sess.active++
+	}
+	t.mu.Unlock()
+	if sess == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return nil
+	}
+	return sess
+}
+
+// release 结束会话中的一个请求并记录最近使用时间
+func (t *httpTransport) release(sess *httpSession) {
+	t.mu.Lock()
+	sess.active--
+	sess.lastUsed = time.Now()
+	t.mu.Unlock()
+}
+
+// expireSessions 关闭空闲超过 sessionTTL 的会话
+func (t *httpTransport) expireSessions() {
+	cutoff := time.Now().Add(-t.sessionTTL)
+	var expired []*httpSession
+	t.mu.Lock()
+	for _, sess := range t.sessions {
+		if sess.active == 0 && sess.lastUsed.Before(cutoff) {
+			expired = append(expired, sess)
+		}
+	}
+	t.mu.Unlock()
+	for _, sess := range expired {
+		t.logger.Info(context.Background(), "Closing idle session", "session", sess.id)
+		t.closeSession(sess)
+	}
+}
+
+// reapSessions 定期回收空闲会话，直到 ctx 取消
+func (t *httpTransport) reapSessions(ctx context.Context) {
+	interval := t.sessionTTL / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.expireSessions()
+		}
+	}
+}
+
+// closeSession 注销会话、关闭其事件流、取消会话中进行中的请求并释放按会话保存的状态
+func (t *httpTransport) closeSession(sess *httpSession) {
+	t.mu.Lock()
+	removed := t.sessions[sess.id] == sess
+	if removed {
+		delete(t.sessions, sess.id)
+	}
+	stream := sess.stream
+	sess.stream = nil
+	onClose := t.onSessionClose
+	t.mu.Unlock()
+	if stream != nil {
+		stream.close()
+	}
+	sess.cancel()
+	if removed && onClose != nil {
+		onClose(sess.id)
+	}
+}
+
+// SetCloseHandler 实现 transport.Transport
+func (t *httpTransport) SetCloseHandler(handler func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onClose = handler
+}
+
+// SetErrorHandler 实现 transport.Transport
+func (t *httpTransport) SetErrorHandler(handler func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onError = handler
+}
+
+// SetMessageHandler 实现 transport.Transport
+func (t *httpTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onMessage = handler
+}
+
+// Handler 返回挂载了指定模式端点的 http.Handler（带认证）
+// mode 为 "http" 时挂载 /mcp，为 "sse" 时挂载 /sse 与 /message
+func (t *httpTransport) Handler(mode string) http.Handler {
+	mux := http.NewServeMux()
+	switch mode {
+	case "sse":
+		mux.HandleFunc("/sse", t.handleSSE)
+		mux.HandleFunc("/message", t.handleSSEMessage)
+	default:
+		mux.HandleFunc("/mcp", t.handleStreamable)
+	}
+	return t.withAuth(mux)
+}
+
+// withAuth 校验 Authorization: Bearer <token>
+func (t *httpTransport) withAuth(next http.Handler) http.Handler {
+	if t.authToken == "" {
+		return next
+	}
+	expected := []byte("Bearer " + t.authToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agentcode-mcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStreamable 处理 Streamable HTTP 端点
+func (t *httpTransport) handleStreamable(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		msg, err := readJSONRPCMessage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// initialize 创建新会话，其余消息必须属于已登记的会话
+		var sess *httpSession
+		if msg.Type == transport.BaseMessageTypeJSONRPCRequestType && msg.JsonRpcRequest.Method == "initialize" {
+			if sess = t.newSession(w); sess == nil {
+				return
+			}
+			w.Header().Set(sessionHeader, sess.id)
+		} else if sess = t.lookupSession(w, r.Header.Get(sessionHeader)); sess == nil {
+			return
+		}
+		defer t.release(sess)
+		// 请求随 HTTP 连接或会话结束而取消
+		ctx, cancel := context.WithCancel(context.WithValue(r.Context(), sessionKey{}, sess.id))
+		defer cancel()
+		stop := context.AfterFunc(sess.ctx, cancel)
+		defer stop()
+
+		if msg.Type != transport.BaseMessageTypeJSONRPCRequestType {
+			t.dispatch(ctx, msg)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		// 请求：等待响应后直接写回；客户端接受 SSE 时，处理期间的通知先以事件流写出
+		result := make(chan []byte, 1)
+		var events chan []byte
+		var notify func([]byte)
+		flusher, canStream := w.(http.Flusher)
+		if canStream && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			events = make(chan []byte, sseBufferSize)
+			notify = func(data []byte) {
+				select {
+				case events <- data:
+				default:
+					t.logger.Warn(context.Background(), "Dropping notification for slow request stream", "session", sess.id)
+				}
+			}
+		}
+		t.dispatchRequest(ctx, sess, msg, func(data []byte) { result <- data }, notify)
+
+		streaming := false
+		writeEvent := func(data []byte) {
+			if !streaming {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				w.WriteHeader(http.StatusOK)
+				streaming = true
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+		for {
+			select {
+			case data := <-events:
+				writeEvent(data)
+				continue
+			case data := <-result:
+				if !streaming {
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write(data)
+					return
+				}
+				// 先写出排在响应之前的通知
+				for len(events) > 0 {
+					writeEvent(<-events)
+				}
+				writeEvent(data)
+			case <-ctx.Done():
+			}
+			return
+		}
+
+	case http.MethodGet:
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+			return
+		}
+		if sess := t.lookupSession(w, r.Header.Get(sessionHeader)); sess != nil {
+			defer t.release(sess)
+			t.serveStream(w, r, sess, nil)
+		}
+
+	case http.MethodDelete:
+		if sess := t.lookupSession(w, r.Header.Get(sessionHeader)); sess != nil {
+			t.release(sess)
+			t.closeSession(sess)
+			w.WriteHeader(http.StatusOK)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSSE 处理旧版 SSE 端点：建立事件流并告知客户端消息提交地址
+func (t *httpTransport) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// 旧版会话的生命周期与事件流相同
+	sess := t.newSession(w)
+	if sess == nil {
+		return
+	}
+	defer t.closeSession(sess)
+	t.serveStream(w, r, sess, func() {
+		fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\n\n", sess.id)
+	})
+}
+
+// handleSSEMessage 处理旧版 SSE 的消息提交，响应通过事件流返回
+func (t *httpTransport) handleSSEMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var stream *sseStream
+	t.mu.Lock()
+	sess := t.sessions[r.URL.Query().Get("sessionId")]
+	if sess != nil {
+		stream = sess.stream
+	}
+	t.mu.Unlock()
+	if stream == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	msg, err := readJSONRPCMessage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 工具调用的生命周期跟随 SSE 会话，而不是这次 POST
+	if msg.Type == transport.BaseMessageTypeJSONRPCRequestType {
+		t.dispatchRequest(sess.ctx, sess, msg, stream.send, nil)
+	} else {
+		t.dispatch(sess.ctx, msg)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveStream 把事件流登记到会话（替换会话原有的流）并持续写出消息，直到客户端断开、会话结束或传输层关闭
+func (t *httpTransport) serveStream(w http.ResponseWriter, r *http.Request, sess *httpSession, onOpen func()) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	stream := &sseStream{
+		id:     sess.id,
+		events: make(chan []byte, sseBufferSize),
+		done:   make(chan struct{}),
+	}
+	t.mu.Lock()
+	if t.sessions[sess.id] != sess {
+		t.mu.Unlock()
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	old := sess.stream
+	sess.stream = stream
+	t.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	defer func() {
+		t.mu.Lock()
+		if sess.stream == stream {
+			sess.stream = nil
+		}
+		t.mu.Unlock()
+		stream.close()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set(sessionHeader, sess.id)
+	w.WriteHeader(http.StatusOK)
+	if onOpen != nil {
+		onOpen()
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case data := <-stream.events:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-stream.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// dispatchRequest 为请求分配内部 ID 并登记响应与通知回调，然后交给 Protocol 处理
+func (t *httpTransport) dispatchRequest(ctx context.Context, sess *httpSession, msg *transport.BaseJsonRpcMessage, respond, notify func([]byte)) {
+	req := *msg.JsonRpcRequest
+	internalID := transport.RequestId(t.nextID.Add(1))
+
+	t.mu.Lock()
+	t.pending[internalID] = &pendingRequest{originalID: req.Id, session: sess, respond: respond, notify: notify}
+	t.mu.Unlock()
+
+	req.Id = internalID
+	t.dispatch(context.WithValue(ctx, requestRouteKey{}, internalID), transport.NewBaseMessageRequest(&req))
+}
+
+// dispatch 将消息交给 Protocol 的消息回调
+func (t *httpTransport) dispatch(ctx context.Context, msg *transport.BaseJsonRpcMessage) {
+	t.mu.Lock()
+	handler := t.onMessage
+	t.mu.Unlock()
+	if handler != nil {
+		handler(ctx, msg)
+	}
+}
+
+// takePending 取出并移除等待中的请求
+func (t *httpTransport) takePending(id transport.RequestId) (*pendingRequest, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("no pending request for id %d", id)
+	}
+	delete(t.pending, id)
+	return p, nil
+}
+
+// sendToRequester 将请求处理期间的通知发给发起请求的会话；请求已结束或会话没有可用的流时丢弃
+func (t *httpTransport) sendToRequester(id transport.RequestId, data []byte) {
+	t.mu.Lock()
+	p := t.pending[id]
+	var stream *sseStream
+	if p != nil && p.notify == nil {
+		stream = p.session.stream
+	}
+	t.mu.Unlock()
+
+	switch {
+	case p == nil:
+	case p.notify != nil:
+		p.notify(data)
+	case stream != nil:
+		if !stream.trySend(data) {
+			t.logger.Warn(context.Background(), "Dropping notification for slow stream", "session", stream.id)
+		}
+	}
+}
+
+// sendToSession 将消息发送到会话的事件流，会话不存在或没有打开的流时丢弃
+func (t *httpTransport) sendToSession(id string, data []byte) {
+	var stream *sseStream
+	t.mu.Lock()
+	if sess := t.sessions[id]; sess != nil {
+		stream = sess.stream
+	}
+	t.mu.Unlock()
+	if stream == nil || !stream.trySend(data) {
+		t.logger.Warn(context.Background(), "Dropping message for session without an open stream", "session", id)
+	}
+}
+
+// broadcast 将服务端通知发送到所有打开的事件流
+func (t *httpTransport) broadcast(data []byte) {
+	t.mu.Lock()
+	streams := make([]*sseStream, 0, len(t.sessions))
+	for _, sess := range t.sessions {
+		if sess.stream != nil {
+			streams = append(streams, sess.stream)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, s := range streams {
+		// 缓冲已满的慢客户端直接丢弃通知，避免阻塞工具调用
+		if !s.trySend(data) {
+			t.logger.Warn(context.Background(), "Dropping notification for slow stream", "session", s.id)
+		}
+	}
+}
+
+// readJSONRPCMessage 解析请求体中的单条 JSON-RPC 消息（不支持批量）
+func readJSONRPCMessage(r *http.Request) (*transport.BaseJsonRpcMessage, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPMessageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) > maxHTTPMessageBytes {
+		return nil, fmt.Errorf("message exceeds %d bytes", maxHTTPMessageBytes)
+	}
+
+	var request transport.BaseJSONRPCRequest
+	if err := json.Unmarshal(body, &request); err == nil {
+		return transport.NewBaseMessageRequest(&request), nil
+	}
+	var notification transport.BaseJSONRPCNotification
+	if err := json.Unmarshal(body, &notification); err == nil {
+		return transport.NewBaseMessageNotification(&notification), nil
+	}
+	var response transport.BaseJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err == nil {
+		return transport.NewBaseMessageResponse(&response), nil
+	}
+	var errResp transport.BaseJSONRPCError
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		return transport.NewBaseMessageError(&errResp), nil
+	}
+	return nil, fmt.Errorf("invalid JSON-RPC message")
+}
+
+// newSessionID 生成随机会话 ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
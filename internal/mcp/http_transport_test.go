@@ -0,0 +1,303 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+	"opencode-go-mcp/internal/log"
+	"opencode-go-mcp/internal/workspace"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// newTestHTTPServer 启动 Streamable HTTP 传输层，消息处理函数模拟 Protocol：
+// tools/call 先用请求的 context 发送一条 progress 通知，再返回响应
+func newTestHTTPServer(t *testing.T) (*httpTransport, string) {
+	t.Helper()
+	tr := newHTTPTransport(log.NewStdLogger("error"), "")
+	return tr, serveTestTransport(t, tr, tr)
+}
+
+// serveTestTransport 在 top（tr 本身或其外层的 extTransport）上安装模拟 Protocol 并启动 HTTP 服务
+func serveTestTransport(t *testing.T, tr *httpTransport, top transport.Transport) string {
+	t.Helper()
+	top.SetMessageHandler(func(ctx context.Context, msg *transport.BaseJsonRpcMessage) {
+		if msg.Type != transport.BaseMessageTypeJSONRPCRequestType {
+			return
+		}
+		req := *msg.JsonRpcRequest
+		go func() {
+			if req.Method == "tools/call" {
+				_ = tr.Send(ctx, transport.NewBaseMessageNotification(&transport.BaseJSONRPCNotification{
+					Jsonrpc: "2.0",
+					Method:  "notifications/progress",
+					Params:  json.RawMessage(fmt.Sprintf(`{"progressToken":1,"progress":1,"message":%s}`, req.Params)),
+				}))
+			}
+			_ = tr.Send(ctx, transport.NewBaseMessageResponse(&transport.BaseJSONRPCResponse{
+				Jsonrpc: "2.0",
+				Id:      req.Id,
+				Result:  json.RawMessage(`{}`),
+			}))
+		}()
+	})
+	srv := httptest.NewServer(tr.Handler("http"))
+	t.Cleanup(func() {
+		tr.Close()
+		srv.Close()
+	})
+	return srv.URL + "/mcp"
+}
+
+func doMCP(t *testing.T, method, url, session, accept, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// initSession 发送 initialize 并返回服务端分配的会话 ID
+func initSession(t *testing.T, url string) string {
+	t.Helper()
+	resp := doMCP(t, http.MethodPost, url, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	resp.Body.Close()
+	id := resp.Header.Get(sessionHeader)
+	if resp.StatusCode != http.StatusOK || id == "" {
+		t.Fatalf("initialize: status %d, session %q", resp.StatusCode, id)
+	}
+	return id
+}
+
+// openStream 打开会话的 GET 事件流，返回逐条 data 的 channel
+func openStream(t *testing.T, url, session string) <-chan string {
+	t.Helper()
+	resp := doMCP(t, http.MethodGet, url, session, "text/event-stream", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET stream: status %d", resp.StatusCode)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return readEvents(resp.Body)
+}
+
+func readEvents(r io.Reader) <-chan string {
+	events := make(chan string, 16)
+	go func() {
+		defer close(events)
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan string) string {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return ""
+	}
+}
+
+func toolCall(id int, tag string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":%q}`, id, tag)
+}
+
+func TestHTTPTransport_ProgressRouting(t *testing.T) {
+	tr, url := newTestHTTPServer(t)
+	sessA := initSession(t, url)
+	sessB := initSession(t, url)
+	streamB := openStream(t, url, sessB)
+
+	// 1. 接受 SSE 的 POST：通知与响应都写入该请求的响应流
+	resp := doMCP(t, http.MethodPost, url, sessA, "application/json, text/event-stream", toolCall(2, "a-sse"))
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := readEvents(resp.Body)
+	if e := nextEvent(t, events); !strings.Contains(e, "notifications/progress") || !strings.Contains(e, "a-sse") {
+		t.Errorf("first event = %s, want progress", e)
+	}
+	if e := nextEvent(t, events); !strings.Contains(e, `"id":2`) {
+		t.Errorf("second event = %s, want response", e)
+	}
+	resp.Body.Close()
+
+	// 2. 只接受 JSON 且没有 GET 流：响应照常返回，通知不会泄露给其他会话
+	resp = doMCP(t, http.MethodPost, url, sessA, "application/json", toolCall(3, "a-json"))
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"id":3`) {
+		t.Errorf("unexpected response: %s", body)
+	}
+
+	// 3. 只接受 JSON 但会话有 GET 流：通知写入本会话的流
+	streamA := openStream(t, url, sessA)
+	resp = doMCP(t, http.MethodPost, url, sessA, "application/json", toolCall(4, "a-stream"))
+	resp.Body.Close()
+	if e := nextEvent(t, streamA); !strings.Contains(e, "a-stream") {
+		t.Errorf("session stream event = %s, want a-stream progress", e)
+	}
+
+	// 4. 与请求无关的通知广播到所有会话；B 收到的第一条就是它，说明 A 的输出没有发给 B
+	_ = tr.Send(context.Background(), transport.NewBaseMessageNotification(&transport.BaseJSONRPCNotification{
+		Jsonrpc: "2.0",
+		Method:  "notifications/tools/list_changed",
+	}))
+	if e := nextEvent(t, streamB); !strings.Contains(e, "list_changed") {
+		t.Errorf("session B received %s, want only the broadcast", e)
+	}
+	if e := nextEvent(t, streamA); !strings.Contains(e, "list_changed") {
+		t.Errorf("session A stream event = %s, want broadcast", e)
+	}
+}
+
+func TestHTTPTransport_Sessions(t *testing.T) {
+	_, url := newTestHTTPServer(t)
+	sess := initSession(t, url)
+
+	for _, tt := range []struct {
+		method, session string
+		want            int
+	}{
+		{http.MethodPost, "", http.StatusBadRequest},
+		{http.MethodPost, "client-chosen", http.StatusNotFound},
+		{http.MethodGet, "client-chosen", http.StatusNotFound},
+		{http.MethodDelete, "client-chosen", http.StatusNotFound},
+	} {
+		resp := doMCP(t, tt.method, url, tt.session, "application/json, text/event-stream", toolCall(2, "x"))
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s with session %q: status %d, want %d", tt.method, tt.session, resp.StatusCode, tt.want)
+		}
+	}
+
+	// DELETE 关闭会话的事件流，之后该 ID 不再可用
+	stream := openStream(t, url, sess)
+	resp := doMCP(t, http.MethodDelete, url, sess, "application/json", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE: status %d", resp.StatusCode)
+	}
+	select {
+	case _, ok := <-stream:
+		if ok {
+			t.Error("unexpected event after DELETE")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stream still open after DELETE")
+	}
+	resp = doMCP(t, http.MethodPost, url, sess, "application/json", toolCall(3, "x"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("POST after DELETE: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPTransport_ClientRootsPerSession(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "app"), 0755)
+	reg, err := workspace.NewRegistry(&config.Config{RootDir: root, MaxFileBytes: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewStdLogger("error")
+	tr := newHTTPTransport(logger, "")
+	ext := newExtTransport(tr, reg, logger)
+	tr.onSessionClose = ext.forgetClient
+	url := serveTestTransport(t, tr, ext)
+
+	resp := doMCP(t, http.MethodPost, url, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{"roots":{}}}}`)
+	resp.Body.Close()
+	sessA := resp.Header.Get(sessionHeader)
+	sessB := initSession(t, url)
+	streamA := openStream(t, url, sessA)
+
+	// initialized 之后 roots/list 只发到 A 的事件流
+	resp = doMCP(t, http.MethodPost, url, sessA, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	resp.Body.Close()
+	var req struct {
+		ID     int64  `json:"id"`
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal([]byte(nextEvent(t, streamA)), &req); err != nil || req.Method != "roots/list" {
+		t.Fatalf("expected roots/list on session A, got %+v, %v", req, err)
+	}
+
+	result := func(dir string) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"roots":[{"uri":"file://%s","name":"app"}]}}`, req.ID, filepath.ToSlash(dir))
+	}
+	// B 冒充 A 应答会被忽略；A 的应答只对 A 生效
+	resp = doMCP(t, http.MethodPost, url, sessB, "application/json", result(filepath.Join(root, "app")))
+	resp.Body.Close()
+	if list := reg.List(sessA); len(list) != 1 {
+		t.Fatalf("response from session B was applied to A: %+v", list)
+	}
+	resp = doMCP(t, http.MethodPost, url, sessA, "application/json", result(filepath.Join(root, "app")))
+	resp.Body.Close()
+	if list := reg.List(sessA); len(list) != 2 || list[1].Name != "app" {
+		t.Fatalf("session A roots = %+v", list)
+	}
+	if list := reg.List(sessB); len(list) != 1 {
+		t.Errorf("session B sees A's roots: %+v", list)
+	}
+
+	// 会话结束后其 root 被删除
+	resp = doMCP(t, http.MethodDelete, url, sessA, "application/json", "")
+	resp.Body.Close()
+	if list := reg.List(sessA); len(list) != 1 {
+		t.Errorf("roots kept after DELETE: %+v", list)
+	}
+}
+
+func TestHTTPTransport_SessionLimits(t *testing.T) {
+	tr, url := newTestHTTPServer(t)
+	tr.maxSessions = 2
+	sessA := initSession(t, url)
+	initSession(t, url)
+
+	// 达到上限后拒绝新会话
+	resp := doMCP(t, http.MethodPost, url, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("initialize over limit: status %d, want 503", resp.StatusCode)
+	}
+
+	// 打开事件流的会话不会过期，空闲的会话过期后腾出名额
+	streamA := openStream(t, url, sessA)
+	tr.sessionTTL = 0
+	tr.expireSessions()
+	initSession(t, url)
+	resp = doMCP(t, http.MethodPost, url, sessA, "application/json", toolCall(2, "still-alive"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("session with open stream expired: status %d", resp.StatusCode)
+	}
+	if e := nextEvent(t, streamA); !strings.Contains(e, "still-alive") {
+		t.Errorf("stream event = %s, want still-alive progress", e)
+	}
+}
@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// 本文件实现 MCP progress 通知（mcp-golang 不向工具处理函数暴露 _meta.progressToken）：
+//  1. extTransport 在 tools/call 请求交给 Protocol 之前解析 params._meta.progressToken，
+//     并把 progressReporter 放入请求的 context（Protocol 会将该 context 传给工具处理函数）。
+//  2. outputForwarder 汇总命令输出，每 progressInterval 或累计 progressChunkBytes 字节发送一次
+//     notifications/progress，progress 为已输出的总字节数，message 为新增的输出文本。
+//  3. 客户端未提供 progressToken 时不发送任何通知。
+//  4. 通知使用请求的 context 发送，HTTP 传输据此只发给发起请求的会话（见 http_transport.go）。
+
+const (
+	progressInterval   = 250 * time.Millisecond
+	progressChunkBytes = 8 << 10
+)
+
+type progressKey struct{}
+
+// progressReporter 向客户端发送与某个请求关联的 progress 通知
+type progressReporter struct {
+	t     *extTransport
+	token json.RawMessage
+}
+
+// withProgress 若 tools/call 请求带有 progressToken，返回携带 progressReporter 的 context
+func (t *extTransport) withProgress(ctx context.Context, message *transport.BaseJsonRpcMessage) context.Context {
+	if message.Type != transport.BaseMessageTypeJSONRPCRequestType || message.JsonRpcRequest.Method != "tools/call" {
+		return ctx
+	}
+	var params struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(message.JsonRpcRequest.Params, &params); err != nil {
+		return ctx
+	}
+	token := params.Meta.ProgressToken
+	if len(token) == 0 || string(token) == "null" {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progressReporter{t: t, token: token})
+}
+
+// progressFromContext 返回请求关联的 progressReporter，没有时返回 nil
+func progressFromContext(ctx context.Context) *progressReporter {
+	p, _ := ctx.Value(progressKey{}).(*progressReporter)
+	return p
+}
+
+// Notify 发送一条 notifications/progress
+func (p *progressReporter) Notify(ctx context.Context, progress float64, message string) error {
+	params, err := json.Marshal(map[string]any{
+		"progressToken": p.token,
+		"progress":      progress,
+		"message":       message,
+	})
+	if err != nil {
+		return err
+	}
+	return p.t.Transport.Send(ctx, transport.NewBaseMessageNotification(&transport.BaseJSONRPCNotification{
+		Jsonrpc: "2.0",
+		Method:  "notifications/progress",
+		Params:  params,
+	}))
+}
+
+// outputForwarder 将命令输出批量转成 progress 通知
+type outputForwarder struct {
+	ctx      context.Context
+	reporter *progressReporter
+
+	mu    sync.Mutex
+	buf   []byte
+	total int64
+	timer *time.Timer
+}
+
+// newOutputForwarder 请求没有 progressToken 时返回 nil
+func newOutputForwarder(ctx context.Context) *outputForwarder {
+	reporter := progressFromContext(ctx)
+	if reporter == nil {
+		return nil
+	}
+	return &outputForwarder{ctx: ctx, reporter: reporter}
+}
+
+// OnOutput 满足 workspace.OutputFunc
+func (f *outputForwarder) OnOutput(stream string, chunk []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buf = append(f.buf, chunk...)
+	f.total += int64(len(chunk))
+	if len(f.buf) >= progressChunkBytes {
+		f.flushLocked()
+		return
+	}
+	if f.timer == nil {
+		f.timer = time.AfterFunc(progressInterval, f.Flush)
+	}
+}
+
+// Flush 立即发送尚未发送的输出（命令结束时调用）
+func (f *outputForwarder) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushLocked()
+}
+
+func (f *outputForwarder) flushLocked() {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	// 末尾不完整的 UTF-8 字符留到下一次发送
+	n := len(f.buf)
+	for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(f.buf[i]) {
+			if !utf8.FullRune(f.buf[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+	if n == 0 {
+		return
+	}
+	sent := f.total - int64(len(f.buf)-n)
+	_ = f.reporter.Notify(f.ctx, float64(sent), string(f.buf[:n]))
+	f.buf = append(f.buf[:0], f.buf[n:]...)
+}
@@ -0,0 +1,164 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"opencode-go-mcp/internal/workspace"
+
+	mcp "github.com/metoro-io/mcp-golang"
+)
+
+// job_wait 默认与最长等待时间；job_kill 等待进程退出的时间
+const (
+	defaultJobWait = 30 * time.Second
+	maxJobWait     = 10 * time.Minute
+	jobKillGrace   = 5 * time.Second
+)
+
+// registerJobTools 注册后台任务工具（job_start / job_status / job_output / job_wait / job_kill）
+// 任务按客户端会话与工作区隔离：只能查看和操作本会话在同一工作区中启动的任务
+func registerJobTools(srv *toolSet, workspaces *workspace.Registry, jobs *workspace.JobManager, logs *execLogOwners, onActivity func()) error {
+	// workspace.job_start
+	if err := srv.RegisterTool("workspace.job_start", "Start a long-running command (dev server, watch-mode tests) in the background; same command policy as secure_exec", func(ctx context.Context, args JobStartArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		client := clientID(ctx)
+		ws, wsName, err := workspaces.Resolve(client, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("job_start: %w", err)
+		}
+		info, err := jobs.Start(ws, workspace.JobScope{Client: client, Workspace: wsName}, args.Command, args.Args)
+		if err != nil {
+			return nil, fmt.Errorf("job_start: %w", err)
+		}
+		logs.add(info.LogID, client, wsName)
+		return jobResponse(info)
+	}); err != nil {
+		return fmt.Errorf("failed to register job_start: %w", err)
+	}
+
+	// workspace.job_status
+	if err := srv.RegisterTool("workspace.job_status", "Get the state of a background job, or list all jobs when jobId is empty", func(ctx context.Context, args JobStatusArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		scope, err := jobScope(ctx, workspaces, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("job_status: %w", err)
+		}
+		if args.JobID == "" {
+			return jobResponse(jobs.List(scope))
+		}
+		info, err := jobs.Status(scope, args.JobID)
+		if err != nil {
+			return nil, fmt.Errorf("job_status: %w", err)
+		}
+		return jobResponse(info)
+	}); err != nil {
+		return fmt.Errorf("failed to register job_status: %w", err)
+	}
+
+	// workspace.job_output
+	if err := srv.RegisterTool("workspace.job_output", "Read output of a background job by byte offset (works while the job is running)", func(ctx context.Context, args JobOutputArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		scope, err := jobScope(ctx, workspaces, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("job_output: %w", err)
+		}
+		limit := args.Limit
+		if limit <= 0 {
+			limit = defaultExecLogChunk
+		}
+		limit = min(limit, maxExecLogChunk)
+		chunk, info, err := jobs.Output(scope, args.JobID, args.Offset, limit)
+		if err != nil {
+			return nil, fmt.Errorf("job_output: %w", err)
+		}
+		return jobResponse(struct {
+			*workspace.ExecLogChunk
+			State string `json:"state"`
+		}{chunk, info.State})
+	}); err != nil {
+		return fmt.Errorf("failed to register job_output: %w", err)
+	}
+
+	// workspace.job_wait
+	if err := srv.RegisterTool("workspace.job_wait", "Wait until a background job exits or the timeout elapses, then return its state", func(ctx context.Context, args JobWaitArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		scope, err := jobScope(ctx, workspaces, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("job_wait: %w", err)
+		}
+		timeout := defaultJobWait
+		if args.TimeoutSeconds > 0 {
+			timeout = min(time.Duration(args.TimeoutSeconds)*time.Second, maxJobWait)
+		}
+		info, err := jobs.Wait(ctx, scope, args.JobID, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("job_wait: %w", err)
+		}
+		return jobResponse(info)
+	}); err != nil {
+		return fmt.Errorf("failed to register job_wait: %w", err)
+	}
+
+	// workspace.job_kill
+	if err := srv.RegisterTool("workspace.job_kill", "Terminate a background job", func(ctx context.Context, args JobKillArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		scope, err := jobScope(ctx, workspaces, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("job_kill: %w", err)
+		}
+		info, err := jobs.Kill(scope, args.JobID, jobKillGrace)
+		if err != nil {
+			return nil, fmt.Errorf("job_kill: %w", err)
+		}
+		return jobResponse(info)
+	}); err != nil {
+		return fmt.Errorf("failed to register job_kill: %w", err)
+	}
+
+	return nil
+}
+
+// jobScope 返回调用方的任务归属：当前客户端会话与工作区名称（name 为空时为默认工作区）
+func jobScope(ctx context.Context, workspaces *workspace.Registry, name string) (workspace.JobScope, error) {
+	client := clientID(ctx)
+	_, wsName, err := workspaces.Resolve(client, name)
+	return workspace.JobScope{Client: client, Workspace: wsName}, err
+}
+
+// jobResponse 以缩进 JSON 返回任务信息
+func jobResponse(v any) (*mcp.ToolResponse, error) {
+	jsonBytes, _ := json.MarshalIndent(v, "", "  ")
+	return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+}
+
+type JobStartArgs struct {
+	Command   string   `json:"command" jsonschema:"required,description=Command to run in the background"`
+	Args      []string `json:"args" jsonschema:"description=Command arguments"`
+	Workspace string   `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type JobStatusArgs struct {
+	JobID     string `json:"jobId" jsonschema:"description=Job ID from job_start (empty lists all jobs in the workspace)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace the job was started in (default workspace if empty)"`
+}
+
+type JobOutputArgs struct {
+	JobID     string `json:"jobId" jsonschema:"required,description=Job ID from job_start"`
+	Offset    int64  `json:"offset" jsonschema:"description=Byte offset to start reading from (use next_offset of the previous call)"`
+	Limit     int64  `json:"limit" jsonschema:"description=Maximum bytes to read (default 65536, max 1048576)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace the job was started in (default workspace if empty)"`
+}
+
+type JobWaitArgs struct {
+	JobID          string `json:"jobId" jsonschema:"required,description=Job ID from job_start"`
+	TimeoutSeconds int64  `json:"timeoutSeconds" jsonschema:"description=Maximum seconds to wait (default 30, max 600)"`
+	Workspace      string `json:"workspace" jsonschema:"description=Workspace the job was started in (default workspace if empty)"`
+}
+
+type JobKillArgs struct {
+	JobID     string `json:"jobId" jsonschema:"required,description=Job ID from job_start"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace the job was started in (default workspace if empty)"`
+}
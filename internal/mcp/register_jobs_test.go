@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestJobTools_ScopedToSession(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := testReloadConfig(t)
+	cfg.AllowedBuildCommands = []string{"sleep"}
+	s, url := newReloadTestServer(t, cfg)
+	defer s.shutdownJobs()
+	sessA := initSession(t, url)
+	sessB := initSession(t, url)
+
+	out := callTool(t, url, sessA, "workspace.job_start", `{"command":"sleep","args":["30"]}`)
+	m := regexp.MustCompile(`job-[0-9a-f]{8}`).FindString(out)
+	if m == "" {
+		t.Fatalf("no job id in job_start output: %s", out)
+	}
+
+	// B 既列不出也终止不了 A 的任务
+	if got := callTool(t, url, sessB, "workspace.job_status", `{}`); strings.Contains(got, m) {
+		t.Errorf("session B lists A's job: %s", got)
+	}
+	if got := callTool(t, url, sessB, "workspace.job_kill", fmt.Sprintf(`{"jobId":%q}`, m)); !strings.Contains(got, "job not found") {
+		t.Errorf("session B killed A's job: %s", got)
+	}
+	if got := callTool(t, url, sessA, "workspace.job_status", `{}`); !strings.Contains(got, m) {
+		t.Errorf("session A cannot list its job: %s", got)
+	}
+	if got := callTool(t, url, sessA, "workspace.job_kill", fmt.Sprintf(`{"jobId":%q}`, m)); !strings.Contains(got, `\"state\": \"killed\"`) {
+		t.Errorf("session A cannot kill its job: %s", got)
+	}
+}
@@ -14,50 +14,59 @@ import (
 )
 
 // registerTools 注册所有 MCP 工具（本地模式，无 Project 参数）
-func registerTools(srv *mcp.Server, ws workspace.Workspace, logger log.Logger, onActivity func()) error {
+func registerTools(srv *toolSet, workspaces *workspace.Registry, logs *execLogOwners, logger log.Logger, onActivity func()) error {
 	// workspace.read_file tool
-	if err := srv.RegisterTool("workspace.read_file", "Read a file from local workspace", func(args ReadFileArgs) (*mcp.ToolResponse, error) {
+	if err := srv.RegisterTool("workspace.read_file", "Read a file from local workspace", func(ctx context.Context, args ReadFileArgs) (*mcp.ToolResponse, error) {
 		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("read_file: %w", err)
+		}
 		maxBytes := args.MaxBytes
 		if maxBytes <= 0 {
 			maxBytes = 1024 * 1024
 		}
-		data, err := ws.ReadFile(context.Background(), args.Path, maxBytes)
+		data, etag, err := ws.ReadFileHashed(context.Background(), args.Path, maxBytes)
 		if err != nil {
 			return nil, fmt.Errorf("read_file: %w", err)
 		}
 		content := string(data)
 		truncated := int64(len(data)) >= maxBytes
-		result := fmt.Sprintf("File: %s\nTruncated: %v\n\n%s", args.Path, truncated, content)
+		result := fmt.Sprintf("File: %s\nETag: %s\nTruncated: %v\n\n%s", args.Path, etag, truncated, content)
 		return mcp.NewToolResponse(mcp.NewTextContent(result)), nil
 	}); err != nil {
 		return fmt.Errorf("failed to register read_file: %w", err)
 	}
 
 	// workspace.write_file tool
-	if err := srv.RegisterTool("workspace.write_file", "Write content to a file", func(args WriteFileArgs) (*mcp.ToolResponse, error) {
+	if err := srv.RegisterTool("workspace.write_file", "Write content to a file", func(ctx context.Context, args WriteFileArgs) (*mcp.ToolResponse, error) {
 		onActivity()
-		err := ws.WriteFile(context.Background(), args.Path, []byte(args.Content), args.AllowCreate)
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("write_file: %w", err)
+		}
+		data := []byte(args.Content)
+		err = ws.WriteFile(context.Background(), args.Path, data, args.AllowCreate, args.IfMatch)
 		if err != nil {
 			return nil, fmt.Errorf("write_file: %w", err)
 		}
-		return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Wrote: %s", args.Path))), nil
+		return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Wrote: %s\nETag: %s", args.Path, workspace.ContentHash(data)))), nil
 	}); err != nil {
 		return fmt.Errorf("failed to register write_file: %w", err)
 	}
 
 	// workspace.health tool
-	if err := srv.RegisterTool("workspace.health", "Health check with tool list", func(args HealthArgs) (*mcp.ToolResponse, error) {
+	if err := srv.RegisterTool("workspace.health", "Health check with tool list", func(ctx context.Context, args HealthArgs) (*mcp.ToolResponse, error) {
 		onActivity()
-		tools := []string{
-			"workspace.read_file", "workspace.write_file", "workspace.inspect_workspace",
-			"workspace.read_code_fragment", "workspace.apply_unified_diff", "workspace.search_and_replace",
-			"workspace.secure_exec", "workspace.health",
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("health: %w", err)
 		}
 		result := map[string]interface{}{
-			"version": "0.3.0-local",
-			"tools":   tools,
-			"status":  "ok",
+			"version":     "0.3.0-local",
+			"tools":       srv.Enabled(),
+			"status":      "ok",
+			"ignoreRules": ws.IgnoreRules(),
 		}
 		jsonResult, _ := json.Marshal(result)
 		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonResult))), nil
@@ -65,9 +74,22 @@ func registerTools(srv *mcp.Server, ws workspace.Workspace, logger log.Logger, o
 		return fmt.Errorf("failed to register health: %w", err)
 	}
 
+	// workspace.list_workspaces tool
+	if err := srv.RegisterTool("workspace.list_workspaces", "List named workspaces (configured and client-provided roots)", func(ctx context.Context, args ListWorkspacesArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		jsonBytes, _ := json.MarshalIndent(workspaces.List(clientID(ctx)), "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register list_workspaces: %w", err)
+	}
+
 	// Eyes: workspace.inspect_workspace
-	if err := srv.RegisterTool("workspace.inspect_workspace", "Inspect workspace directory structure", func(args InspectWorkspaceArgs) (*mcp.ToolResponse, error) {
+	if err := srv.RegisterTool("workspace.inspect_workspace", "Inspect workspace directory structure", func(ctx context.Context, args InspectWorkspaceArgs) (*mcp.ToolResponse, error) {
 		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("inspect_workspace: %w", err)
+		}
 		maxDepth := args.MaxDepth
 		if maxDepth <= 0 {
 			maxDepth = 2
@@ -81,45 +103,52 @@ func registerTools(srv *mcp.Server, ws workspace.Workspace, logger log.Logger, o
 		if !ok {
 			return nil, fmt.Errorf("workspace does not support InspectWorkspace")
 		}
-		nodes, err := osw.InspectWorkspace(context.Background(), relPath, maxDepth)
-		if err != nil {
-			return nil, fmt.Errorf("inspect_workspace: %w", err)
-		}
 
-		type SerializableNode struct {
-			Path    string `json:"path"`
-			IsDir   bool   `json:"is_dir"`
-			Size    int64  `json:"size"`
-			ModTime string `json:"mod_time"`
-		}
-
-		result := make([]SerializableNode, len(nodes))
-		for i, n := range nodes {
-			result[i] = SerializableNode{
-				Path:    n.Path,
-				IsDir:   n.IsDir,
-				Size:    n.Size,
-				ModTime: n.ModTime.Format(time.RFC3339),
+		switch args.Format {
+		case "", "flat":
+			nodes, err := osw.InspectWorkspace(context.Background(), relPath, maxDepth)
+			if err != nil {
+				return nil, fmt.Errorf("inspect_workspace: %w", err)
+			}
+			result := make([]*SerializableNode, len(nodes))
+			for i, n := range nodes {
+				result[i] = toSerializableNode(n)
+			}
+			jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+			return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+		case "tree", "ascii":
+			root, err := osw.InspectWorkspaceTree(context.Background(), relPath, maxDepth)
+			if err != nil {
+				return nil, fmt.Errorf("inspect_workspace: %w", err)
 			}
+			if args.Format == "ascii" {
+				return mcp.NewToolResponse(mcp.NewTextContent(workspace.RenderTree(root))), nil
+			}
+			jsonBytes, _ := json.Marshal(toSerializableNode(root))
+			return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+		default:
+			return nil, fmt.Errorf("inspect_workspace: invalid format %q (want flat, tree or ascii)", args.Format)
 		}
-		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
-		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
 	}); err != nil {
 		return fmt.Errorf("failed to register inspect_workspace: %w", err)
 	}
 
 	// Eyes: workspace.read_code_fragment
-	if err := srv.RegisterTool("workspace.read_code_fragment", "Read a code fragment by line range", func(args ReadCodeFragmentArgs) (*mcp.ToolResponse, error) {
+	if err := srv.RegisterTool("workspace.read_code_fragment", "Read a code fragment by line range", func(ctx context.Context, args ReadCodeFragmentArgs) (*mcp.ToolResponse, error) {
 		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("read_code_fragment: %w", err)
+		}
 		osw, ok := ws.(*workspace.OSWorkspace)
 		if !ok {
 			return nil, fmt.Errorf("workspace does not support ReadCodeFragment")
 		}
-		lines, truncated, err := osw.ReadCodeFragment(context.Background(), args.Path, args.StartLine, args.EndLine)
+		lines, truncated, etag, err := osw.ReadCodeFragmentHashed(context.Background(), args.Path, args.StartLine, args.EndLine)
 		if err != nil {
 			return nil, fmt.Errorf("read_code_fragment: %w", err)
 		}
-		content := strings.Join(lines, "\n")
+		content := fmt.Sprintf("ETag: %s\n\n", etag) + strings.Join(lines, "\n")
 		if truncated {
 			content += "\n... [TRUNCATED] ..."
 		}
@@ -128,95 +157,680 @@ func registerTools(srv *mcp.Server, ws workspace.Workspace, logger log.Logger, o
 		return fmt.Errorf("failed to register read_code_fragment: %w", err)
 	}
 
-	// Hands: workspace.apply_unified_diff
-	if err := srv.RegisterTool("workspace.apply_unified_diff", "Apply a unified diff patch", func(args ApplyUnifiedDiffArgs) (*mcp.ToolResponse, error) {
+	// Eyes: workspace.grep
+	if err := srv.RegisterTool("workspace.grep", "Search file contents by regex or literal string", func(ctx context.Context, args GrepArgs) (*mcp.ToolResponse, error) {
 		onActivity()
-		osw, ok := ws.(*workspace.OSWorkspace)
-		if !ok {
-			return nil, fmt.Errorf("workspace does not support ApplyUnifiedDiff")
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("grep: %w", err)
+		}
+		result, err := ws.Grep(context.Background(), workspace.GrepOptions{
+			Pattern:      args.Pattern,
+			Path:         args.Path,
+			Literal:      args.Literal,
+			IgnoreCase:   args.IgnoreCase,
+			Include:      args.Include,
+			Exclude:      args.Exclude,
+			ContextLines: args.ContextLines,
+			MaxResults:   args.MaxResults,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("grep: %w", err)
 		}
-		applied, err := osw.ApplyUnifiedDiff(context.Background(), args.DiffText, args.DryRun)
+		return mcp.NewToolResponse(mcp.NewTextContent(formatGrepResult(result))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register grep: %w", err)
+	}
+
+	// Eyes: workspace.find_files
+	if err := srv.RegisterTool("workspace.find_files", "Find files and directories by glob pattern (supports **)", func(ctx context.Context, args FindFilesArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("find_files: %w", err)
+		}
+		opts := workspace.FindOptions{
+			Pattern: args.Pattern,
+			Path:    args.Path,
+			Type:    args.Type,
+			MinSize: args.MinSize,
+			MaxSize: args.MaxSize,
+			SortBy:  args.SortBy,
+			Cursor:  args.Cursor,
+			Limit:   args.Limit,
+		}
+		if opts.ModifiedAfter, err = parseOptionalTime(args.ModifiedAfter); err != nil {
+			return nil, fmt.Errorf("find_files: invalid modifiedAfter: %w", err)
+		}
+		if opts.ModifiedBefore, err = parseOptionalTime(args.ModifiedBefore); err != nil {
+			return nil, fmt.Errorf("find_files: invalid modifiedBefore: %w", err)
+		}
+
+		result, err := ws.FindFiles(context.Background(), opts)
 		if err != nil {
+			return nil, fmt.Errorf("find_files: %w", err)
+		}
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register find_files: %w", err)
+	}
+
+	// Hands: workspace.apply_unified_diff
+	if err := srv.RegisterTool("workspace.apply_unified_diff", "Apply a unified diff patch with context verification, offset search and fuzz like GNU patch; reports per-hunk results and, on failure, the expected vs actual lines", func(ctx context.Context, args ApplyUnifiedDiffArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("apply_unified_diff: %w", err)
+		}
+		opts := workspace.PatchOptions{
+			DryRun:       args.DryRun,
+			Fuzz:         args.Fuzz,
+			MaxOffset:    args.MaxOffset,
+			WriteRejects: args.WriteRejects,
+			IfMatch:      args.IfMatch,
+		}
+		// 0 表示默认值：fuzz 默认 2，偏移不限制；负数表示关闭
+		switch {
+		case args.Fuzz == 0:
+			opts.Fuzz = workspace.DefaultPatchFuzz
+		case args.Fuzz < 0:
+			opts.Fuzz = 0
+		}
+		switch {
+		case args.MaxOffset == 0:
+			opts.MaxOffset = -1
+		case args.MaxOffset < 0:
+			opts.MaxOffset = 0
+		}
+		result, err := ws.ApplyPatch(context.Background(), args.DiffText, opts)
+		if result == nil {
 			return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s", err.Error()))), nil
 		}
-		msg := fmt.Sprintf("Applied to %d files: %v", len(applied), applied)
-		if args.DryRun {
-			msg = fmt.Sprintf("Dry-run: patch would be applied to %d files: %v", len(applied), applied)
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s\n\n%s", err.Error(), jsonBytes))), nil
 		}
-		return mcp.NewToolResponse(mcp.NewTextContent(msg)), nil
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
 	}); err != nil {
 		return fmt.Errorf("failed to register apply_unified_diff: %w", err)
 	}
 
 	// Hands: workspace.search_and_replace
-	if err := srv.RegisterTool("workspace.search_and_replace", "Search and replace exact string", func(args SearchAndReplaceArgs) (*mcp.ToolResponse, error) {
+	if err := srv.RegisterTool("workspace.search_and_replace", "Search and replace in one file (path) or in all files matching a glob: literal or regex (with $1 capture-group substitution), an ordered list of edits applied atomically, or only the Nth occurrence; dryRun reports per-match line previews", func(ctx context.Context, args SearchAndReplaceArgs) (*mcp.ToolResponse, error) {
 		onActivity()
-		osw, ok := ws.(*workspace.OSWorkspace)
-		if !ok {
-			return nil, fmt.Errorf("workspace does not support SearchAndReplace")
-		}
-		actual, err := osw.SearchAndReplace(context.Background(), args.Path, args.Old, args.New, args.ExpectedOccurrences)
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
 		if err != nil {
+			return nil, fmt.Errorf("search_and_replace: %w", err)
+		}
+		opts := workspace.ReplaceOptions{
+			Path:    args.Path,
+			Glob:    args.Glob,
+			DryRun:  args.DryRun,
+			IfMatch: args.IfMatch,
+		}
+		// old/new 是只有一组替换时的简写，与 edits 互斥
+		switch {
+		case len(args.Edits) > 0 && args.Old != "":
+			return mcp.NewToolResponse(mcp.NewTextContent("Error: use either old/new or edits, not both")), nil
+		case len(args.Edits) > 0:
+			for _, e := range args.Edits {
+				opts.Edits = append(opts.Edits, workspace.ReplaceEdit(e))
+			}
+		default:
+			opts.Edits = []workspace.ReplaceEdit{{
+				Old:                 args.Old,
+				New:                 args.New,
+				Regex:               args.Regex,
+				ExpectedOccurrences: args.ExpectedOccurrences,
+				Occurrence:          args.Occurrence,
+			}}
+		}
+		result, err := ws.SearchAndReplace(context.Background(), opts)
+		if result == nil {
 			return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s", err.Error()))), nil
 		}
-		msg := fmt.Sprintf("Replaced %d occurrences in %s", actual, args.Path)
-		if args.ExpectedOccurrences == 0 {
-			msg = fmt.Sprintf("Found %d occurrences (dry-run, no changes)", actual)
+		jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s\n\n%s", err.Error(), jsonBytes))), nil
 		}
-		return mcp.NewToolResponse(mcp.NewTextContent(msg)), nil
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
 	}); err != nil {
 		return fmt.Errorf("failed to register search_and_replace: %w", err)
 	}
 
-	// Shield: workspace.secure_exec
-	if err := srv.RegisterTool("workspace.secure_exec", "Execute a command securely with timeout", func(args SecuredExecArgs) (*mcp.ToolResponse, error) {
+	// Hands: workspace.replace_lines
+	if err := srv.RegisterTool("workspace.replace_lines", "Replace lines startLine..endLine (1-based, inclusive, as in read_code_fragment) with new text, or delete them when text is empty; returns the new line numbers of the edited region", func(ctx context.Context, args ReplaceLinesArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("replace_lines: %w", err)
+		}
+		result, err := ws.ReplaceLines(context.Background(), args.Path, args.StartLine, args.EndLine, args.Text, workspace.LineEditOptions{
+			Expected: args.Expected,
+			IfMatch:  args.IfMatch,
+			DryRun:   args.DryRun,
+		})
+		return lineEditResponse(result, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register replace_lines: %w", err)
+	}
+
+	// Hands: workspace.insert_lines
+	if err := srv.RegisterTool("workspace.insert_lines", "Insert text after line afterLine (0 inserts at the top of the file); returns the line numbers of the inserted lines", func(ctx context.Context, args InsertLinesArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("insert_lines: %w", err)
+		}
+		result, err := ws.InsertLines(context.Background(), args.Path, args.AfterLine, args.Text, workspace.LineEditOptions{
+			Expected: args.Expected,
+			IfMatch:  args.IfMatch,
+			DryRun:   args.DryRun,
+		})
+		return lineEditResponse(result, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register insert_lines: %w", err)
+	}
+
+	// Hands: workspace.make_dir
+	if err := srv.RegisterTool("workspace.make_dir", "Create a directory and any missing parents (recorded in the edit history; an existing directory is left unchanged)", func(ctx context.Context, args MakeDirArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("make_dir: %w", err)
+		}
+		result, err := ws.MakeDir(context.Background(), args.Path)
+		return fsResponse(result, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register make_dir: %w", err)
+	}
+
+	// Hands: workspace.move
+	if err := srv.RegisterTool("workspace.move", "Move or rename a file or directory, creating missing parent directories of the destination; undoable with workspace.undo", func(ctx context.Context, args TransferArgs) (*mcp.ToolResponse, error) {
 		onActivity()
-		stdout, stderr, exitCode, err := ws.Execute(context.Background(), args.Command, args.Args, args.TimeoutSeconds)
-		stdout = workspace.TruncateOutputString(stdout, 2000)
-		stderr = workspace.TruncateOutputString(stderr, 2000)
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("move: %w", err)
+		}
+		result, err := ws.Move(context.Background(), args.Source, args.Destination, args.Overwrite)
+		return fsResponse(result, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register move: %w", err)
+	}
 
+	// Hands: workspace.copy
+	if err := srv.RegisterTool("workspace.copy", "Copy a file or directory, creating missing parent directories of the destination; undoable with workspace.undo", func(ctx context.Context, args TransferArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
 		if err != nil {
-			msg := fmt.Sprintf("Exit Code: %d\nSTDOUT:\n%s\nSTDERR:\n%s\nError: %s", exitCode, stdout, stderr, err.Error())
-			return mcp.NewToolResponse(mcp.NewTextContent(msg)), nil
+			return nil, fmt.Errorf("copy: %w", err)
 		}
+		result, err := ws.Copy(context.Background(), args.Source, args.Destination, args.Overwrite)
+		return fsResponse(result, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register copy: %w", err)
+	}
+
+	// Hands: workspace.delete
+	if err := srv.RegisterTool("workspace.delete", "Delete a file or directory (non-empty directories need recursive); undoable with workspace.undo, and trash keeps a copy outside the workspace", func(ctx context.Context, args DeleteArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("delete: %w", err)
+		}
+		result, err := ws.Delete(context.Background(), args.Path, workspace.DeleteOptions{
+			Recursive: args.Recursive,
+			Trash:     args.Trash,
+		})
+		return fsResponse(result, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register delete: %w", err)
+	}
 
-		result := fmt.Sprintf("Exit Code: %d\nSTDOUT:\n%s\nSTDERR:\n%s", exitCode, stdout, stderr)
+	// Hands: workspace.history
+	if err := srv.RegisterTool("workspace.history", "List recent edits made by write_file, search_and_replace, apply_unified_diff, replace_lines, insert_lines, make_dir, move, copy and delete (newest first) with before/after content hashes; use the ids with workspace.undo", func(ctx context.Context, args HistoryArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		limit := args.Limit
+		if limit == 0 {
+			limit = defaultHistoryLimit
+		}
+		report, err := ws.History(context.Background(), limit)
+		if err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		jsonBytes, _ := json.MarshalIndent(report, "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register history: %w", err)
+	}
+
+	// Hands: workspace.undo
+	if err := srv.RegisterTool("workspace.undo", "Undo edits from the edit history: the last steps edits (default 1) or the edit with editId; refuses files changed since the edit", func(ctx context.Context, args UndoArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("undo: %w", err)
+		}
+		entries, err := ws.Undo(context.Background(), args.Steps, args.EditID)
+		return revertResponse("undone", entries, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register undo: %w", err)
+	}
+
+	// Hands: workspace.redo
+	if err := srv.RegisterTool("workspace.redo", "Redo undone edits: the most recently undone steps edits (default 1) or the edit with editId; refuses files changed since the undo", func(ctx context.Context, args UndoArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("redo: %w", err)
+		}
+		entries, err := ws.Redo(context.Background(), args.Steps, args.EditID)
+		return revertResponse("redone", entries, err), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register redo: %w", err)
+	}
+
+	// Shield: workspace.secure_exec
+	// 请求带 progressToken 时，输出以 notifications/progress 实时推送；完整输出可用 read_exec_log 读取
+	if err := srv.RegisterTool("workspace.secure_exec", "Execute a command securely with timeout (streams output as progress notifications when a progressToken is given)", func(ctx context.Context, args SecuredExecArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		client := clientID(ctx)
+		ws, wsName, err := workspaces.Resolve(client, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("secure_exec: %w", err)
+		}
+		opts := workspace.ExecOptions{
+			TimeoutSeconds: args.TimeoutSeconds,
+			Env:            args.Env,
+			Cwd:            args.Cwd,
+			Stdin:          args.Stdin,
+			StdinFile:      args.StdinFile,
+		}
+		forwarder := newOutputForwarder(ctx)
+		if forwarder != nil {
+			opts.OnOutput = forwarder.OnOutput
+		}
+		res, err := ws.ExecuteStream(ctx, args.Command, args.Args, opts)
+		if forwarder != nil {
+			forwarder.Flush()
+		}
+		logs.add(res.LogID, client, wsName)
+		stdout := workspace.TruncateOutputString(res.Stdout, 2000)
+		stderr := workspace.TruncateOutputString(res.Stderr, 2000)
+
+		result := fmt.Sprintf("Exit Code: %d\nSTDOUT:\n%s\nSTDERR:\n%s", res.ExitCode, stdout, stderr)
+		if err != nil {
+			result += fmt.Sprintf("\nError: %s", err.Error())
+		}
+		if res.LogID != "" {
+			result += fmt.Sprintf("\nLog: %s (%d bytes, full output via workspace.read_exec_log)", res.LogID, res.LogBytes)
+		}
 		return mcp.NewToolResponse(mcp.NewTextContent(result)), nil
 	}); err != nil {
 		return fmt.Errorf("failed to register secure_exec: %w", err)
 	}
 
+	// Shield: workspace.run_tests
+	if err := srv.RegisterTool("workspace.run_tests", "Run go test -json and return structured per-test results (status, elapsed, failure output with file:line) plus a summary", func(ctx context.Context, args RunTestsArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		client := clientID(ctx)
+		ws, wsName, err := workspaces.Resolve(client, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("run_tests: %w", err)
+		}
+		opts := workspace.TestOptions{
+			Packages:       args.Packages,
+			Run:            args.Run,
+			Skip:           args.Skip,
+			TimeoutSeconds: args.TimeoutSeconds,
+			Cwd:            args.Cwd,
+		}
+		forwarder := newOutputForwarder(ctx)
+		if forwarder != nil {
+			opts.OnOutput = forwarder.OnOutput
+		}
+		report, err := ws.RunTests(ctx, opts)
+		if forwarder != nil {
+			forwarder.Flush()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("run_tests: %w", err)
+		}
+		logs.add(report.LogID, client, wsName)
+		jsonBytes, _ := json.MarshalIndent(report, "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register run_tests: %w", err)
+	}
+
+	// Shield: workspace.diagnostics
+	if err := srv.RegisterTool("workspace.diagnostics", "Run go build and go vet (plus gofmt -l and staticcheck when allowed and installed) and return de-duplicated diagnostics as {file, line, column, severity, message, tool} with workspace-relative paths", func(ctx context.Context, args DiagnosticsArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("diagnostics: %w", err)
+		}
+		report, err := ws.Diagnostics(ctx, workspace.DiagnosticsOptions{
+			Packages:       args.Packages,
+			Tools:          args.Tools,
+			TimeoutSeconds: args.TimeoutSeconds,
+			Cwd:            args.Cwd,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("diagnostics: %w", err)
+		}
+		jsonBytes, _ := json.MarshalIndent(report, "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register diagnostics: %w", err)
+	}
+
+	// Shield: workspace.read_exec_log
+	// 只能读取本客户端在同一工作区中产生的日志（HTTP 模式下各会话互不可见）
+	if err := srv.RegisterTool("workspace.read_exec_log", "Read the full output log of a secure_exec, run_tests or background job run by byte offset", func(ctx context.Context, args ReadExecLogArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		client := clientID(ctx)
+		_, wsName, err := workspaces.Resolve(client, args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("read_exec_log: %w", err)
+		}
+		if !logs.owns(args.LogID, client, wsName) {
+			return nil, fmt.Errorf("read_exec_log: exec log %q not found in workspace %q", args.LogID, wsName)
+		}
+		limit := args.Limit
+		if limit <= 0 {
+			limit = defaultExecLogChunk
+		}
+		limit = min(limit, maxExecLogChunk)
+		chunk, err := workspace.ReadExecLog(args.LogID, args.Offset, limit)
+		if err != nil {
+			return nil, fmt.Errorf("read_exec_log: %w", err)
+		}
+		jsonBytes, _ := json.MarshalIndent(chunk, "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register read_exec_log: %w", err)
+	}
+
+	// Shield: workspace.explain_policy
+	if err := srv.RegisterTool("workspace.explain_policy", "Explain whether a command would be allowed by secure_exec and which policy rule decides it (does not run the command)", func(ctx context.Context, args ExplainPolicyArgs) (*mcp.ToolResponse, error) {
+		onActivity()
+		ws, err := workspaces.Get(clientID(ctx), args.Workspace)
+		if err != nil {
+			return nil, fmt.Errorf("explain_policy: %w", err)
+		}
+		jsonBytes, _ := json.MarshalIndent(ws.ExplainCommand(args.Command, args.Args), "", "  ")
+		return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes))), nil
+	}); err != nil {
+		return fmt.Errorf("failed to register explain_policy: %w", err)
+	}
+
 	return nil
 }
 
+type ListWorkspacesArgs struct{}
+
 // 参数结构体（用于 Eyes 工具）
 type InspectWorkspaceArgs struct {
-	Path     string `json:"path" jsonschema:"description=Relative path to inspect (default root)"`
-	MaxDepth int    `json:"maxDepth" jsonschema:"description=Recursion depth (default 2)"`
+	Path      string `json:"path" jsonschema:"description=Relative path to inspect (default root)"`
+	MaxDepth  int    `json:"maxDepth" jsonschema:"description=Recursion depth (default 2)"`
+	Format    string `json:"format" jsonschema:"description=Output format: flat (default JSON list), tree (nested JSON with per-directory size and file_count) or ascii (compact text tree)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+// SerializableNode inspect_workspace 的输出节点（mod_time 统一为 RFC3339）
+type SerializableNode struct {
+	Path      string              `json:"path"`
+	IsDir     bool                `json:"is_dir"`
+	Size      int64               `json:"size"`
+	ModTime   string              `json:"mod_time"`
+	FileCount int                 `json:"file_count,omitempty"`
+	Children  []*SerializableNode `json:"children,omitempty"`
+}
+
+// toSerializableNode 递归转换 TreeNode
+func toSerializableNode(n *workspace.TreeNode) *SerializableNode {
+	node := &SerializableNode{
+		Path:      n.Path,
+		IsDir:     n.IsDir,
+		Size:      n.Size,
+		ModTime:   n.ModTime.Format(time.RFC3339),
+		FileCount: n.FileCount,
+	}
+	for _, child := range n.Children {
+		node.Children = append(node.Children, toSerializableNode(child))
+	}
+	return node
 }
 
 type ReadCodeFragmentArgs struct {
 	Path      string `json:"path" jsonschema:"required,description=File path to read"`
 	StartLine int    `json:"startLine" jsonschema:"required,description=Start line (1-indexed)"`
 	EndLine   int    `json:"endLine" jsonschema:"required,description=End line (inclusive)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type GrepArgs struct {
+	Pattern      string   `json:"pattern" jsonschema:"required,description=Regex (or literal string when literal=true) to search for"`
+	Path         string   `json:"path" jsonschema:"description=Directory or file to search (default root)"`
+	Literal      bool     `json:"literal" jsonschema:"description=Treat pattern as a literal string"`
+	IgnoreCase   bool     `json:"ignoreCase" jsonschema:"description=Case-insensitive search"`
+	Include      []string `json:"include" jsonschema:"description=Only search files matching these globs (e.g. *.go)"`
+	Exclude      []string `json:"exclude" jsonschema:"description=Skip files or directories matching these globs"`
+	ContextLines int      `json:"contextLines" jsonschema:"description=Lines of context before and after each match (max 10)"`
+	MaxResults   int      `json:"maxResults" jsonschema:"description=Maximum matches to return (capped by max_search_results)"`
+	Workspace    string   `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type FindFilesArgs struct {
+	Pattern        string `json:"pattern" jsonschema:"required,description=Glob pattern; ** matches any number of directories; patterns without / match file names at any depth"`
+	Path           string `json:"path" jsonschema:"description=Directory to search (default root)"`
+	Type           string `json:"type" jsonschema:"description=Entry type filter: file or dir (default both)"`
+	MinSize        int64  `json:"minSize" jsonschema:"description=Minimum file size in bytes"`
+	MaxSize        int64  `json:"maxSize" jsonschema:"description=Maximum file size in bytes"`
+	ModifiedAfter  string `json:"modifiedAfter" jsonschema:"description=Only entries modified after this RFC3339 time"`
+	ModifiedBefore string `json:"modifiedBefore" jsonschema:"description=Only entries modified before this RFC3339 time"`
+	SortBy         string `json:"sortBy" jsonschema:"description=Sort order: path (default) or relevance"`
+	Cursor         string `json:"cursor" jsonschema:"description=Paging cursor from a previous next_cursor"`
+	Limit          int    `json:"limit" jsonschema:"description=Page size (capped by max_search_results)"`
+	Workspace      string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+// parseOptionalTime 解析可选的 RFC3339 时间参数，空字符串返回零值
+func parseOptionalTime(s string) (time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(s))
+}
+
+// revertResponse 渲染 undo/redo 的结果；中途失败时同时列出已完成的条目
+func revertResponse(key string, entries []workspace.EditEntry, err error) *mcp.ToolResponse {
+	if err != nil && len(entries) == 0 {
+		return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s", err.Error())))
+	}
+	jsonBytes, _ := json.MarshalIndent(map[string][]workspace.EditEntry{key: entries}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s\n\n%s", err.Error(), jsonBytes)))
+	}
+	return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes)))
+}
+
+// lineEditResponse 渲染 replace_lines / insert_lines 的结果
+func lineEditResponse(result *workspace.LineEditResult, err error) *mcp.ToolResponse {
+	if err != nil {
+		return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s", err.Error())))
+	}
+	jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+	return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes)))
+}
+
+// fsResponse 渲染 make_dir / move / copy / delete 的结果
+func fsResponse(result *workspace.FSResult, err error) *mcp.ToolResponse {
+	if err != nil {
+		return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: %s", err.Error())))
+	}
+	jsonBytes, _ := json.MarshalIndent(result, "", "  ")
+	return mcp.NewToolResponse(mcp.NewTextContent(string(jsonBytes)))
+}
+
+// formatGrepResult 将搜索结果渲染为 grep 风格文本（path:line:col: text），比 JSON 更节省 token
+func formatGrepResult(result *workspace.GrepResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Matches: %d (files scanned: %d, truncated: %v)\n", len(result.Matches), result.FilesScanned, result.Truncated)
+	for _, m := range result.Matches {
+		for i, text := range m.Before {
+			fmt.Fprintf(&sb, "%s-%d- %s\n", m.Path, m.Line-len(m.Before)+i, text)
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d: %s\n", m.Path, m.Line, m.Column, m.Text)
+		for i, text := range m.After {
+			fmt.Fprintf(&sb, "%s-%d- %s\n", m.Path, m.Line+1+i, text)
+		}
+		if len(m.Before) > 0 || len(m.After) > 0 {
+			sb.WriteString("--\n")
+		}
+	}
+	return sb.String()
 }
 
 // 参数结构体（用于 Hands 工具）
 type ApplyUnifiedDiffArgs struct {
-	DiffText string `json:"diffText" jsonschema:"required,description=Unified diff content"`
-	DryRun   bool   `json:"dryRun" jsonschema:"description=Preview only without applying"`
+	DiffText     string            `json:"diffText" jsonschema:"required,description=Unified diff content"`
+	DryRun       bool              `json:"dryRun" jsonschema:"description=Preview only without applying"`
+	Fuzz         int               `json:"fuzz" jsonschema:"description=Max context lines ignored at each end of a hunk when it does not match exactly (0 for default 2, negative for exact context)"`
+	MaxOffset    int               `json:"maxOffset" jsonschema:"description=Max lines a hunk may move from its @@ line number (0 for unlimited, negative to disable offset search)"`
+	WriteRejects bool              `json:"writeRejects" jsonschema:"description=Apply the hunks that match and write failed hunks to <file>.rej (default: apply nothing if any hunk fails)"`
+	IfMatch      map[string]string `json:"ifMatch" jsonschema:"description=Map of file path to the ETag from read_file; the patch is refused with a conflict (and a diff of the changes) if any of these files changed since it was read"`
+	Workspace    string            `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
 }
 
 type SearchAndReplaceArgs struct {
-	Path                string `json:"path" jsonschema:"required,description=File path to modify"`
-	Old                 string `json:"old" jsonschema:"required,description=String to search for"`
-	New                 string `json:"new" jsonschema:"required,description=Replacement string"`
-	ExpectedOccurrences int    `json:"expectedOccurrences" jsonschema:"description=Expected number of occurrences (0 for dry-run)"`
+	Path                string            `json:"path" jsonschema:"description=File path to modify (either path or glob is required)"`
+	Glob                string            `json:"glob" jsonschema:"description=Replace in every text file matching this doublestar glob (e.g. **/*.go) instead of a single path"`
+	Old                 string            `json:"old" jsonschema:"description=String or regex to search for (shorthand for a single edit)"`
+	New                 string            `json:"new" jsonschema:"description=Replacement string; in regex mode $1 or ${name} expand to capture groups"`
+	Regex               bool              `json:"regex" jsonschema:"description=Treat old as an RE2 regular expression"`
+	ExpectedOccurrences int               `json:"expectedOccurrences" jsonschema:"description=Expected number of matches (total across files with glob); the edit is refused if it differs (0 for no check)"`
+	Occurrence          int               `json:"occurrence" jsonschema:"description=Replace only the Nth match (1-based; path only)"`
+	Edits               []ReplaceEditArgs `json:"edits" jsonschema:"description=Ordered list of edits applied one after another; all are written together or not at all"`
+	DryRun              bool              `json:"dryRun" jsonschema:"description=Preview only: report match counts and line previews without writing"`
+	IfMatch             string            `json:"ifMatch" jsonschema:"description=ETag from read_file; refuse with a conflict (and a diff of the changes) if the file changed since it was read (path only)"`
+	Workspace           string            `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+// ReplaceEditArgs 与 workspace.ReplaceEdit 字段一一对应
+type ReplaceEditArgs struct {
+	Old                 string `json:"old" jsonschema:"required,description=String or regex to search for"`
+	New                 string `json:"new" jsonschema:"description=Replacement string"`
+	Regex               bool   `json:"regex" jsonschema:"description=Treat old as an RE2 regular expression"`
+	ExpectedOccurrences int    `json:"expectedOccurrences" jsonschema:"description=Expected number of matches (0 for no check)"`
+	Occurrence          int    `json:"occurrence" jsonschema:"description=Replace only the Nth match (1-based)"`
+}
+
+type ReplaceLinesArgs struct {
+	Path      string `json:"path" jsonschema:"required,description=File path to modify"`
+	StartLine int    `json:"startLine" jsonschema:"required,description=First line to replace (1-based)"`
+	EndLine   int    `json:"endLine" jsonschema:"required,description=Last line to replace (inclusive)"`
+	Text      string `json:"text" jsonschema:"description=Replacement lines (a trailing newline is optional; empty deletes the lines)"`
+	Expected  string `json:"expected" jsonschema:"description=Current content of lines startLine..endLine; the edit is refused if they differ (write a single blank line as a newline)"`
+	IfMatch   string `json:"ifMatch" jsonschema:"description=ETag from read_file or read_code_fragment; refuse with a conflict (and a diff of the changes) if the file changed since it was read"`
+	DryRun    bool   `json:"dryRun" jsonschema:"description=Preview only without writing"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type InsertLinesArgs struct {
+	Path      string `json:"path" jsonschema:"required,description=File path to modify"`
+	AfterLine int    `json:"afterLine" jsonschema:"required,description=Insert after this line (0 for the top of the file)"`
+	Text      string `json:"text" jsonschema:"required,description=Lines to insert (a trailing newline is optional)"`
+	Expected  string `json:"expected" jsonschema:"description=Current content of the lines ending at afterLine; the insert is refused if they differ"`
+	IfMatch   string `json:"ifMatch" jsonschema:"description=ETag from read_file or read_code_fragment; refuse with a conflict (and a diff of the changes) if the file changed since it was read"`
+	DryRun    bool   `json:"dryRun" jsonschema:"description=Preview only without writing"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type MakeDirArgs struct {
+	Path      string `json:"path" jsonschema:"required,description=Directory to create"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+// TransferArgs workspace.move 与 workspace.copy 共用的参数
+type TransferArgs struct {
+	Source      string `json:"source" jsonschema:"required,description=File or directory to move or copy"`
+	Destination string `json:"destination" jsonschema:"required,description=Full destination path (not the parent directory); existing directories are not merged"`
+	Overwrite   bool   `json:"overwrite" jsonschema:"description=Replace existing destination files (refused when files.disable_delete is set)"`
+	Workspace   string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type DeleteArgs struct {
+	Path      string `json:"path" jsonschema:"required,description=File or directory to delete"`
+	Recursive bool   `json:"recursive" jsonschema:"description=Delete a non-empty directory and everything in it"`
+	Trash     bool   `json:"trash" jsonschema:"description=Copy the deleted content to the trash directory (files.trash_dir) first"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+// history 默认返回的条数
+const defaultHistoryLimit = 20
+
+type HistoryArgs struct {
+	Limit     int    `json:"limit" jsonschema:"description=Maximum entries to return (0 for default 20, negative for all)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type UndoArgs struct {
+	Steps     int    `json:"steps" jsonschema:"description=Number of edits to undo/redo (0 for default 1); ignored when editId is set"`
+	EditID    int64  `json:"editId" jsonschema:"description=Id of a specific edit from workspace.history"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
 }
 
 // 参数结构体（用于 Shield 工具）
 type SecuredExecArgs struct {
-	Command        string   `json:"command" jsonschema:"required,description=Command to execute"`
-	Args           []string `json:"args" jsonschema:"description=Command arguments"`
+	Command        string            `json:"command" jsonschema:"required,description=Command to execute"`
+	Args           []string          `json:"args" jsonschema:"description=Command arguments"`
+	TimeoutSeconds int64             `json:"timeoutSeconds" jsonschema:"description=Timeout in seconds (0 for default)"`
+	Env            map[string]string `json:"env" jsonschema:"description=Extra environment variables (only names allowed by the env policy)"`
+	Cwd            string            `json:"cwd" jsonschema:"description=Working directory relative to the workspace root (default: workspace root)"`
+	Stdin          string            `json:"stdin" jsonschema:"description=Text passed to the command's standard input"`
+	StdinFile      string            `json:"stdinFile" jsonschema:"description=Workspace file passed as standard input (instead of stdin)"`
+	Workspace      string            `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type RunTestsArgs struct {
+	Packages       []string `json:"packages" jsonschema:"description=Package patterns (default ./...)"`
+	Run            string   `json:"run" jsonschema:"description=Only run tests matching this regexp (go test -run)"`
+	Skip           string   `json:"skip" jsonschema:"description=Skip tests matching this regexp (go test -skip)"`
 	TimeoutSeconds int64    `json:"timeoutSeconds" jsonschema:"description=Timeout in seconds (0 for default)"`
+	Cwd            string   `json:"cwd" jsonschema:"description=Working directory relative to the workspace root, e.g. a nested module"`
+	Workspace      string   `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+type DiagnosticsArgs struct {
+	Packages       []string `json:"packages" jsonschema:"description=Package patterns for build/vet/staticcheck (default ./...)"`
+	Tools          []string `json:"tools" jsonschema:"description=Sources to run: build, vet, gofmt, staticcheck (default: build and vet, plus gofmt and staticcheck when allowed and installed)"`
+	TimeoutSeconds int64    `json:"timeoutSeconds" jsonschema:"description=Timeout in seconds per command (0 for default)"`
+	Cwd            string   `json:"cwd" jsonschema:"description=Working directory relative to the workspace root, e.g. a nested module"`
+	Workspace      string   `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
+
+// read_exec_log 默认与最大单次读取字节数
+const (
+	defaultExecLogChunk = 64 << 10
+	maxExecLogChunk     = 1 << 20
+)
+
+type ReadExecLogArgs struct {
+	LogID     string `json:"logId" jsonschema:"required,description=Log ID printed by secure_exec"`
+	Offset    int64  `json:"offset" jsonschema:"description=Byte offset to start reading from (use next_offset of the previous call)"`
+	Limit     int64  `json:"limit" jsonschema:"description=Maximum bytes to read (default 65536, max 1048576)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace the command ran in (default workspace if empty)"`
+}
+
+type ExplainPolicyArgs struct {
+	Command   string   `json:"command" jsonschema:"required,description=Command to check (executable name; extra words are treated as arguments)"`
+	Args      []string `json:"args" jsonschema:"description=Command arguments"`
+	Workspace string   `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
 }
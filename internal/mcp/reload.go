@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// levelSetter 支持运行中修改日志级别的 Logger（如 log.StdLogger）
+type levelSetter interface {
+	SetLevel(levelStr string)
+}
+
+// DisableTools 设置不对外暴露的工具；服务器运行中调用时客户端会收到 notifications/tools/list_changed
+func (s *Server) DisableTools(names []string) error {
+	if unknown := s.tools.Unknown(names); len(unknown) > 0 {
+		s.logger.Warn(context.Background(), "Ignoring unknown tools in disabled_tools", "tools", strings.Join(unknown, ","))
+	}
+	enabled, disabled, err := s.tools.SetDisabled(names)
+	if len(enabled) > 0 || len(disabled) > 0 {
+		s.logger.Info(context.Background(), "Tool set changed", "enabled", strings.Join(enabled, ","), "disabled", strings.Join(disabled, ","))
+	}
+	return err
+}
+
+// DisabledTools 返回配置中不对外暴露的工具：disabled_tools，以及 files.disable_delete 开启时的 workspace.delete 与 workspace.move
+func DisabledTools(cfg *config.Config) []string {
+	names := append([]string(nil), cfg.DisabledTools...)
+	if cfg.Files.DisableDelete {
+		names = append(names, "workspace.delete", "workspace.move")
+	}
+	return names
+}
+
+// ApplyConfig 应用热重载后的配置（注册为 config.Reloader 回调）：
+// 重建工作区（命令白名单、扩展名黑名单、路径白名单、超时等）、更新日志级别、工具集合与后台任务上限
+// 传输相关配置需要重启进程才能生效，这里只提示
+// 无论 config.Diff 是否列出差异都会完整应用新配置，Diff 只用于日志，漏列的字段也不会被忽略
+func (s *Server) ApplyConfig(oldCfg, newCfg *config.Config) error {
+	changes := config.Diff(oldCfg, newCfg)
+	ctx := context.Background()
+
+	if err := s.workspaces.Reload(newCfg); err != nil {
+		return fmt.Errorf("failed to reload workspaces: %w", err)
+	}
+	logWorkspaceWarnings(s.logger, s.workspaces, "")
+	if l, ok := s.logger.(levelSetter); ok {
+		l.SetLevel(newCfg.LogLevel)
+	}
+	if err := s.DisableTools(DisabledTools(newCfg)); err != nil {
+		return err
+	}
+	s.jobs.SetLimit(newCfg.MaxJobs)
+
+	if len(changes) == 0 {
+		s.logger.Debug(ctx, "Config reloaded without changes")
+	} else {
+		s.logger.Info(ctx, "Config reloaded", "changes", strings.Join(changes, "; "))
+	}
+	if oldCfg.Transport != newCfg.Transport || oldCfg.HTTP != newCfg.HTTP {
+		s.logger.Warn(ctx, "Transport settings changed; restart the server to apply them")
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+	"opencode-go-mcp/internal/log"
+	"opencode-go-mcp/internal/workspace"
+)
+
+// newReloadTestServer 在 HTTP 传输上启动完整的服务器（含全部工具），返回服务器与 /mcp 地址
+func newReloadTestServer(t *testing.T, cfg *config.Config) (*Server, string) {
+	t.Helper()
+	reg, err := workspace.NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := newHTTPTransport(log.NewStdLogger("error"), "")
+	s, err := newServer(reg, log.NewStdLogger("error"), tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(tr.Handler("http"))
+	t.Cleanup(func() {
+		tr.Close()
+		srv.Close()
+	})
+	return s, srv.URL + "/mcp"
+}
+
+func testReloadConfig(t *testing.T) *config.Config {
+	return &config.Config{
+		RootDir:              t.TempDir(),
+		LogLevel:             "error",
+		MaxFileBytes:         1024,
+		MaxJobs:              4,
+		AllowedBuildCommands: []string{"go"},
+	}
+}
+
+// listTools 通过 tools/list 返回客户端看到的工具列表原文
+func listTools(t *testing.T, url, session string) string {
+	t.Helper()
+	resp := doMCP(t, http.MethodPost, url, session, "application/json", `{"jsonrpc":"2.0","id":9,"method":"tools/list","params":{}}`)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestApplyConfig_DisabledTools(t *testing.T) {
+	cfg := testReloadConfig(t)
+	s, url := newReloadTestServer(t, cfg)
+	sess := initSession(t, url)
+	stream := openStream(t, url, sess)
+	if !strings.Contains(listTools(t, url, sess), `"workspace.write_file"`) {
+		t.Fatal("workspace.write_file missing before reload")
+	}
+
+	// 禁用：工具被注销，客户端收到 tools/list_changed
+	next := *cfg
+	next.DisabledTools = []string{"workspace.write_file"}
+	if err := s.ApplyConfig(cfg, &next); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, stream); !strings.Contains(e, "notifications/tools/list_changed") {
+		t.Errorf("event after disabling = %s, want tools/list_changed", e)
+	}
+	if slices.Contains(s.tools.Enabled(), "workspace.write_file") || strings.Contains(listTools(t, url, sess), `"workspace.write_file"`) {
+		t.Error("workspace.write_file still exposed after disabling")
+	}
+
+	// 重新启用
+	if err := s.ApplyConfig(&next, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, stream); !strings.Contains(e, "notifications/tools/list_changed") {
+		t.Errorf("event after enabling = %s, want tools/list_changed", e)
+	}
+	if !strings.Contains(listTools(t, url, sess), `"workspace.write_file"`) {
+		t.Error("workspace.write_file not exposed after enabling")
+	}
+}
+
+func TestApplyConfig_DisableDeleteHidesMove(t *testing.T) {
+	cfg := testReloadConfig(t)
+	s, _ := newReloadTestServer(t, cfg)
+	next := *cfg
+	next.Files.DisableDelete = true
+	if err := s.ApplyConfig(cfg, &next); err != nil {
+		t.Fatal(err)
+	}
+	enabled := s.tools.Enabled()
+	if slices.Contains(enabled, "workspace.delete") || slices.Contains(enabled, "workspace.move") {
+		t.Errorf("delete/move still enabled: %v", enabled)
+	}
+	if !slices.Contains(enabled, "workspace.copy") {
+		t.Errorf("workspace.copy should stay enabled: %v", enabled)
+	}
+}
+
+func TestApplyConfig_Policy(t *testing.T) {
+	cfg := testReloadConfig(t)
+	s, _ := newReloadTestServer(t, cfg)
+	explain := func() workspace.PolicyDecision {
+		ws, err := s.workspaces.Get("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ws.ExplainCommand("make", []string{"build"})
+	}
+	if explain().Allowed {
+		t.Fatal("make allowed before reload")
+	}
+
+	next := *cfg
+	next.AllowedBuildCommands = []string{"go", "make"}
+	if err := s.ApplyConfig(cfg, &next); err != nil {
+		t.Fatal(err)
+	}
+	if d := explain(); !d.Allowed {
+		t.Errorf("make denied after reload: %+v", d)
+	}
+}
+
+func TestApplyConfig_RootDir(t *testing.T) {
+	cfg := testReloadConfig(t)
+	s, _ := newReloadTestServer(t, cfg)
+	next := *cfg
+	next.RootDir = t.TempDir()
+	if err := s.ApplyConfig(cfg, &next); err != nil {
+		t.Fatal(err)
+	}
+	if list := s.workspaces.List(""); len(list) != 1 || list[0].Root != next.RootDir {
+		t.Errorf("workspaces after root_dir change = %+v, want root %s", list, next.RootDir)
+	}
+}
@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+
+	"opencode-go-mcp/internal/workspace"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// 本文件实现 MCP roots：向客户端请求 roots/list，并把 file:// 根目录同步到工作区注册表。
+// roots/list 只发给触发它的客户端会话，也只接受该会话的响应，结果只对该会话可见。
+
+// requestRoots 向客户端 client 发送 roots/list 请求
+func (t *extTransport) requestRoots(client string) {
+	id := transport.RequestId(serverRequestIDBase + t.nextID.Add(1))
+	t.mu.Lock()
+	t.pendingRoots[id] = client
+	t.mu.Unlock()
+
+	ctx := context.Background()
+	if client != "" {
+		ctx = context.WithValue(ctx, sessionKey{}, client)
+	}
+	err := t.Transport.Send(ctx, transport.NewBaseMessageRequest(&transport.BaseJSONRPCRequest{
+		Jsonrpc: "2.0",
+		Id:      id,
+		Method:  "roots/list",
+		Params:  json.RawMessage("{}"),
+	}))
+	if err != nil {
+		t.takeRoots(id, client)
+		t.logger.Warn(context.Background(), "Failed to send roots/list", "error", err)
+	}
+}
+
+// handleRootsResult 解析 roots/list 结果并更新客户端 client 的工作区（非 file:// 的 root、
+// 不在已配置工作区内且未开启 allow_client_roots 的 root 会被忽略）
+func (t *extTransport) handleRootsResult(client string, result json.RawMessage) {
+	var payload struct {
+		Roots []struct {
+			URI  string `json:"uri"`
+			Name string `json:"name"`
+		} `json:"roots"`
+	}
+	if err := json.Unmarshal(result, &payload); err != nil {
+		t.logger.Warn(context.Background(), "Invalid roots/list result", "error", err)
+		return
+	}
+
+	var roots []workspace.ClientRoot
+	for _, r := range payload.Roots {
+		path, err := fileURIToPath(r.URI)
+		if err != nil {
+			t.logger.Warn(context.Background(), "Ignoring client root", "uri", r.URI, "error", err)
+			continue
+		}
+		roots = append(roots, workspace.ClientRoot{Name: r.Name, Path: path})
+	}
+	ignored, err := t.registry.SetClientRoots(client, roots)
+	if err != nil {
+		t.logger.Warn(context.Background(), "Failed to apply client roots", "error", err)
+		return
+	}
+	for _, reason := range ignored {
+		t.logger.Warn(context.Background(), "Ignoring client root", "reason", reason)
+	}
+	t.logger.Info(context.Background(), "Client roots updated", "count", len(roots)-len(ignored))
+	logWorkspaceWarnings(t.logger, t.registry, client)
+}
+
+// fileURIToPath 将 file:// URI 转换为本地路径
+func fileURIToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	path := u.Path
+	// Windows: file:///C:/work → C:/work
+	if runtime.GOOS == "windows" && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	if path == "" || !filepath.IsAbs(filepath.FromSlash(path)) {
+		return "", fmt.Errorf("root path must be absolute")
+	}
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
@@ -2,7 +2,10 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"sync/atomic"
 	"time"
 
@@ -10,57 +13,125 @@ import (
 	"opencode-go-mcp/internal/workspace"
 
 	mcp "github.com/metoro-io/mcp-golang"
+	"github.com/metoro-io/mcp-golang/transport"
 	"github.com/metoro-io/mcp-golang/transport/stdio"
 )
 
+// shutdownTimeout HTTP 模式下优雅关闭的最长等待时间
+const shutdownTimeout = 10 * time.Second
+
+// jobShutdownGrace 退出时等待后台任务终止的最长时间
+const jobShutdownGrace = 5 * time.Second
+
+// HTTPOptions HTTP/SSE 传输选项
+type HTTPOptions struct {
+	Mode      string // "http"（Streamable HTTP，端点 /mcp）或 "sse"（旧版 HTTP+SSE，端点 /sse 与 /message）
+	Addr      string // 监听地址，如 127.0.0.1:8765
+	AuthToken string // 非空时要求 Authorization: Bearer <token>
+	// AllowInsecure 允许在非本机地址上不设 AuthToken 监听，否则 RunHTTP 拒绝启动
+	AllowInsecure bool
+}
+
 // Server 封装 mcp-golang 服务器
 type Server struct {
-	ws           workspace.Workspace
+	workspaces   *workspace.Registry
 	logger       log.Logger
 	server       *mcp.Server
+	ext          *extTransport
+	tools        *toolSet
+	jobs         *workspace.JobManager
+	logs         *execLogOwners
+	http         *httpTransport
+	httpOpts     HTTPOptions
 	lastActivity atomic.Int64
 }
 
-// NewServer 创建 MCP 服务器并注册所有工具
-func NewServer(ws workspace.Workspace, logger log.Logger) (*Server, error) {
-	transport := stdio.NewStdioServerTransport()
-	mcpSrv := mcp.NewServer(transport)
+// NewServer 创建基于 stdio 传输的 MCP 服务器并注册所有工具
+func NewServer(workspaces *workspace.Registry, logger log.Logger) (*Server, error) {
+	return newServer(workspaces, logger, stdio.NewStdioServerTransport())
+}
+
+// NewHTTPServer 创建基于 HTTP/SSE 传输的 MCP 服务器，通过 RunHTTP 启动
+func NewHTTPServer(workspaces *workspace.Registry, logger log.Logger, opts HTTPOptions) (*Server, error) {
+	t := newHTTPTransport(logger, opts.AuthToken)
+	s, err := newServer(workspaces, logger, t)
+	if err != nil {
+		return nil, err
+	}
+	t.onSessionClose = func(id string) {
+		s.ext.forgetClient(id)
+		// 会话结束后其任务无法再被查看或终止，在后台终止并删除，不阻塞 HTTP 处理
+		go s.jobs.RemoveClient(id, jobShutdownGrace)
+	}
+	s.http = t
+	s.httpOpts = opts
+	return s, nil
+}
+
+// newServer 在指定传输层上创建服务器并注册工具（传输层外包一层协议扩展）
+func newServer(workspaces *workspace.Registry, logger log.Logger, t transport.Transport) (*Server, error) {
+	ext := newExtTransport(t, workspaces, logger)
+	mcpSrv := mcp.NewServer(ext)
 
 	s := &Server{
-		ws:     ws,
-		logger: logger,
-		server: mcpSrv,
+		ext:        ext,
+		workspaces: workspaces,
+		logger:     logger,
+		server:     mcpSrv,
+		tools:      newToolSet(mcpSrv, nil),
+		jobs:       workspace.NewJobManager(workspaces.Config().MaxJobs),
+		logs:       newExecLogOwners(),
 	}
 	s.lastActivity.Store(time.Now().UnixNano())
 
-	if err := registerTools(mcpSrv, ws, logger, func() {
+	onActivity := func() {
 		s.lastActivity.Store(time.Now().UnixNano())
-	}); err != nil {
+	}
+	if err := registerTools(s.tools, workspaces, s.logs, logger, onActivity); err != nil {
 		return nil, fmt.Errorf("failed to register tools: %w", err)
 	}
+	if err := registerJobTools(s.tools, workspaces, s.jobs, s.logs, onActivity); err != nil {
+		return nil, fmt.Errorf("failed to register tools: %w", err)
+	}
+	logWorkspaceWarnings(logger, workspaces, "")
 
 	return s, nil
 }
 
+// logWorkspaceWarnings 记录客户端 client 可见工作区的配置问题（如编辑日志未启用的原因）
+func logWorkspaceWarnings(logger log.Logger, workspaces *workspace.Registry, client string) {
+	for _, info := range workspaces.List(client) {
+		if info.Warning != "" {
+			logger.Warn(context.Background(), "Workspace configuration problem", "workspace", info.Name, "root", info.Root, "warning", info.Warning)
+		}
+	}
+}
+
 // 参数结构体定义（本地模式，无 Project 参数）
 
 type ReadFileArgs struct {
-	Path     string `json:"path" jsonschema:"required,description=File path to read"`
-	MaxBytes int64  `json:"maxBytes" jsonschema:"description=Maximum bytes to read (default 1MB)"`
+	Path      string `json:"path" jsonschema:"required,description=File path to read"`
+	MaxBytes  int64  `json:"maxBytes" jsonschema:"description=Maximum bytes to read (default 1MB)"`
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
 }
 
 type WriteFileArgs struct {
 	Path        string `json:"path" jsonschema:"required,description=File path to write"`
 	Content     string `json:"content" jsonschema:"required,description=Content to write"`
 	AllowCreate bool   `json:"allowCreate" jsonschema:"description=Allow creating new file"`
+	IfMatch     string `json:"ifMatch" jsonschema:"description=ETag from read_file; refuse with a conflict (and a diff of the changes) if the file changed since it was read"`
+	Workspace   string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
 }
 
-type HealthArgs struct{}
+type HealthArgs struct {
+	Workspace string `json:"workspace" jsonschema:"description=Workspace name from workspace.list_workspaces (default workspace if empty)"`
+}
 
-// RunSTDIO 启动服务器
+// RunSTDIO 启动服务器；退出时（空闲超时或收到信号）终止并清理所有后台任务
 func (s *Server) RunSTDIO(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	defer s.shutdownJobs()
 
 	idleTimeout := 30 * time.Minute
 
@@ -93,3 +164,81 @@ func (s *Server) RunSTDIO(ctx context.Context) error {
 	<-ctx.Done()
 	return nil
 }
+
+// RunHTTP 启动 HTTP/SSE 服务器，阻塞直到上下文取消后优雅关闭
+// 与 stdio 不同，HTTP 模式作为常驻服务运行，不做空闲超时退出
+func (s *Server) RunHTTP(ctx context.Context) error {
+	if s.http == nil {
+		return fmt.Errorf("server was not created with NewHTTPServer")
+	}
+
+	ln, err := net.Listen("tcp", s.httpOpts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.httpOpts.Addr, err)
+	}
+	if s.httpOpts.AuthToken == "" && !isLoopback(ln.Addr()) {
+		if !s.httpOpts.AllowInsecure {
+			ln.Close()
+			return fmt.Errorf("refusing to listen on non-loopback address %s without an auth token (set http.auth_token / MCP_HTTP_AUTH_TOKEN, or http.allow_insecure to override)", ln.Addr())
+		}
+		s.logger.Warn(ctx, "HTTP transport listening on non-loopback address without auth token", "addr", ln.Addr().String())
+	}
+
+	httpSrv := &http.Server{
+		Handler:           s.http.Handler(s.httpOpts.Mode),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if err := s.server.Serve(); err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to start mcp server: %w", err)
+	}
+	// 无论以何种方式返回都终止后台任务（包括 Serve 出错的情况）
+	defer s.shutdownJobs()
+
+	s.logger.Info(ctx, "MCP HTTP server listening", "addr", ln.Addr().String(), "mode", s.httpOpts.Mode)
+
+	reapCtx, stopReap := context.WithCancel(ctx)
+	defer stopReap()
+	go s.http.reapSessions(reapCtx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpSrv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("http server error: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	// 先关闭事件流（SSE 连接不会自行结束），再等待进行中的请求完成，最后关闭传输层
+	s.logger.Info(context.Background(), "Shutting down MCP HTTP server")
+	s.http.closeStreams()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = httpSrv.Shutdown(shutdownCtx)
+	_ = s.http.Close()
+	if err != nil {
+		return fmt.Errorf("http server shutdown: %w", err)
+	}
+	return nil
+}
+
+// shutdownJobs 终止运行中的后台任务并删除任务日志
+func (s *Server) shutdownJobs() {
+	if n := s.jobs.Len(); n > 0 {
+		s.logger.Info(context.Background(), "Cleaning up background jobs", "count", n)
+	}
+	s.jobs.Shutdown(jobShutdownGrace)
+}
+
+// isLoopback 判断监听地址是否仅限本机
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	"fmt"
+	"sync"
+
+	mcp "github.com/metoro-io/mcp-golang"
+)
+
+// toolDef 一个工具的注册信息（禁用的工具也保留，以便热重载时重新启用）
+type toolDef struct {
+	name        string
+	description string
+	handler     any
+}
+
+// toolSet 在 mcp.Server 之上维护可热切换的工具集合
+// 服务器运行后，mcp.Server 在注册/注销工具时会自动发送 notifications/tools/list_changed
+type toolSet struct {
+	srv      *mcp.Server
+	mu       sync.Mutex
+	defs     []toolDef
+	disabled map[string]bool
+}
+
+func newToolSet(srv *mcp.Server, disabled []string) *toolSet {
+	return &toolSet{srv: srv, disabled: toSet(disabled)}
+}
+
+// RegisterTool 记录工具定义，未被禁用时注册到服务器（签名与 mcp.Server.RegisterTool 一致）
+func (t *toolSet) RegisterTool(name, description string, handler any) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.defs = append(t.defs, toolDef{name: name, description: description, handler: handler})
+	if t.disabled[name] {
+		return nil
+	}
+	return t.srv.RegisterTool(name, description, handler)
+}
+
+// SetDisabled 按新的禁用列表注册/注销工具，返回新启用与新禁用的工具名
+func (t *toolSet) SetDisabled(names []string) (enabled, disabled []string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next := toSet(names)
+	for _, def := range t.defs {
+		switch {
+		case t.disabled[def.name] && !next[def.name]:
+			if err := t.srv.RegisterTool(def.name, def.description, def.handler); err != nil {
+				return enabled, disabled, fmt.Errorf("failed to register %s: %w", def.name, err)
+			}
+			enabled = append(enabled, def.name)
+		case !t.disabled[def.name] && next[def.name]:
+			if err := t.srv.DeregisterTool(def.name); err != nil {
+				return enabled, disabled, fmt.Errorf("failed to deregister %s: %w", def.name, err)
+			}
+			disabled = append(disabled, def.name)
+		}
+	}
+	t.disabled = next
+	return enabled, disabled, nil
+}
+
+// Enabled 返回当前对外暴露的工具名（按注册顺序）
+func (t *toolSet) Enabled() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var names []string
+	for _, def := range t.defs {
+		if !t.disabled[def.name] {
+			names = append(names, def.name)
+		}
+	}
+	return names
+}
+
+// Unknown 返回 names 中不是已注册工具的名称（用于提示配置拼写错误）
+func (t *toolSet) Unknown(names []string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	known := make(map[string]bool, len(t.defs))
+	for _, def := range t.defs {
+		known[def.name] = true
+	}
+	var unknown []string
+	for _, name := range names {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
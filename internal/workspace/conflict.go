@@ -0,0 +1,179 @@
+package workspace
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+)
+
+// 本文件实现写入时的乐观并发控制（ifMatch）：
+//  1. read_file / read_code_fragment 读取时顺带计算全文 sha256 作为 ETag（片段读取也会读完整个文件计算哈希），
+//     文件不大时把读到的全文放入内存中的快照缓存；写入类工具写入后的内容同样放入缓存。
+//  2. 写入类工具传入 ifMatch 时，文件当前内容的哈希必须与之相同，否则返回 ConflictError 且不写入任何文件。
+//  3. ConflictError 附带读取时的内容到当前内容的 unified diff：读取时的内容依次从快照缓存、编辑日志中查找，
+//     都找不到时只说明哈希不一致。
+
+// 快照缓存的总字节数上限；单个文件最多占四分之一
+const (
+	snapshotCacheBytes            = 4 << 20
+	lowResourceSnapshotCacheBytes = 1 << 20
+	maxConflictDiffLines          = 200
+)
+
+// ConflictError 文件在读取之后被修改（内容与 ifMatch 不一致）
+type ConflictError struct {
+	Path     string
+	Expected string // ifMatch 传入的哈希
+	Actual   string // 当前内容的哈希，为空表示文件不存在
+	Diff     string // 读取时的内容到当前内容的 unified diff，读取时的内容不可用时为空
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "conflict: %s has changed since it was read (ifMatch %s, current %s); nothing was written, re-read the file and reapply the edit",
+		e.Path, shortHash(e.Expected), shortHash(e.Actual))
+	switch {
+	case e.Diff != "":
+		b.WriteString("\n\nChanges since the read:\n")
+		b.WriteString(e.Diff)
+	case e.Actual != "":
+		b.WriteString("\n\n(diff unavailable: the content that was read is no longer cached)")
+	}
+	return b.String()
+}
+
+// snapshotCache 最近读取或写入的文件内容（按哈希索引，先进先出淘汰）
+type snapshotCache struct {
+	mu    sync.Mutex
+	order []string
+	data  map[string][]byte
+	bytes int64
+}
+
+var readSnapshots = &snapshotCache{data: make(map[string][]byte)}
+
+func (c *snapshotCache) put(hash string, data []byte, limit int64) {
+	if int64(len(data)) > limit/4 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.data[hash]; ok {
+		return
+	}
+	c.data[hash] = data
+	c.order = append(c.order, hash)
+	c.bytes += int64(len(data))
+	for c.bytes > limit && len(c.order) > 0 {
+		c.bytes -= int64(len(c.data[c.order[0]]))
+		delete(c.data, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *snapshotCache) get(hash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.data[hash]
+	return data, ok
+}
+
+// snapshotLimit 返回快照缓存的总字节数上限
+func (w *OSWorkspace) snapshotLimit() int64 {
+	if w.cfg.LowResourceMode {
+		return lowResourceSnapshotCacheBytes
+	}
+	return snapshotCacheBytes
+}
+
+// ContentHash 返回内容的 sha256（read_file 返回的 ETag 与写入类工具的 ifMatch 使用同一格式）
+func ContentHash(data []byte) string {
+	return contentHash(data, true)
+}
+
+// rememberContent 缓存写入后的内容，返回其哈希（作为下一次写入的 ifMatch）
+func (w *OSWorkspace) rememberContent(data []byte) string {
+	hash := contentHash(data, true)
+	readSnapshots.put(hash, data, w.snapshotLimit())
+	return hash
+}
+
+// versionSink 读取文件时接收全部字节：计算哈希，文件不超过缓存上限的四分之一时保留全文
+type versionSink struct {
+	sum   hash.Hash
+	buf   *bytes.Buffer
+	limit int64 // 快照缓存的总字节数上限
+}
+
+func (w *OSWorkspace) newVersionSink() *versionSink {
+	return &versionSink{sum: sha256.New(), buf: &bytes.Buffer{}, limit: w.snapshotLimit()}
+}
+
+func (s *versionSink) Write(p []byte) (int, error) {
+	s.sum.Write(p)
+	if s.buf != nil {
+		if int64(s.buf.Len()+len(p)) > s.limit/4 {
+			s.buf = nil
+		} else {
+			s.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// finish 返回全文哈希，并在保留了全文时放入快照缓存
+func (s *versionSink) finish() string {
+	hash := hex.EncodeToString(s.sum.Sum(nil))
+	if s.buf != nil {
+		readSnapshots.put(hash, s.buf.Bytes(), s.limit)
+	}
+	return hash
+}
+
+// checkIfMatch 校验文件读取时（newFileChange）的内容与 ifMatch 一致；ifMatch 为空时不校验
+func (w *OSWorkspace) checkIfMatch(path string, c *fileChange, ifMatch string) error {
+	ifMatch = strings.ToLower(strings.TrimSpace(ifMatch))
+	if ifMatch == "" {
+		return nil
+	}
+	if !isContentHash(ifMatch) {
+		return fmt.Errorf("invalid ifMatch %q: want the 64-character sha256 etag returned by read_file", ifMatch)
+	}
+	current := contentHash(c.original, c.exists)
+	if current == ifMatch {
+		return nil
+	}
+	conflict := &ConflictError{Path: path, Expected: ifMatch, Actual: current}
+	if old, ok := w.lookupContent(ifMatch); ok {
+		conflict.Diff = unifiedDiff(path, old, c.original, maxConflictDiffLines)
+	}
+	return conflict
+}
+
+// lookupContent 按哈希查找曾经读取或写入的内容：先查快照缓存，再查编辑日志
+func (w *OSWorkspace) lookupContent(hash string) ([]byte, bool) {
+	if data, ok := readSnapshots.get(hash); ok {
+		return data, true
+	}
+	j, err := w.journal()
+	if err != nil || !isContentHash(hash) {
+		return nil, false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	data, err := j.readObject(hash)
+	return data, err == nil
+}
+
+// isContentHash 判断是否为十六进制小写的 sha256
+func isContentHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
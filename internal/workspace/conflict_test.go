@@ -0,0 +1,92 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_IfMatchConflict(t *testing.T) {
+	tmpDir := t.TempDir()
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: tmpDir, MaxFileBytes: 1 << 20})
+	ctx := context.Background()
+	file := filepath.Join(tmpDir, "main.go")
+	os.WriteFile(file, []byte("package main\n\nfunc a() {}\n"), 0644)
+
+	_, etag, err := ws.ReadFileHashed(ctx, "main.go", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, fragEtag, err := ws.ReadCodeFragmentHashed(ctx, "main.go", 1, 1)
+	if err != nil || fragEtag != etag {
+		t.Fatalf("fragment etag %q (err %v), want %q", fragEtag, err, etag)
+	}
+
+	// 读取之后文件被 IDE 修改
+	os.WriteFile(file, []byte("package main\n\nfunc b() {}\n"), 0644)
+
+	err = ws.WriteFile(ctx, "main.go", []byte("package main\n"), false, etag)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if !strings.Contains(conflict.Diff, "-func a() {}\n+func b() {}\n") {
+		t.Errorf("unexpected diff:\n%s", conflict.Diff)
+	}
+	if got := readString(t, file); got != "package main\n\nfunc b() {}\n" {
+		t.Errorf("file overwritten despite conflict: %q", got)
+	}
+
+	if _, err := ws.SearchAndReplace(ctx, ReplaceOptions{Path: "main.go", Edits: []ReplaceEdit{{Old: "b()", New: "c()"}}, IfMatch: etag}); !errors.As(err, &conflict) {
+		t.Errorf("expected SearchAndReplace conflict, got %v", err)
+	}
+	diff := "--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-func b() {}\n+func c() {}\n"
+	if _, err := ws.ApplyPatch(ctx, diff, PatchOptions{IfMatch: map[string]string{"main.go": etag}}); !errors.As(err, &conflict) {
+		t.Errorf("expected ApplyPatch conflict, got %v", err)
+	}
+
+	// 使用当前 ETag 时写入成功，返回的哈希可用于下一次写入
+	_, current, _ := ws.ReadFileHashed(ctx, "main.go", 0)
+	res, err := ws.SearchAndReplace(ctx, ReplaceOptions{Path: "main.go", Edits: []ReplaceEdit{{Old: "b()", New: "c()"}}, IfMatch: current})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := res.Files[0].Hash
+	diff = "--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-func c() {}\n+func d() {}\n"
+	patched, err := ws.ApplyPatch(ctx, diff, PatchOptions{IfMatch: map[string]string{"main.go": next}, DryRun: true})
+	if err != nil {
+		t.Fatalf("ApplyPatch with current etag: %v", err)
+	}
+	if want := ContentHash([]byte("package main\n\nfunc d() {}\n")); patched.Files[0].Hash != want {
+		t.Errorf("patched hash = %s, want %s", patched.Files[0].Hash, want)
+	}
+	if err := ws.WriteFile(ctx, "main.go", []byte("x\n"), false, next); err != nil {
+		t.Fatalf("WriteFile with current etag: %v", err)
+	}
+
+	if err := ws.WriteFile(ctx, "main.go", []byte("y\n"), false, "abc"); err == nil || !strings.Contains(err.Error(), "invalid ifMatch") {
+		t.Errorf("expected invalid ifMatch error, got %v", err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	want := "--- a/x.txt\n+++ b/x.txt\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -10,3 +10,4 @@\n j\n k\n l\n+m\n"
+	if got := unifiedDiff("x.txt", []byte(old), []byte(new), 0); got != want {
+		t.Errorf("unifiedDiff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff("x.txt", []byte(old), []byte(new), 3); !strings.HasSuffix(got, "... (diff truncated)\n") {
+		t.Errorf("expected truncated diff, got:\n%s", got)
+	}
+	if got := unifiedDiff("x.txt", []byte("a\n"), []byte("a"), 0); !strings.Contains(got, "newline at end of file") {
+		t.Errorf("expected newline note, got:\n%s", got)
+	}
+}
@@ -0,0 +1,205 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 本文件实现编译器与静态检查诊断的结构化结果（workspace.diagnostics 使用）：
+//  1. 依次运行 go build（-o /dev/null，不产生二进制）、go vet，以及在命令策略放行且已安装时的 gofmt -l、staticcheck；
+//     每条命令都经 ExecuteStream 执行，命令策略、沙箱与环境变量策略与 secure_exec 相同。
+//  2. 输出中形如 file.go:line[:col]: message 的行解析为 Diagnostic，以制表符开头的续行并入上一条；
+//     gofmt -l 列出的文件记为一条格式诊断。
+//  3. 路径转换为工作区相对路径（工作区外的保持绝对路径），按 file:line:col:message 去重，最多 maxDiagnostics 条。
+
+const maxDiagnostics = 200
+
+// 诊断来源
+const (
+	DiagBuild       = "build"
+	DiagVet         = "vet"
+	DiagGofmt       = "gofmt"
+	DiagStaticcheck = "staticcheck"
+)
+
+// 默认运行的诊断来源；gofmt 与 staticcheck 只在命令策略放行且已安装时运行
+var defaultDiagTools = []string{DiagBuild, DiagVet, DiagGofmt, DiagStaticcheck}
+
+// DiagnosticsOptions Diagnostics 的参数
+type DiagnosticsOptions struct {
+	Packages       []string // 包模式，默认 ./...（gofmt 始终检查工作目录）
+	Tools          []string // 要运行的来源（build / vet / gofmt / staticcheck），为空时运行全部可用来源
+	TimeoutSeconds int64    // 每条命令的超时，<= 0 时使用配置中的 BuildTimeout
+	Cwd            string   // 工作目录（嵌套模块），相对工作区根目录
+}
+
+// Diagnostic 单条诊断
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // error / warning
+	Message  string `json:"message"`
+	Tool     string `json:"tool"`
+}
+
+// DiagnosticRun 单个来源的运行情况
+type DiagnosticRun struct {
+	Tool     string `json:"tool"`
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	Skipped  string `json:"skipped,omitempty"` // 未运行的原因（策略拒绝、未安装）
+	Error    string `json:"error,omitempty"`   // 超时等
+	Count    int    `json:"count"`             // 该来源产生的诊断数（去重前）
+}
+
+// DiagnosticsReport Diagnostics 的结果
+type DiagnosticsReport struct {
+	Diagnostics []Diagnostic    `json:"diagnostics"`
+	Truncated   bool            `json:"truncated"`
+	Runs        []DiagnosticRun `json:"runs"`
+}
+
+var diagLinePattern = regexp.MustCompile(`^(?:vet: )?([^\s:][^:]*\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// Diagnostics 运行编译与静态检查并返回结构化诊断
+func (w *OSWorkspace) Diagnostics(ctx context.Context, opts DiagnosticsOptions) (*DiagnosticsReport, error) {
+	if err := checkPackagePatterns(opts.Packages); err != nil {
+		return nil, err
+	}
+	dir, err := w.execDir(opts.Cwd)
+	if err != nil {
+		return nil, err
+	}
+	tools := opts.Tools
+	explicit := len(tools) > 0
+	if !explicit {
+		tools = defaultDiagTools
+	}
+	pkgs := opts.Packages
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+
+	report := &DiagnosticsReport{Diagnostics: []Diagnostic{}, Runs: []DiagnosticRun{}}
+	seen := make(map[string]bool)
+	for _, tool := range tools {
+		var cmd string
+		var args []string
+		switch tool {
+		case DiagBuild:
+			cmd, args = "go", append([]string{"build", "-o", os.DevNull}, pkgs...)
+		case DiagVet:
+			cmd, args = "go", append([]string{"vet"}, pkgs...)
+		case DiagGofmt:
+			cmd, args = "gofmt", []string{"-l", "."}
+		case DiagStaticcheck:
+			cmd, args = "staticcheck", pkgs
+		default:
+			return nil, fmt.Errorf("unknown diagnostics tool %q (want build, vet, gofmt or staticcheck)", tool)
+		}
+		run := DiagnosticRun{Tool: tool, Command: strings.Join(append([]string{cmd}, args...), " "), ExitCode: -1}
+
+		if d := w.explainCommand(dir, cmd, args); !d.Allowed {
+			run.Skipped = fmt.Sprintf("not allowed by command policy (rule: %s)", d.Rule)
+		} else if _, err := exec.LookPath(cmd); err != nil {
+			run.Skipped = cmd + " not installed"
+		}
+		if run.Skipped != "" {
+			// 默认来源中未放行或未安装的可选工具静默跳过
+			if explicit || tool == DiagBuild || tool == DiagVet {
+				report.Runs = append(report.Runs, run)
+			}
+			continue
+		}
+
+		res, err := w.ExecuteStream(ctx, cmd, args, ExecOptions{TimeoutSeconds: opts.TimeoutSeconds, Cwd: opts.Cwd})
+		run.ExitCode = res.ExitCode
+		if err != nil && res.ExitCode <= 0 {
+			run.Error = err.Error()
+		}
+		var diags []Diagnostic
+		if tool == DiagGofmt {
+			diags = parseGofmtList(res.Stdout)
+		}
+		diags = append(diags, parseDiagnostics(tool, res.Stdout+"\n"+res.Stderr)...)
+		if res.OutputDropped {
+			report.Truncated = true
+		}
+		run.Count = len(diags)
+		report.Runs = append(report.Runs, run)
+
+		for _, d := range diags {
+			d.File = w.diagPath(dir, d.File)
+			key := fmt.Sprintf("%s:%d:%d:%s", d.File, d.Line, d.Column, d.Message)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if len(report.Diagnostics) >= maxDiagnostics {
+				report.Truncated = true
+				continue
+			}
+			report.Diagnostics = append(report.Diagnostics, d)
+		}
+	}
+	return report, nil
+}
+
+// parseDiagnostics 解析 file.go:line[:col]: message 形式的输出；以制表符开头的续行并入上一条
+func parseDiagnostics(tool, output string) []Diagnostic {
+	severity := "warning"
+	if tool == DiagBuild {
+		severity = "error"
+	}
+	var out []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := diagLinePattern.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{File: m[1], Severity: severity, Message: m[4], Tool: tool}
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Column, _ = strconv.Atoi(m[3])
+			// vet 中的类型检查错误（"vet: " 前缀）与 gofmt 的语法错误说明代码无法编译
+			if strings.HasPrefix(line, "vet: ") || tool == DiagGofmt {
+				d.Severity = "error"
+			}
+			out = append(out, d)
+			continue
+		}
+		if strings.HasPrefix(line, "\t") && len(out) > 0 {
+			out[len(out)-1].Message += "\n" + strings.TrimSpace(line)
+		}
+	}
+	return out
+}
+
+// parseGofmtList 解析 gofmt -l 的输出（每行一个未格式化的文件）
+func parseGofmtList(stdout string) []Diagnostic {
+	var out []Diagnostic
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || !strings.HasSuffix(line, ".go") {
+			continue
+		}
+		out = append(out, Diagnostic{File: line, Severity: "warning", Message: "file is not gofmt-formatted", Tool: DiagGofmt})
+	}
+	return out
+}
+
+// diagPath 把相对命令工作目录或绝对的路径转换为工作区相对路径；工作区外的路径保持绝对路径
+func (w *OSWorkspace) diagPath(dir, file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	rel, err := filepath.Rel(w.root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Clean(file)
+	}
+	return filepath.ToSlash(rel)
+}
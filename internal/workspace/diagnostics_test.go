@@ -0,0 +1,111 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_Diagnostics(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"mod/go.mod":           "module example.com/m\n\ngo 1.21\n",
+		"mod/broken/broken.go": "package broken\n\nfunc F() int { return \"x\" }\n",
+		"mod/vetme/vetme.go": `package vetme
+
+import "fmt"
+
+func F() string { return fmt.Sprintf("%d", "x") }
+`,
+		"mod/ugly/ugly.go": "package ugly\n\nfunc  F()  {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		AllowedBuildCommands: []string{"go build", "go vet", "gofmt -l"},
+		BuildTimeout:         120,
+		Env:                  config.EnvPolicy{Set: map[string]string{"GOFLAGS": "-mod=mod", "GOTOOLCHAIN": "local"}},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	report, err := ws.Diagnostics(context.Background(), DiagnosticsOptions{Cwd: "mod"})
+	if err != nil {
+		t.Fatalf("Diagnostics failed: %v", err)
+	}
+	find := func(tool, file string) *Diagnostic {
+		for i, d := range report.Diagnostics {
+			if d.Tool == tool && d.File == file {
+				return &report.Diagnostics[i]
+			}
+		}
+		return nil
+	}
+	if d := find(DiagBuild, "mod/broken/broken.go"); d == nil || d.Line != 3 || d.Column == 0 || d.Severity != "error" {
+		t.Errorf("missing build error for broken.go, got %+v", report.Diagnostics)
+	}
+	if d := find(DiagVet, "mod/vetme/vetme.go"); d == nil || d.Line != 5 || !strings.Contains(d.Message, "Sprintf") {
+		t.Errorf("missing vet warning for vetme.go, got %+v", report.Diagnostics)
+	}
+	if _, err := exec.LookPath("gofmt"); err == nil {
+		if d := find(DiagGofmt, "mod/ugly/ugly.go"); d == nil || d.Severity != "warning" {
+			t.Errorf("missing gofmt diagnostic for ugly.go, got %+v", report.Diagnostics)
+		}
+	}
+	seen := make(map[Diagnostic]bool)
+	for _, d := range report.Diagnostics {
+		if seen[d] {
+			t.Errorf("duplicate diagnostic %+v", d)
+		}
+		seen[d] = true
+	}
+	for _, run := range report.Runs {
+		if run.Tool == DiagStaticcheck {
+			t.Errorf("staticcheck is not allowed and should be skipped silently, got %+v", run)
+		}
+	}
+
+	// 显式请求但未放行的来源记录跳过原因
+	report, err = ws.Diagnostics(context.Background(), DiagnosticsOptions{Cwd: "mod", Tools: []string{DiagStaticcheck}})
+	if err != nil {
+		t.Fatalf("Diagnostics failed: %v", err)
+	}
+	if len(report.Runs) != 1 || report.Runs[0].Skipped == "" {
+		t.Errorf("expected skipped staticcheck run, got %+v", report.Runs)
+	}
+	if _, err := ws.Diagnostics(context.Background(), DiagnosticsOptions{Tools: []string{"lint"}}); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	out := "# example.com/m/p\n" +
+		"vet: p/p.go:4:9: undefined: x\n" +
+		"p/q.go:7:2: cannot use y (variable of type int) as string value\n" +
+		"\thave int\n" +
+		"p/r.go:9: something (SA4006)\n"
+	diags := parseDiagnostics(DiagVet, out)
+	if len(diags) != 3 {
+		t.Fatalf("got %d diagnostics: %+v", len(diags), diags)
+	}
+	if diags[0].File != "p/p.go" || diags[0].Column != 9 || diags[0].Severity != "error" {
+		t.Errorf("type-check error parsed as %+v", diags[0])
+	}
+	if diags[1].Severity != "warning" || !strings.HasSuffix(diags[1].Message, "\nhave int") {
+		t.Errorf("continuation line not merged: %+v", diags[1])
+	}
+	if diags[2].Line != 9 || diags[2].Column != 0 {
+		t.Errorf("line-only position parsed as %+v", diags[2])
+	}
+}
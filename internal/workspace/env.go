@@ -0,0 +1,72 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现执行命令时的环境变量策略（ExecuteStream 使用）：
+//  1. 服务进程的环境只继承匹配 passthrough 的变量，AI_*_API_KEY 等凭据默认不会传给命令。
+//  2. 依次叠加 set 中固定注入的变量与调用方传入的变量（必须匹配 allow_caller_env，否则整条命令被拒绝）。
+//  3. 匹配 redact 的变量无论来源都会被移除；调用方显式传入被 redact 的变量同样拒绝。
+
+// buildEnv 按策略生成命令的环境（KEY=VALUE，按名称排序）
+func buildEnv(p config.EnvPolicy, base []string, caller map[string]string) ([]string, error) {
+	for name := range caller {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, fmt.Errorf("env not allowed: invalid variable name %q", name)
+		}
+		if pattern, ok := matchEnvName(p.Redact, name); ok {
+			return nil, fmt.Errorf("env not allowed: %q is always redacted (rule: env.redact %q)", name, pattern)
+		}
+		if _, ok := matchEnvName(p.AllowCallerEnv, name); !ok {
+			return nil, fmt.Errorf("env not allowed: %q matches none of %v (rule: env.allow_caller_env)", name, p.AllowCallerEnv)
+		}
+	}
+
+	env := make(map[string]string)
+	for _, kv := range base {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if _, ok := matchEnvName(p.Passthrough, name); ok {
+			env[name] = value
+		}
+	}
+	for name, value := range p.Set {
+		env[name] = value
+	}
+	for name, value := range caller {
+		env[name] = value
+	}
+
+	out := make([]string, 0, len(env))
+	for name, value := range env {
+		if _, ok := matchEnvName(p.Redact, name); ok {
+			continue
+		}
+		out = append(out, name+"="+value)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// commandEnv 生成在本工作区执行 command 时的环境
+func (w *OSWorkspace) commandEnv(command string, caller map[string]string) ([]string, error) {
+	return buildEnv(w.cfg.EnvFor(command), os.Environ(), caller)
+}
+
+// matchEnvName 用 * / ? 通配匹配变量名，返回命中的模式
+func matchEnvName(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if argPatternRegexp(pattern).MatchString(name) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
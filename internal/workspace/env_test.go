@@ -0,0 +1,72 @@
+package workspace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestBuildEnv(t *testing.T) {
+	cfg := &config.Config{Env: config.EnvPolicy{
+		Passthrough:    []string{"PATH", "LC_*", "AI_OPENAI_API_KEY"},
+		Set:            map[string]string{"GOFLAGS": "-mod=mod", "GH_TOKEN": "x"},
+		AllowCallerEnv: []string{"CGO_*"},
+		Commands: []config.EnvRule{
+			{Command: "go", Set: map[string]string{"GOFLAGS": "-mod=readonly"}, AllowCallerEnv: []string{"GOOS"}},
+		},
+	}}
+	base := []string{"PATH=/bin", "LC_ALL=C", "HOME=/root", "AI_OPENAI_API_KEY=sk-1", "broken"}
+
+	env, err := buildEnv(cfg.EnvFor("sh"), base, map[string]string{"CGO_ENABLED": "0"})
+	if err != nil {
+		t.Fatalf("buildEnv failed: %v", err)
+	}
+	want := "CGO_ENABLED=0,GOFLAGS=-mod=mod,LC_ALL=C,PATH=/bin"
+	if got := strings.Join(env, ","); got != want {
+		t.Errorf("env = %q, want %q", got, want)
+	}
+
+	// 按命令覆盖与追加
+	env, err = buildEnv(cfg.EnvFor("go"), base, map[string]string{"GOOS": "linux"})
+	if err != nil {
+		t.Fatalf("buildEnv failed: %v", err)
+	}
+	if got := strings.Join(env, ","); !strings.Contains(got, "GOFLAGS=-mod=readonly") || !strings.Contains(got, "GOOS=linux") {
+		t.Errorf("per-command env not applied: %q", got)
+	}
+
+	// 调用方传入未允许或被 redact 的变量
+	for _, name := range []string{"GOOS", "CGO_API_KEY", "A=B"} {
+		if _, err := buildEnv(cfg.EnvFor("sh"), base, map[string]string{name: "v"}); err == nil {
+			t.Errorf("expected caller env %q to be rejected", name)
+		}
+	}
+}
+
+func TestOSWorkspace_ExecuteEnv(t *testing.T) {
+	t.Setenv("AI_GEMINI_API_KEY", "secret-key")
+	cfg := &config.Config{
+		RootDir:              t.TempDir(),
+		AllowedBuildCommands: []string{"sh"},
+		BuildTimeout:         10,
+		Env:                  config.EnvPolicy{AllowCallerEnv: []string{"FOO"}},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	res, err := ws.ExecuteStream(context.Background(), "sh", []string{"-c", "env"}, ExecOptions{Env: map[string]string{"FOO": "bar"}})
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+	if strings.Contains(res.Stdout, "secret-key") {
+		t.Error("API key leaked into command environment")
+	}
+	if !strings.Contains(res.Stdout, "FOO=bar") || !strings.Contains(res.Stdout, "PATH=") {
+		t.Errorf("unexpected environment: %q", res.Stdout)
+	}
+
+	if _, err := ws.ExecuteStream(context.Background(), "sh", []string{"-c", "true"}, ExecOptions{Env: map[string]string{"BAR": "1"}}); err == nil || !strings.Contains(err.Error(), "env not allowed") {
+		t.Errorf("expected env not allowed error, got %v", err)
+	}
+}
@@ -0,0 +1,385 @@
+package workspace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 本文件实现命令输出的流式处理与存储（ExecuteStream 使用）：
+//  1. ringBuffer：固定容量的环形缓冲区，只保留最近的输出，长时间运行的命令也不会占满内存。
+//  2. execLog：每次执行的完整输出（stdout 与 stderr 按到达顺序交织）写入当前用户缓存目录下的日志文件
+//     （$XDG_CACHE_HOME/agentcode-mcp/exec-logs，权限 0700，使用前校验不是符号链接且属于当前用户），
+//     单个日志最多 maxExecLogBytes，目录中只保留最近 execLogKeep 个、总计不超过 maxExecLogTotalBytes 的日志
+//     （仍在写入的日志与 JobManager 仍持有的后台任务日志不会被清理）；LowResourceMode 下三项上限都更小。
+//  3. ReadExecLog：按 offset/limit 分段读取日志，命令运行期间也可读取。
+//  4. ExecOptions.OnOutput：输出到达时立即回调（MCP 层据此发送 progress 通知）。
+
+const (
+	execRingBytes = 64 << 10 // 每个输出流在内存中保留的字节数
+	execWaitDelay = 2 * time.Second
+
+	execLogKeep                     = 100
+	maxExecLogBytes                 = 32 << 20
+	maxExecLogTotalBytes            = 512 << 20
+	lowResourceExecLogKeep          = 20
+	lowResourceMaxExecLogBytes      = 4 << 20
+	lowResourceMaxExecLogTotalBytes = 32 << 20
+)
+
+// execLogLimits 执行日志的保留上限
+type execLogLimits struct {
+	keep       int   // 目录中保留的日志个数
+	maxBytes   int64 // 单个日志的字节数上限
+	totalBytes int64 // 目录中全部日志的字节数上限
+}
+
+var execLogIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// activeLogs 仍在写入的日志文件路径（清理时跳过，避免删除长时间运行的后台任务日志）
+var activeLogs sync.Map
+
+// retainedLogs JobManager 仍持有的后台任务日志路径（清理时跳过，任务记录删除时由 RemoveExecLog 释放），
+// 否则已结束但仍可通过 job_output 查看的任务会因为其他命令产生新日志而丢失输出
+var retainedLogs sync.Map
+
+// OutputFunc 输出回调，stream 为 "stdout" 或 "stderr"；chunk 仅在回调期间有效，需要保留时应复制
+type OutputFunc func(stream string, chunk []byte)
+
+// ExecOptions ExecuteStream 的可选参数
+type ExecOptions struct {
+	TimeoutSeconds int64                       // <= 0 时使用配置中的 BuildTimeout
+	NoTimeout      bool                        // 不设超时，只随 ctx 取消（后台任务）
+	OnOutput       OutputFunc                  // 可为 nil
+	OnStart        func(pid int, logID string) // 进程启动后回调，可为 nil
+	Env            map[string]string           // 调用方额外传入的环境变量，须经 env.allow_caller_env 允许
+	Cwd            string                      // 工作目录（相对工作区根目录），须为工作区内的目录，空则为根目录
+	Stdin          string                      // 标准输入内容，与 StdinFile 互斥
+	StdinFile      string                      // 以工作区内的文件作为标准输入，与 Stdin 互斥
+}
+
+// ExecResult ExecuteStream 的执行结果
+type ExecResult struct {
+	Stdout        string // stdout 最近 execRingBytes 字节
+	Stderr        string // stderr 最近 execRingBytes 字节
+	ExitCode      int
+	OutputDropped bool   // 环形缓冲区是否丢弃过输出（完整内容见日志）
+	LogID         string // 完整输出日志 ID（创建日志失败时为空）
+	LogBytes      int64  // 日志字节数
+}
+
+// outputFuncWriter 将写入转发给 OutputFunc
+type outputFuncWriter struct {
+	stream string
+	fn     OutputFunc
+}
+
+func (o outputFuncWriter) Write(p []byte) (int, error) {
+	o.fn(o.stream, p)
+	return len(p), nil
+}
+
+// ExecLogChunk 日志分段读取结果
+type ExecLogChunk struct {
+	LogID      string `json:"log_id"`
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	Size       int64  `json:"size"` // 读取时的日志总字节数（命令仍在运行时会继续增长）
+	EOF        bool   `json:"eof"`
+	Content    string `json:"content"`
+}
+
+// ringBuffer 固定容量的环形缓冲区，并发安全
+type ringBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	next  int // 下一次写入的位置
+	full  bool
+	total int64 // 累计写入字节数
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{data: make([]byte, size)}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	r.total += int64(n)
+	if n >= len(r.data) {
+		copy(r.data, p[n-len(r.data):])
+		r.next = 0
+		r.full = true
+		return n, nil
+	}
+	if r.next+n >= len(r.data) {
+		r.full = true
+	}
+	c := copy(r.data[r.next:], p)
+	copy(r.data, p[c:])
+	r.next = (r.next + n) % len(r.data)
+	return n, nil
+}
+
+// String 返回缓冲区中的内容；发生丢弃时去掉开头不完整的 UTF-8 字符
+func (r *ringBuffer) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return string(r.data[:r.next])
+	}
+	out := make([]byte, 0, len(r.data))
+	out = append(out, r.data[r.next:]...)
+	out = append(out, r.data[:r.next]...)
+	for len(out) > 0 && !utf8.RuneStart(out[0]) {
+		out = out[1:]
+	}
+	return string(out)
+}
+
+// Dropped 返回是否有输出因超出容量被丢弃
+func (r *ringBuffer) Dropped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total > int64(len(r.data))
+}
+
+// execLog 单次执行的完整输出日志，写入失败不影响命令执行
+type execLog struct {
+	id       string
+	mu       sync.Mutex
+	f        *os.File
+	written  int64
+	maxBytes int64
+}
+
+// execLogLimits 返回执行日志的保留上限
+func (w *OSWorkspace) execLogLimits() execLogLimits {
+	if w.cfg.LowResourceMode {
+		return execLogLimits{keep: lowResourceExecLogKeep, maxBytes: lowResourceMaxExecLogBytes, totalBytes: lowResourceMaxExecLogTotalBytes}
+	}
+	return execLogLimits{keep: execLogKeep, maxBytes: maxExecLogBytes, totalBytes: maxExecLogTotalBytes}
+}
+
+// execLogDir 日志目录（当前用户的缓存目录下，其他用户无法预先创建或替换）
+func execLogDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine cache dir for exec logs: %w", err)
+	}
+	return filepath.Join(cache, "agentcode-mcp", "exec-logs"), nil
+}
+
+// ensurePrivateDir 创建权限为 0700 的目录，并确认它不是符号链接、属于当前用户且其他用户不可访问
+func ensurePrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if err := checkDirOwner(dir, info); err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return os.Chmod(dir, 0700)
+	}
+	return nil
+}
+
+// newExecLog 创建新的日志文件，并按 limits 清理过旧的日志
+func newExecLog(limits execLogLimits) (*execLog, error) {
+	dir, err := execLogDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensurePrivateDir(dir); err != nil {
+		return nil, fmt.Errorf("exec log dir: %w", err)
+	}
+	var raw [8]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate log id: %w", err)
+	}
+	id := hex.EncodeToString(raw[:])
+	f, err := os.OpenFile(filepath.Join(dir, id+".log"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec log: %w", err)
+	}
+	activeLogs.Store(f.Name(), true)
+	pruneExecLogs(dir, limits.keep, limits.totalBytes)
+	return &execLog{id: id, f: f, maxBytes: limits.maxBytes}, nil
+}
+
+func (l *execLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if remain := l.maxBytes - l.written; remain > 0 {
+		chunk := p
+		if int64(len(chunk)) > remain {
+			chunk = chunk[:remain]
+		}
+		n, _ := l.f.Write(chunk)
+		l.written += int64(n)
+	}
+	return len(p), nil
+}
+
+// Size 返回已写入日志的字节数
+func (l *execLog) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.written
+}
+
+func (l *execLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	activeLogs.Delete(l.f.Name())
+	return l.f.Close()
+}
+
+// retainExecLog 使日志不被 pruneExecLogs 清理，直到 RemoveExecLog 删除它
+func retainExecLog(id string) {
+	if dir, err := execLogDir(); err == nil && execLogIDPattern.MatchString(id) {
+		retainedLogs.Store(filepath.Join(dir, id+".log"), true)
+	}
+}
+
+// RemoveExecLog 删除指定的执行日志（后台任务清理时使用）
+func RemoveExecLog(id string) error {
+	if !execLogIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid log id %q", id)
+	}
+	dir, err := execLogDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, id+".log")
+	retainedLogs.Delete(path)
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// pruneExecLogs 按修改时间从旧到新删除日志，直到最多剩 keep 个且总大小不超过 totalBytes
+// （仍在写入的日志与后台任务持有的日志计入总数与总大小，但不会被删除）
+func pruneExecLogs(dir string, keep int, totalBytes int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type logFile struct {
+		path    string
+		size    int64
+		modTime int64
+	}
+	var files []logFile
+	count, total := 0, int64(0)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		count++
+		total += info.Size()
+		path := filepath.Join(dir, e.Name())
+		if _, active := activeLogs.Load(path); active {
+			continue
+		}
+		if _, retained := retainedLogs.Load(path); retained {
+			continue
+		}
+		files = append(files, logFile{path: path, size: info.Size(), modTime: info.ModTime().UnixNano()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime < files[j].modTime })
+	for _, f := range files {
+		if count <= keep && total <= totalBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			count--
+			total -= f.size
+		}
+	}
+}
+
+// ReadExecLog 从 offset 开始读取最多 limit 字节的执行日志（不会在 UTF-8 字符中间截断）
+func ReadExecLog(id string, offset, limit int64) (*ExecLogChunk, error) {
+	if !execLogIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid log id %q", id)
+	}
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid offset/limit: %d/%d", offset, limit)
+	}
+	dir, err := execLogDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, id+".log"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("exec log %q not found (older logs are pruned)", id)
+		}
+		return nil, fmt.Errorf("failed to open exec log: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat exec log: %w", err)
+	}
+	size := info.Size()
+	if offset > size {
+		offset = size
+	}
+
+	buf := make([]byte, min(limit, size-offset))
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read exec log: %w", err)
+	}
+	buf = buf[:n]
+	if offset+int64(n) < size {
+		buf = trimPartialRune(buf)
+	}
+
+	next := offset + int64(len(buf))
+	return &ExecLogChunk{
+		LogID:      id,
+		Offset:     offset,
+		NextOffset: next,
+		Size:       size,
+		EOF:        next >= size,
+		Content:    string(buf),
+	}, nil
+}
+
+// trimPartialRune 去掉末尾不完整的 UTF-8 字符（留给下一段读取）
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
@@ -0,0 +1,19 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkDirOwner 确认目录属于当前用户（防止其他用户预先创建日志目录）
+func checkDirOwner(dir string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot determine owner of %s", dir)
+	}
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by uid %d, not the current user", dir, st.Uid)
+	}
+	return nil
+}
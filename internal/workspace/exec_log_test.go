@@ -0,0 +1,190 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(8)
+	r.Write([]byte("abc"))
+	if got := r.String(); got != "abc" || r.Dropped() {
+		t.Errorf("String() = %q, dropped %v", got, r.Dropped())
+	}
+	r.Write([]byte("defgh"))
+	r.Write([]byte("ij"))
+	if got := r.String(); got != "cdefghij" || !r.Dropped() {
+		t.Errorf("String() = %q, dropped %v, want cdefghij", got, r.Dropped())
+	}
+	r.Write([]byte("0123456789"))
+	if got := r.String(); got != "23456789" {
+		t.Errorf("String() = %q, want 23456789", got)
+	}
+
+	// 丢弃导致开头残缺的多字节字符被去掉
+	u := newRingBuffer(4)
+	u.Write([]byte("中ab"))
+	u.Write([]byte("c"))
+	if got := u.String(); got != "abc" {
+		t.Errorf("String() = %q, want abc", got)
+	}
+}
+
+func TestOSWorkspace_ExecuteStream(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir := t.TempDir()
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		BuildTimeout:         5,
+		AllowedBuildCommands: []string{"sh"},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	var mu sync.Mutex
+	var streamed strings.Builder
+	res, err := ws.ExecuteStream(context.Background(), "sh", []string{"-c", "echo out; echo 中文 >&2"}, ExecOptions{
+		OnOutput: func(stream string, chunk []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			streamed.WriteString(stream + ":" + string(chunk))
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+	if res.Stdout != "out\n" || res.Stderr != "中文\n" || res.ExitCode != 0 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if !strings.Contains(streamed.String(), "stdout:out\n") || !strings.Contains(streamed.String(), "stderr:中文\n") {
+		t.Errorf("callback missed output: %q", streamed.String())
+	}
+	if res.LogID == "" {
+		t.Fatal("expected log id")
+	}
+	// 日志写入当前用户缓存目录下仅本人可访问的目录
+	if info, err := os.Stat(filepath.Join(cache, "agentcode-mcp", "exec-logs")); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("exec log dir: %v, %v", info, err)
+	}
+
+	// 完整日志可按 offset 分段读取，且不会切断多字节字符
+	full, err := ReadExecLog(res.LogID, 0, 1024)
+	if err != nil {
+		t.Fatalf("ReadExecLog failed: %v", err)
+	}
+	if full.Size != res.LogBytes || !full.EOF || !strings.Contains(full.Content, "中文") {
+		t.Errorf("unexpected log: %+v", full)
+	}
+	res, err = ws.ExecuteStream(context.Background(), "sh", []string{"-c", "printf 'ab中文'"}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+	part, err := ReadExecLog(res.LogID, 2, 4) // "中" 占 3 字节，第 4 个字节属于 "文"，留给下一段
+	if err != nil {
+		t.Fatalf("ReadExecLog failed: %v", err)
+	}
+	if part.Content != "中" || part.NextOffset != 5 || part.EOF {
+		t.Errorf("unexpected chunk: %+v", part)
+	}
+
+	if _, err := ReadExecLog("../secret", 0, 10); err == nil {
+		t.Error("expected invalid id error")
+	}
+}
+
+func TestPruneExecLogs(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%016x.log", i))
+		os.WriteFile(path, make([]byte, 100), 0600)
+		mtime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(path, mtime, mtime)
+	}
+	names := func() string {
+		entries, _ := os.ReadDir(dir)
+		var out []string
+		for _, e := range entries {
+			out = append(out, strings.TrimLeft(strings.TrimSuffix(e.Name(), ".log"), "0"))
+		}
+		return strings.Join(out, ",")
+	}
+
+	// 按个数保留最新的日志
+	pruneExecLogs(dir, 4, 1<<20)
+	if got := names(); got != "1,2,3,4" {
+		t.Errorf("after keep=4: %s", got)
+	}
+	// 按总大小继续删除最旧的日志
+	pruneExecLogs(dir, 4, 250)
+	if got := names(); got != "3,4" {
+		t.Errorf("after totalBytes=250: %s", got)
+	}
+	// 后台任务持有的日志即使最旧也不会被删除
+	retained := filepath.Join(dir, fmt.Sprintf("%016x.log", 3))
+	retainedLogs.Store(retained, true)
+	defer retainedLogs.Delete(retained)
+	pruneExecLogs(dir, 1, 1<<20)
+	if got := names(); got != "3" {
+		t.Errorf("after keep=1 with retained log: %s", got)
+	}
+}
+
+func TestOSWorkspace_ExecLogLimits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg := &config.Config{RootDir: t.TempDir(), BuildTimeout: 5, AllowedBuildCommands: []string{"sh"}, LowResourceMode: true}
+	w, _ := NewOSWorkspace(cfg)
+	ws := w.(*OSWorkspace)
+	if l := ws.execLogLimits(); l.keep != lowResourceExecLogKeep || l.totalBytes != lowResourceMaxExecLogTotalBytes {
+		t.Errorf("low resource limits = %+v", l)
+	}
+
+	// 单个日志超过上限的部分不写入磁盘
+	res, err := ws.ExecuteStream(context.Background(), "sh", []string{"-c", "head -c 5000000 /dev/zero"}, ExecOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LogBytes != lowResourceMaxExecLogBytes {
+		t.Errorf("LogBytes = %d, want %d", res.LogBytes, lowResourceMaxExecLogBytes)
+	}
+}
+
+func TestEnsurePrivateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions")
+	}
+	base := t.TempDir()
+
+	// 已存在但权限过宽的目录被收紧为 0700
+	loose := filepath.Join(base, "loose")
+	os.Mkdir(loose, 0755)
+	if err := ensurePrivateDir(loose); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(loose); info.Mode().Perm() != 0700 {
+		t.Errorf("mode = %v, want 0700", info.Mode().Perm())
+	}
+
+	// 指向其他位置的符号链接被拒绝
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(t.TempDir(), link); err != nil {
+		t.Skip(err)
+	}
+	if err := ensurePrivateDir(link); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("expected symlink to be rejected, got %v", err)
+	}
+}
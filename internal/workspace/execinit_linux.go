@@ -0,0 +1,120 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现执行命令前的初始化进程（配置了资源限制或沙箱时 ExecuteStream 使用）：
+//  1. 父进程重新启动自身，参数为 execInitArg + 初始化描述 + 原命令；初始化进程（MaybeRunExecInit）
+//     按需建立沙箱（见 sandbox_linux.go）、设置 rlimit，然后 exec 原命令，pid 与进程组保持不变。
+//  2. 资源限制在 exec 之前设置，原命令从第一条指令起就受限制，不存在启动后再 prlimit 的竞争窗口。
+//  3. 初始化失败时通过状态管道（fd 3，exec 成功后自动关闭）把错误传回父进程，原命令不会执行。
+
+const (
+	execInitArg    = "__agentcode_exec_init"
+	execStatusFD   = 3
+	execInitFailed = 125
+)
+
+// execInitSpec 父进程传给初始化进程的描述
+type execInitSpec struct {
+	Path    string                `json:"path"`              // 原命令的可执行文件路径
+	Limits  config.ResourceLimits `json:"limits"`            // 需要设置的资源限制
+	Sandbox *sandboxSpec          `json:"sandbox,omitempty"` // 非空时建立沙箱
+}
+
+// execInit 一次经由初始化进程执行的父进程侧状态
+type execInit struct {
+	sandbox bool     // 是否建立沙箱（决定错误类型）
+	status  *os.File // 状态管道读端
+	child   *os.File // 状态管道写端（启动后关闭）
+}
+
+// setupExecInit 把 execCmd 改写为经由初始化进程执行；不需要初始化（无限制且不启用沙箱）时返回 nil
+func setupExecInit(execCmd *exec.Cmd, root string, limits config.ResourceLimits, sb config.SandboxConfig) (*execInit, error) {
+	if execCmd.Err != nil || (!sb.Enabled && limits == (config.ResourceLimits{})) {
+		// 命令不存在等错误留给 Start 报告
+		return nil, nil
+	}
+	spec := execInitSpec{Path: execCmd.Path, Limits: limits}
+	if sb.Enabled {
+		spec.Sandbox = newSandboxSpec(execCmd, root, sb)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode exec init spec: %w", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec init status pipe: %w", err)
+	}
+	execCmd.Args = append([]string{"agentcode-exec", execInitArg, string(data)}, execCmd.Args...)
+	execCmd.Path = "/proc/self/exe"
+	execCmd.ExtraFiles = []*os.File{w}
+	return &execInit{sandbox: sb.Enabled, status: r, child: w}, nil
+}
+
+// startError 包装 Start 的错误：沙箱无法创建命名空间时给出明确提示
+func (p *execInit) startError(err error) error {
+	if p.sandbox {
+		return sandboxStartError(err)
+	}
+	return err
+}
+
+// wait 等待初始化进程 exec 原命令；初始化失败时返回其报告的错误
+func (p *execInit) wait() error {
+	p.child.Close()
+	p.child = nil
+	msg, err := io.ReadAll(p.status)
+	if err == nil && len(msg) == 0 {
+		return nil
+	}
+	if err != nil {
+		msg = []byte("failed to read init status: " + err.Error())
+	}
+	if p.sandbox {
+		return fmt.Errorf("%w: %s", ErrSandboxUnavailable, msg)
+	}
+	return fmt.Errorf("execution failed: %s", msg)
+}
+
+// Close 释放状态管道
+func (p *execInit) Close() {
+	if p.child != nil {
+		p.child.Close()
+	}
+	p.status.Close()
+}
+
+// MaybeRunExecInit 若当前进程是初始化进程，则完成初始化并 exec 原命令（不返回）；否则立即返回。
+// 必须在 main（以及测试的 TestMain）最开始调用。
+func MaybeRunExecInit() {
+	if len(os.Args) < 4 || os.Args[1] != execInitArg {
+		return
+	}
+	// 能力集与 no_new_privs 是线程属性，必须在 exec 所在的线程上设置
+	runtime.LockOSThread()
+	syscall.CloseOnExec(execStatusFD)
+	status := os.NewFile(execStatusFD, "exec-init-status")
+
+	var spec execInitSpec
+	err := json.Unmarshal([]byte(os.Args[2]), &spec)
+	if err == nil && spec.Sandbox != nil {
+		err = initSandbox(*spec.Sandbox)
+	}
+	if err == nil {
+		err = execWithLimits(spec.Path, os.Args[3:], os.Environ(), spec.Limits)
+	}
+	_, _ = status.WriteString(err.Error())
+	os.Exit(execInitFailed)
+}
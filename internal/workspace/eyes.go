@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,17 +15,19 @@ import (
 // TODO(eyes_file_overview):
 //  本文件实现“空间感知模块（The Eyes）”：
 //  1. TreeNode：用于表示目录树节点，包含 path / is_dir / size / mod_time / children。
-//  2. InspectWorkspace：基于 filepath.WalkDir 构建目录树结果，应用内置 ignore 列表和 maxDepth。
-//  3. ReadCodeFragment：基于 os.Open + bufio.Scanner 按行号读取代码片段，文件超过 20KB 时强制分页。
+//  2. InspectWorkspace：基于 filepath.WalkDir 构建目录树结果，应用 .gitignore 语义的忽略规则和 maxDepth。
+//  3. InspectWorkspaceTree / RenderTree：把扁平列表组装为嵌套树（带目录聚合大小与文件数），或渲染为紧凑 ASCII 树。
+//  4. ReadCodeFragment：基于 os.Open + bufio.Scanner 按行号读取代码片段，文件超过 20KB 时强制分页。
 //  使用本文件时，请按每个函数上方的 TODO 步骤检查/完善实现。
 
 // TreeNode 目录树节点
 type TreeNode struct {
-	Path     string      `json:"path"`               // 从工作区根目录开始的相对路径
-	IsDir    bool        `json:"is_dir"`             // 是否为目录
-	Size     int64       `json:"size"`               // 文件大小（字节），目录为 0
-	ModTime  time.Time   `json:"mod_time"`           // 最后修改时间（序列化为 RFC3339 字符串）
-	Children []*TreeNode `json:"children,omitempty"` // 子节点列表（目前实现返回扁平列表，children 预留用于扩展）
+	Path      string      `json:"path"`                 // 从工作区根目录开始的相对路径
+	IsDir     bool        `json:"is_dir"`               // 是否为目录
+	Size      int64       `json:"size"`                 // 文件大小（字节）；扁平列表中目录为 0，嵌套树中为子树文件总大小
+	ModTime   time.Time   `json:"mod_time"`             // 最后修改时间（序列化为 RFC3339 字符串）
+	FileCount int         `json:"file_count,omitempty"` // 子树中的文件数（仅嵌套树中的目录节点）
+	Children  []*TreeNode `json:"children,omitempty"`   // 子节点列表（InspectWorkspace 返回扁平列表，InspectWorkspaceTree 填充）
 }
 
 // InspectWorkspace 扫描工作区目录树
@@ -33,9 +36,9 @@ type TreeNode struct {
 //  1. 使用 w.sanitizePath(relPath) 将用户输入转换为安全的绝对路径 absPath。
 //  2. 使用 os.Stat(absPath) 确保其存在且为目录，否则返回错误。
 //  3. 如果 maxDepth <= 0，则使用安全默认值 2；在 LowResourceMode 下可考虑进一步收紧。
-//  4. 定义 ignoreDirs 和 hiddenPrefixes：
-//     - ignoreDirs 用于跳过 .git/node_modules/dist 等典型构建产物或 IDE 目录。
-//     - hiddenPrefixes 用于跳过隐藏文件，如 .DS_Store。
+//  4. 使用 w.newIgnoreMatcher() 构建忽略规则（见 ignore.go）：
+//     - 内置规则跳过 .git/node_modules/dist 等典型构建产物或 IDE 目录；
+//     - 再叠加 .git/info/exclude、各级 .gitignore 和 .agentignore。
 //  5. 使用 filepath.WalkDir 遍历：
 //     - 每个回调中检查 ctx.Done()，支持取消；
 //     - 通过 filepath.Rel(w.root, path) 计算相对路径 rel；
@@ -65,26 +68,7 @@ func (w *OSWorkspace) InspectWorkspace(ctx context.Context, relPath string, maxD
 		maxDepth = 2 // 默认递归深度
 	}
 
-	// 内置忽略列表（常见开发环境目录）
-	ignoreDirs := map[string]bool{
-		".git":         true,
-		"node_modules": true,
-		"dist":         true,
-		"build":        true,
-		".next":        true,
-		"vendor":       true,
-		".cache":       true,
-		".venv":        true,
-		"__pycache__":  true,
-		".idea":        true,
-		".vscode":      true,
-		"coverage":     true,
-		".nyc_output":  true,
-	}
-
-	// 不需要在 JSON 中输出的隐藏文件/目录（如 .DS_Store）
-	hiddenPrefixes := []string{".", ".DS_Store"}
-
+	ignore := w.newIgnoreMatcher()
 	var nodes []*TreeNode
 
 	// 使用 filepath.WalkDir 进行扫描
@@ -122,23 +106,12 @@ func (w *OSWorkspace) InspectWorkspace(ctx context.Context, relPath string, maxD
 			return nil
 		}
 
-		// 忽略特定目录
-		if d.IsDir() {
-			dirName := d.Name()
-			if ignoreDirs[dirName] || strings.HasPrefix(dirName, ".") {
+		// 应用 .gitignore 语义的忽略规则（起点目录本身不参与匹配）
+		if path != absPath && ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
 				return filepath.SkipDir
 			}
-		}
-
-		// 忽略隐藏文件（点号开头的文件，Unix 风格）
-		baseName := d.Name()
-		for _, prefix := range hiddenPrefixes {
-			if strings.HasPrefix(baseName, prefix) {
-				if d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil // 跳过文件
-			}
+			return nil // 跳过文件
 		}
 
 		// 构建 TreeNode
@@ -186,6 +159,120 @@ func (w *OSWorkspace) InspectWorkspace(ctx context.Context, relPath string, maxD
 	return nodes, nil
 }
 
+// InspectWorkspaceTree 扫描工作区并返回嵌套目录树，根节点为 relPath 对应的目录
+// 目录节点的 Size/FileCount 为 maxDepth 范围内子树的聚合值
+func (w *OSWorkspace) InspectWorkspaceTree(ctx context.Context, relPath string, maxDepth int) (*TreeNode, error) {
+	nodes, err := w.InspectWorkspace(ctx, relPath, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := w.sanitizePath(relPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relPath: %w", err)
+	}
+	rootRel, err := filepath.Rel(w.root, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute relative path: %w", err)
+	}
+	return buildTree(nodes, rootRel), nil
+}
+
+// buildTree 将 InspectWorkspace 返回的扁平节点按父子关系挂接，并自底向上计算聚合值
+func buildTree(nodes []*TreeNode, rootRel string) *TreeNode {
+	byPath := make(map[string]*TreeNode, len(nodes))
+	for _, n := range nodes {
+		byPath[n.Path] = n
+	}
+	root, ok := byPath[rootRel]
+	if !ok {
+		root = &TreeNode{Path: rootRel, IsDir: true}
+		byPath[rootRel] = root
+	}
+
+	// nodes 已按目录优先 + 路径排序，挂接后子节点顺序保持不变
+	for _, n := range nodes {
+		if n == root {
+			continue
+		}
+		parent, ok := byPath[filepath.Dir(n.Path)]
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, n)
+	}
+
+	aggregateTree(root)
+	return root
+}
+
+// aggregateTree 递归计算目录的文件总大小和文件数
+func aggregateTree(node *TreeNode) (size int64, files int) {
+	if !node.IsDir {
+		return node.Size, 1
+	}
+	for _, child := range node.Children {
+		s, f := aggregateTree(child)
+		size += s
+		files += f
+	}
+	node.Size = size
+	node.FileCount = files
+	return size, files
+}
+
+// RenderTree 将嵌套树渲染为紧凑的 ASCII 文本（比 JSON 节省大量 token）
+func RenderTree(root *TreeNode) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s/ (%s)\n", filepath.ToSlash(root.Path), describeNode(root))
+	renderChildren(&sb, root, "")
+	return sb.String()
+}
+
+// renderChildren 递归输出子节点，prefix 为当前层级的缩进前缀
+func renderChildren(sb *strings.Builder, node *TreeNode, prefix string) {
+	for i, child := range node.Children {
+		last := i == len(node.Children)-1
+		branch, nextPrefix := "├── ", prefix+"│   "
+		if last {
+			branch, nextPrefix = "└── ", prefix+"    "
+		}
+		name := filepath.Base(child.Path)
+		if child.IsDir {
+			name += "/"
+		}
+		fmt.Fprintf(sb, "%s%s%s (%s)\n", prefix, branch, name, describeNode(child))
+		if child.IsDir {
+			renderChildren(sb, child, nextPrefix)
+		}
+	}
+}
+
+// describeNode 节点摘要：文件为大小，目录为文件数 + 总大小
+func describeNode(n *TreeNode) string {
+	if !n.IsDir {
+		return formatBytes(n.Size)
+	}
+	unit := "files"
+	if n.FileCount == 1 {
+		unit = "file"
+	}
+	return fmt.Sprintf("%d %s, %s", n.FileCount, unit, formatBytes(n.Size))
+}
+
+// formatBytes 将字节数格式化为易读形式（B / KB / MB / GB）
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGT"[exp])
+}
+
 // ReadCodeFragment 按行读取代码片段
 // startLine 和 endLine 都是从 1 开始计数（1-indexed）
 // TODO(eyes_read_code_fragment_impl):
@@ -200,6 +287,21 @@ func (w *OSWorkspace) InspectWorkspace(ctx context.Context, relPath string, maxD
 //  5. 扫描过程中定期检查 ctx.Done()，支持取消。
 //  6. 如果实际扫描到的行数 < 请求的 endLine，可将 truncated 置为 true 提示 Agent。
 func (w *OSWorkspace) ReadCodeFragment(ctx context.Context, path string, startLine, endLine int) (lines []string, truncated bool, err error) {
+	return w.readCodeFragment(ctx, path, startLine, endLine, nil)
+}
+
+// ReadCodeFragmentHashed 按行范围读取文件，同时返回全文的 sha256（读完片段后继续读完文件计算哈希）
+func (w *OSWorkspace) ReadCodeFragmentHashed(ctx context.Context, path string, startLine, endLine int) (lines []string, truncated bool, hash string, err error) {
+	sink := w.newVersionSink()
+	lines, truncated, err = w.readCodeFragment(ctx, path, startLine, endLine, sink)
+	if err != nil {
+		return nil, false, "", err
+	}
+	return lines, truncated, sink.finish(), nil
+}
+
+// readCodeFragment 按行范围读取文件；sink 不为 nil 时同时接收文件的全部字节
+func (w *OSWorkspace) readCodeFragment(ctx context.Context, path string, startLine, endLine int, sink io.Writer) (lines []string, truncated bool, err error) {
 	// 参数校验
 	if startLine <= 0 || endLine < startLine {
 		return nil, false, fmt.Errorf("invalid line range: %d-%d", startLine, endLine)
@@ -241,7 +343,11 @@ func (w *OSWorkspace) ReadCodeFragment(ctx context.Context, path string, startLi
 	}
 
 	// 流式读取每一行
-	scanner := bufio.NewScanner(file)
+	var src io.Reader = file
+	if sink != nil {
+		src = io.TeeReader(file, sink)
+	}
+	scanner := bufio.NewScanner(src)
 	currentLine := 1
 	var result []string
 
@@ -265,6 +371,12 @@ func (w *OSWorkspace) ReadCodeFragment(ctx context.Context, path string, startLi
 	if err := scanner.Err(); err != nil {
 		return nil, false, fmt.Errorf("scan error: %w", err)
 	}
+	// 片段之后的内容只计入 sink
+	if sink != nil {
+		if _, err := io.Copy(io.Discard, src); err != nil {
+			return nil, false, fmt.Errorf("read error: %w", err)
+		}
+	}
 
 	// 返回实际读取的行数少于请求时，标记 truncated
 	truncated = currentLine < endLine
@@ -97,3 +97,50 @@ func TestOSWorkspace_ReadCodeFragment(t *testing.T) {
 		t.Error("expected error for invalid startLine")
 	}
 }
+
+func TestOSWorkspace_InspectWorkspaceTree(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir: tmpDir,
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	os.MkdirAll(filepath.Join(tmpDir, "src", "app"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "README.md"), []byte("readme"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "src", "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "src", "app", "utils.go"), []byte("package app"), 0644)
+
+	root, err := ws.InspectWorkspaceTree(context.Background(), ".", 3)
+	if err != nil {
+		t.Fatalf("InspectWorkspaceTree failed: %v", err)
+	}
+	if root.Path != "." || !root.IsDir {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	if root.FileCount != 3 || root.Size != int64(len("readme")+len("package main")+len("package app")) {
+		t.Errorf("unexpected root aggregate: files=%d size=%d", root.FileCount, root.Size)
+	}
+	if len(root.Children) != 2 || root.Children[0].Path != "src" || root.Children[1].Path != "README.md" {
+		t.Fatalf("unexpected root children: %+v", root.Children)
+	}
+	src := root.Children[0]
+	if src.FileCount != 2 || len(src.Children) != 2 {
+		t.Errorf("unexpected src node: files=%d children=%d", src.FileCount, len(src.Children))
+	}
+
+	// 子目录作为根
+	sub, err := ws.InspectWorkspaceTree(context.Background(), "src", 3)
+	if err != nil {
+		t.Fatalf("InspectWorkspaceTree(src) failed: %v", err)
+	}
+	if sub.Path != "src" || sub.FileCount != 2 {
+		t.Errorf("unexpected subtree root: %+v", sub)
+	}
+
+	ascii := RenderTree(root)
+	for _, want := range []string{"./ (3 files, 29 B)", "├── src/ (2 files, 23 B)", "│   ├── app/ (1 file, 11 B)", "│   │   └── utils.go (11 B)", "└── README.md (6 B)"} {
+		if !strings.Contains(ascii, want) {
+			t.Errorf("ascii tree missing %q:\n%s", want, ascii)
+		}
+	}
+}
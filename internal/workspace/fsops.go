@@ -0,0 +1,362 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 本文件实现文件管理工具（make_dir / move / copy / delete）：
+//  1. 所有路径都经过 sanitizePath 与 BlockedExtensions 校验；目录操作逐个检查其中的文件，不支持符号链接等特殊文件。
+//  2. 操作先规划为待创建/删除的目录与待写入/删除的文件，再经 commitTreeEdit 一并提交并记入编辑日志：
+//     move / copy 按内容进行（而不是 rename），因此与其他写入工具一样可以 undo / redo。
+//  3. move / copy 自动创建目标的父目录；目标文件已存在时需要 overwrite，目录不会合并。
+//  4. delete 删除非空目录需要 recursive；trash 为 true 时先把内容复制到工作区外的回收站（files.trash_dir）。
+//     files.disable_delete 禁止 delete、move（会删除源文件）以及 copy 覆盖已有文件。
+//  5. 单次操作的文件与目录数、总字节数有上限，避免在低内存设备上一次读入过多内容。
+
+// 单次 move / copy / delete 的规模上限
+const (
+	maxTreeEntries          = 1000
+	maxTreeBytes            = 64 << 20
+	lowResourceMaxTreeBytes = 16 << 20
+)
+
+// ErrDeleteDisabled files.disable_delete 开启时 delete、move 与覆盖已有文件返回的错误
+var ErrDeleteDisabled = errors.New("deletes are disabled by configuration (files.disable_delete)")
+
+// DeleteOptions Delete 的参数
+type DeleteOptions struct {
+	Recursive bool // 删除非空目录及其全部内容
+	Trash     bool // 删除前把内容复制到回收站
+}
+
+// FSResult make_dir / move / copy / delete 的结果
+type FSResult struct {
+	Path        string   `json:"path"`
+	Destination string   `json:"destination,omitempty"` // 仅 move / copy
+	Files       int      `json:"files"`                 // 写入（move / copy）或删除的文件数
+	CreatedDirs []string `json:"created_dirs,omitempty"`
+	RemovedDirs []string `json:"removed_dirs,omitempty"`
+	Trash       string   `json:"trash,omitempty"` // 删除的内容在回收站中的位置
+	EditID      int64    `json:"edit_id,omitempty"`
+}
+
+// treeEntry 目录树中的一个文件或目录
+type treeEntry struct {
+	absPath string
+	rel     string      // 相对于树根的路径，树根为 "."
+	file    *fileChange // 文件读取时的状态，目录为 nil
+}
+
+// MakeDir 创建目录及缺失的父目录；目录已存在时不做任何修改
+func (w *OSWorkspace) MakeDir(ctx context.Context, path string) (*FSResult, error) {
+	absPath, err := w.fsPath(path)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := w.missingDirs(absPath)
+	if err != nil {
+		return nil, err
+	}
+	result := &FSResult{Path: w.relSlash(absPath)}
+	for _, d := range dirs {
+		result.CreatedDirs = append(result.CreatedDirs, d.path)
+	}
+	if result.EditID, err = w.commitTreeEdit(EditMakeDir, nil, nil, dirs); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return result, nil
+}
+
+// Move 移动文件或目录到 dst（完整的目标路径）
+func (w *OSWorkspace) Move(ctx context.Context, src, dst string, overwrite bool) (*FSResult, error) {
+	return w.transfer(ctx, EditMove, src, dst, overwrite)
+}
+
+// Copy 复制文件或目录到 dst（完整的目标路径）
+func (w *OSWorkspace) Copy(ctx context.Context, src, dst string, overwrite bool) (*FSResult, error) {
+	return w.transfer(ctx, EditCopy, src, dst, overwrite)
+}
+
+// transfer 实现 move 与 copy：在目标位置写入源的全部文件，move 时再删除源文件与目录
+func (w *OSWorkspace) transfer(ctx context.Context, tool, src, dst string, overwrite bool) (*FSResult, error) {
+	if tool == EditMove && w.cfg.Files.DisableDelete {
+		return nil, fmt.Errorf("cannot move %s (the source would be deleted): %w", src, ErrDeleteDisabled)
+	}
+	srcAbs, err := w.fsPath(src)
+	if err != nil {
+		return nil, err
+	}
+	dstAbs, err := w.fsPath(dst)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(srcAbs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	switch {
+	case srcAbs == dstAbs:
+		return nil, fmt.Errorf("source and destination are the same")
+	case info.IsDir() && strings.HasPrefix(dstAbs, srcAbs+string(filepath.Separator)):
+		return nil, fmt.Errorf("cannot %s directory %s into itself", tool, src)
+	}
+	if info.IsDir() {
+		if _, err := os.Lstat(dstAbs); err == nil {
+			return nil, fmt.Errorf("destination %s already exists (directories are not merged)", dst)
+		}
+	}
+
+	entries, err := w.readTree(ctx, srcAbs)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := w.missingDirs(filepath.Dir(dstAbs))
+	if err != nil {
+		return nil, err
+	}
+	var changes []*fileChange
+	var paths []string
+	result := &FSResult{Path: w.relSlash(srcAbs), Destination: w.relSlash(dstAbs)}
+	for _, e := range entries {
+		target := filepath.Join(dstAbs, e.rel)
+		if e.file == nil {
+			dirs = append(dirs, dirChange{absPath: target, path: w.relSlash(target), create: true})
+			continue
+		}
+		if w.isBlockedExtension(target) {
+			return nil, fmt.Errorf("extension blocked for file %q", w.relSlash(target))
+		}
+		c, err := newFileChange(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check destination: %w", err)
+		}
+		if c.exists {
+			if !overwrite {
+				return nil, fmt.Errorf("destination %s already exists (set overwrite to replace it)", w.relSlash(target))
+			}
+			if w.cfg.Files.DisableDelete {
+				return nil, fmt.Errorf("cannot overwrite %s: %w", w.relSlash(target), ErrDeleteDisabled)
+			}
+		}
+		c.data, c.mode = e.file.original, e.file.origMode
+		if c.data == nil {
+			c.data = []byte{}
+		}
+		changes = append(changes, c)
+		paths = append(paths, w.relSlash(target))
+		result.Files++
+	}
+	if tool == EditMove {
+		for _, e := range entries {
+			if e.file == nil {
+				dirs = append(dirs, dirChange{absPath: e.absPath, path: w.relSlash(e.absPath)})
+				continue
+			}
+			e.file.remove = true
+			changes = append(changes, e.file)
+			paths = append(paths, w.relSlash(e.absPath))
+		}
+	}
+	result.CreatedDirs, result.RemovedDirs = dirPaths(dirs)
+
+	if result.EditID, err = w.commitTreeEdit(tool, paths, changes, dirs); err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %w", tool, src, err)
+	}
+	for _, c := range changes {
+		if !c.remove {
+			w.rememberContent(c.data)
+		}
+	}
+	return result, nil
+}
+
+// Delete 删除文件或目录；非空目录需要 opts.Recursive
+func (w *OSWorkspace) Delete(ctx context.Context, path string, opts DeleteOptions) (*FSResult, error) {
+	if w.cfg.Files.DisableDelete {
+		return nil, ErrDeleteDisabled
+	}
+	absPath, err := w.fsPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	entries, err := w.readTree(ctx, absPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 1 && !opts.Recursive {
+		return nil, fmt.Errorf("%s is a directory that is not empty (set recursive to delete it and its contents)", path)
+	}
+
+	var changes []*fileChange
+	var paths []string
+	var dirs []dirChange
+	result := &FSResult{Path: w.relSlash(absPath)}
+	for _, e := range entries {
+		if e.file == nil {
+			dirs = append(dirs, dirChange{absPath: e.absPath, path: w.relSlash(e.absPath)})
+			continue
+		}
+		e.file.remove = true
+		changes = append(changes, e.file)
+		paths = append(paths, w.relSlash(e.absPath))
+		result.Files++
+	}
+	_, result.RemovedDirs = dirPaths(dirs)
+
+	if opts.Trash {
+		if result.Trash, err = w.copyToTrash(entries); err != nil {
+			return nil, fmt.Errorf("failed to move %s to trash (nothing was deleted): %w", path, err)
+		}
+	}
+	if result.EditID, err = w.commitTreeEdit(EditDelete, paths, changes, dirs); err != nil {
+		return nil, fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// fsPath 校验文件管理工具的路径：sanitizePath、扩展名黑名单，不能是工作区根目录或符号链接
+// （sanitizePath 会解析符号链接，对链接本身的 move / delete 会作用到链接指向的文件）
+func (w *OSWorkspace) fsPath(path string) (string, error) {
+	raw := filepath.Clean(strings.TrimSpace(path))
+	if !filepath.IsAbs(raw) {
+		raw = filepath.Join(w.root, raw)
+	}
+	if info, err := os.Lstat(raw); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("%s is a symlink (symlinks are not supported)", path)
+	}
+	absPath, err := w.sanitizePath(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	if absPath == w.root {
+		return "", fmt.Errorf("cannot operate on the workspace root")
+	}
+	if w.isBlockedExtension(absPath) {
+		return "", fmt.Errorf("extension blocked for file %q", path)
+	}
+	return absPath, nil
+}
+
+// missingDirs 返回 absDir 及其祖先中尚不存在的目录（由浅到深）
+func (w *OSWorkspace) missingDirs(absDir string) ([]dirChange, error) {
+	var dirs []dirChange
+	for dir := absDir; dir != w.root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return nil, fmt.Errorf("%s exists and is not a directory", w.relSlash(dir))
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		dirs = append([]dirChange{{absPath: dir, path: w.relSlash(dir), create: true}}, dirs...)
+	}
+	return dirs, nil
+}
+
+// readTree 读取 absPath（文件或目录）下的全部目录与文件，父目录先于其中的条目
+func (w *OSWorkspace) readTree(ctx context.Context, absPath string) ([]treeEntry, error) {
+	limit := int64(maxTreeBytes)
+	if w.cfg.LowResourceMode {
+		limit = lowResourceMaxTreeBytes
+	}
+	var entries []treeEntry
+	var total int64
+	err := filepath.WalkDir(absPath, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(entries) == maxTreeEntries {
+			return fmt.Errorf("%s has more than %d files and directories", w.relSlash(absPath), maxTreeEntries)
+		}
+		rel, err := filepath.Rel(absPath, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			entries = append(entries, treeEntry{absPath: path, rel: rel})
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s is not a regular file (symlinks and special files are not supported)", w.relSlash(path))
+		}
+		if w.isBlockedExtension(path) {
+			return fmt.Errorf("extension blocked for file %q", w.relSlash(path))
+		}
+		c, err := newFileChange(path)
+		if err != nil {
+			return err
+		}
+		if total += c.size; total > limit {
+			return fmt.Errorf("%s is larger than %d MB", w.relSlash(absPath), limit>>20)
+		}
+		entries = append(entries, treeEntry{absPath: path, rel: rel, file: c})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// copyToTrash 把待删除的内容按工作区相对路径复制到 <trash_dir>/<根目录哈希>/<时间>-<随机串>/，返回该目录
+func (w *OSWorkspace) copyToTrash(entries []treeEntry) (string, error) {
+	base := w.cfg.Files.TrashDir
+	if base == "" {
+		return "", fmt.Errorf("trash is not configured (files.trash_dir)")
+	}
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	if isWithin(w.root, base) {
+		return "", fmt.Errorf("trash dir %s must be outside the workspace root %s", base, w.root)
+	}
+	parent := filepath.Join(base, workspaceKey(w.root))
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	trash, err := os.MkdirTemp(parent, time.Now().Format("20060102-150405")+"-*")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		target := filepath.Join(trash, filepath.FromSlash(w.relSlash(e.absPath)))
+		if e.file == nil {
+			err = os.MkdirAll(target, 0755)
+		} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+			err = os.WriteFile(target, e.file.original, e.file.origMode)
+		}
+		if err != nil {
+			os.RemoveAll(trash)
+			return "", err
+		}
+	}
+	return trash, nil
+}
+
+// dirPaths 按创建与删除拆分目录的工作区相对路径
+func dirPaths(dirs []dirChange) (created, removed []string) {
+	for _, d := range dirs {
+		if d.create {
+			created = append(created, d.path)
+		} else {
+			removed = append(removed, d.path)
+		}
+	}
+	return created, removed
+}
@@ -0,0 +1,195 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_MakeDir(t *testing.T) {
+	ws, root := newJournalWorkspace(t, config.HistoryConfig{})
+	ctx := context.Background()
+
+	res, err := ws.MakeDir(ctx, "a/b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(res.CreatedDirs, ",") != "a,a/b,a/b/c" || res.EditID == 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	// 目录已存在时不做修改，也不记入编辑日志
+	if res, err := ws.MakeDir(ctx, "a/b"); err != nil || len(res.CreatedDirs) != 0 || res.EditID != 0 {
+		t.Errorf("existing dir: %+v, %v", res, err)
+	}
+	if err := ws.WriteFile(ctx, "a/b/c/x.go", []byte("package c\n"), true, ""); err != nil {
+		t.Fatalf("write into new dir: %v", err)
+	}
+	if _, err := ws.MakeDir(ctx, "a/b/c/x.go/d"); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("expected not a directory error, got %v", err)
+	}
+	if _, err := ws.MakeDir(ctx, "."); err == nil {
+		t.Error("expected error for workspace root")
+	}
+
+	// 目录中已有新文件时拒绝撤销 make_dir
+	if _, err := ws.Undo(ctx, 0, res.EditID); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("expected not empty error, got %v", err)
+	}
+	os.Remove(filepath.Join(root, "a/b/c/x.go"))
+	if _, err := ws.Undo(ctx, 0, res.EditID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a")); !os.IsNotExist(err) {
+		t.Errorf("a still exists after undo: %v", err)
+	}
+}
+
+func TestOSWorkspace_MoveCopy(t *testing.T) {
+	ws, root := newJournalWorkspace(t, config.HistoryConfig{})
+	ctx := context.Background()
+	os.MkdirAll(filepath.Join(root, "src/sub"), 0755)
+	os.MkdirAll(filepath.Join(root, "src/empty"), 0755)
+	os.WriteFile(filepath.Join(root, "src/a.go"), []byte("package src\n"), 0644)
+	os.WriteFile(filepath.Join(root, "src/sub/run.sh"), []byte("#!/bin/sh\n"), 0755)
+
+	res, err := ws.Copy(ctx, "src", "out/copy", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files != 2 || strings.Join(res.CreatedDirs, ",") != "out,out/copy,out/copy/empty,out/copy/sub" {
+		t.Errorf("unexpected copy result: %+v", res)
+	}
+	if got := readString(t, filepath.Join(root, "out/copy/a.go")); got != "package src\n" {
+		t.Errorf("copied content = %q", got)
+	}
+	if info, err := os.Stat(filepath.Join(root, "out/copy/sub/run.sh")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("copied mode: %v, %v", info, err)
+	}
+	if _, err := ws.Copy(ctx, "src", "out/copy", false); err == nil || !strings.Contains(err.Error(), "not merged") {
+		t.Errorf("expected existing dir error, got %v", err)
+	}
+	if _, err := ws.Copy(ctx, "src", "src/sub/inner", false); err == nil || !strings.Contains(err.Error(), "into itself") {
+		t.Errorf("expected into itself error, got %v", err)
+	}
+
+	// 覆盖已有文件需要 overwrite
+	if _, err := ws.Copy(ctx, "src/a.go", "out/copy/sub/run.sh", false); err == nil || !strings.Contains(err.Error(), "set overwrite") {
+		t.Errorf("expected overwrite error, got %v", err)
+	}
+	if _, err := ws.Copy(ctx, "src/a.go", "out/copy/sub/run.sh", true); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = ws.Move(ctx, "src", "moved", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files != 2 || strings.Join(res.RemovedDirs, ",") != "src,src/empty,src/sub" {
+		t.Errorf("unexpected move result: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src")); !os.IsNotExist(err) {
+		t.Errorf("src still exists after move: %v", err)
+	}
+	if got := readString(t, filepath.Join(root, "moved/sub/run.sh")); got != "#!/bin/sh\n" {
+		t.Errorf("moved content = %q", got)
+	}
+
+	// 撤销 move 恢复原目录树（包括空目录），重做后再次移动
+	if _, err := ws.Undo(ctx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "moved")); !os.IsNotExist(err) {
+		t.Errorf("moved still exists after undo: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "src/empty")); err != nil || !info.IsDir() {
+		t.Errorf("src/empty not restored: %v", err)
+	}
+	if _, err := ws.Redo(ctx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, filepath.Join(root, "moved/a.go")); got != "package src\n" {
+		t.Errorf("content after redo = %q", got)
+	}
+}
+
+func TestOSWorkspace_Delete(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{
+		RootDir:           root,
+		BlockedExtensions: []string{".key"},
+		History:           config.HistoryConfig{Dir: t.TempDir()},
+		Files:             config.FilesConfig{TrashDir: t.TempDir()},
+	}
+	w, err := NewOSWorkspace(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := w.(*OSWorkspace)
+	ctx := context.Background()
+	os.MkdirAll(filepath.Join(root, "build/obj"), 0755)
+	os.WriteFile(filepath.Join(root, "build/obj/a.o"), []byte("obj"), 0644)
+	os.WriteFile(filepath.Join(root, "build/log.txt"), []byte("log"), 0644)
+
+	if _, err := ws.Delete(ctx, "build", DeleteOptions{}); err == nil || !strings.Contains(err.Error(), "set recursive") {
+		t.Fatalf("expected recursive error, got %v", err)
+	}
+	res, err := ws.Delete(ctx, "build", DeleteOptions{Recursive: true, Trash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files != 2 || len(res.RemovedDirs) != 2 || res.EditID == 0 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(root, "build")); !os.IsNotExist(err) {
+		t.Errorf("build still exists: %v", err)
+	}
+	if !strings.HasPrefix(res.Trash, cfg.Files.TrashDir) {
+		t.Errorf("trash = %q, want under %s", res.Trash, cfg.Files.TrashDir)
+	}
+	if got := readString(t, filepath.Join(res.Trash, "build/obj/a.o")); got != "obj" {
+		t.Errorf("trash content = %q", got)
+	}
+
+	if _, err := ws.Undo(ctx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, filepath.Join(root, "build/log.txt")); got != "log" {
+		t.Errorf("content after undo = %q", got)
+	}
+
+	// 目录中含有黑名单扩展名的文件时整个操作被拒绝
+	os.WriteFile(filepath.Join(root, "build/id.key"), []byte("secret"), 0600)
+	if _, err := ws.Delete(ctx, "build", DeleteOptions{Recursive: true}); err == nil || !strings.Contains(err.Error(), "extension blocked") {
+		t.Errorf("expected blocked extension error, got %v", err)
+	}
+	if _, err := ws.Move(ctx, "build/log.txt", "build/log.key", false); err == nil || !strings.Contains(err.Error(), "extension blocked") {
+		t.Errorf("expected blocked extension error for destination, got %v", err)
+	}
+
+	// files.disable_delete 禁止删除与覆盖
+	cfg.Files.DisableDelete = true
+	if _, err := ws.Delete(ctx, "build/log.txt", DeleteOptions{}); !errors.Is(err, ErrDeleteDisabled) {
+		t.Errorf("expected ErrDeleteDisabled, got %v", err)
+	}
+	if _, err := ws.Copy(ctx, "build/log.txt", "build/obj/a.o", true); !errors.Is(err, ErrDeleteDisabled) {
+		t.Errorf("expected ErrDeleteDisabled for overwrite, got %v", err)
+	}
+	// move 会删除源文件，同样被拒绝且不做任何修改
+	if _, err := ws.Move(ctx, "build/log.txt", "logs/log.txt", false); !errors.Is(err, ErrDeleteDisabled) {
+		t.Errorf("expected ErrDeleteDisabled for move, got %v", err)
+	}
+	if got := readString(t, filepath.Join(root, "build/log.txt")); got != "log" {
+		t.Errorf("source changed by refused move: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "logs")); !os.IsNotExist(err) {
+		t.Errorf("refused move created the destination: %v", err)
+	}
+	if _, err := ws.Copy(ctx, "build/log.txt", "logs/log.txt", false); err != nil {
+		t.Errorf("copy without overwrite should still work: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package workspace
+
+import (
+	"path"
+	"strings"
+)
+
+// 本文件实现 doublestar 风格的 glob 匹配（不引入第三方依赖，保持二进制小巧）：
+//  1. 支持 path.Match 的全部语法（* ? [...]），其中 * 不跨越目录分隔符。
+//  2. 独立的 ** 段匹配零个或多个目录层级，例如 internal/**/*_test.go。
+//  3. 支持 {a,b} 花括号展开，例如 *.{go,md}。
+//  4. 不含 / 的模式按文件名匹配任意层级（等价于 **/pattern），与 .gitignore 的习惯一致。
+//  所有路径均使用 / 分隔（调用方负责 filepath.ToSlash）。
+
+// matchGlob 判断相对路径 rel 是否匹配 doublestar 模式 pattern
+func matchGlob(pattern, rel string) bool {
+	for _, p := range expandBraces(pattern) {
+		p = strings.TrimPrefix(p, "./")
+		if !strings.Contains(p, "/") {
+			p = "**/" + p
+		}
+		if matchSegments(strings.Split(p, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGlob 检查模式语法是否合法（path.Match 对每个段返回 ErrBadPattern）
+func validateGlob(pattern string) error {
+	for _, p := range expandBraces(pattern) {
+		for _, seg := range strings.Split(p, "/") {
+			if seg == "**" {
+				continue
+			}
+			if _, err := path.Match(seg, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchSegments 逐段匹配，** 可吞掉任意数量的路径段
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		seg := pattern[0]
+		if seg == "**" {
+			// 合并连续的 **
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(seg, parts[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		parts = parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// expandBraces 展开 {a,b} 形式的花括号（支持嵌套），不含花括号时原样返回
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		return []string{pattern}
+	}
+
+	// 找到匹配的右括号，并在顶层按逗号切分
+	depth := 0
+	var options []string
+	start := open + 1
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				options = append(options, pattern[start:i])
+				prefix, suffix := pattern[:open], pattern[i+1:]
+				var result []string
+				for _, opt := range options {
+					result = append(result, expandBraces(prefix+opt+suffix)...)
+				}
+				return result
+			}
+		case ',':
+			if depth == 1 {
+				options = append(options, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	// 括号不闭合：按字面量处理
+	return []string{pattern}
+}
+
+// hasGlobMeta 判断模式是否包含通配符
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[{")
+}
@@ -0,0 +1,34 @@
+package workspace
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "internal/config/config.go", true},
+		{"reloader.go", "internal/config/reloader.go", true},
+		{"**/*_test.go", "internal/workspace/eyes_test.go", true},
+		{"**/*_test.go", "eyes_test.go", true},
+		{"internal/**/*.go", "internal/workspace/eyes.go", true},
+		{"internal/**/*.go", "cmd/opencode-mcp/main.go", false},
+		{"internal/*.go", "internal/workspace/eyes.go", false},
+		{"cmd/**", "cmd/opencode-mcp/main.go", true},
+		{"*.{go,md}", "docs/README.md", true},
+		{"*.{go,md}", "config.json", false},
+		{"src/{a,b/c}/*.go", "src/b/c/x.go", true},
+		{"./README.md", "README.md", true},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.pattern, c.rel); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", c.pattern, c.rel, got, c.want)
+		}
+	}
+
+	if err := validateGlob("[a-"); err == nil {
+		t.Error("expected bad pattern error")
+	}
+}
@@ -0,0 +1,381 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 本文件实现 go test 的结构化结果（workspace.run_tests 使用）：
+//  1. RunTests 通过 ExecuteStream 执行 go test -json，命令策略、沙箱、环境变量策略与 secure_exec 相同。
+//  2. 事件在输出到达时逐行解析：通过/跳过的测试不保留输出，失败测试只保留最后 maxTestOutputBytes 字节，
+//     内存占用与测试数量和输出总量无关。
+//  3. 失败输出中的 file.go:line 映射为工作区相对路径（按模块路径推算包目录），工作区外的位置（如标准库栈帧）忽略。
+//  4. 编译失败等包级错误记录在 PackageTestResult.Output 中。
+//  5. 包模式不能以 - 开头，否则 -exec=sh、-toolexec=x 之类的 flag 会借包参数绕过 deny_args
+//     （旧配置中的 "go test" 白名单条目不检查参数，不含路径分隔符的值也不会被 path_args 拦截）。
+
+const (
+	maxTestOutputBytes = 8 << 10
+	maxTestLocations   = 5
+)
+
+// 测试状态
+const (
+	TestPass = "pass"
+	TestFail = "fail"
+	TestSkip = "skip"
+)
+
+// TestOptions RunTests 的参数
+type TestOptions struct {
+	Packages       []string   // 包模式，默认 ./...；不能以 - 开头
+	Run            string     // -run 正则
+	Skip           string     // -skip 正则
+	TimeoutSeconds int64      // <= 0 时使用配置中的 BuildTimeout
+	Cwd            string     // 工作目录（嵌套模块），相对工作区根目录
+	OnOutput       OutputFunc // 原始输出回调（progress 通知），可为 nil
+}
+
+// TestCaseResult 单个测试（含子测试）的结果
+type TestCaseResult struct {
+	Package   string   `json:"package"`
+	Test      string   `json:"test"`
+	Status    string   `json:"status"`
+	Elapsed   float64  `json:"elapsed"`             // 秒
+	Output    string   `json:"output,omitempty"`    // 仅失败测试
+	Locations []string `json:"locations,omitempty"` // 失败输出中的 file:line（工作区相对路径）
+}
+
+// PackageTestResult 包级结果
+type PackageTestResult struct {
+	Package string  `json:"package"`
+	Status  string  `json:"status"` // 无测试文件时为 skip
+	Elapsed float64 `json:"elapsed"`
+	Output  string  `json:"output,omitempty"` // 仅失败包：编译错误或测试之外的输出
+}
+
+// TestSummary 汇总
+type TestSummary struct {
+	Passed         int     `json:"passed"`
+	Failed         int     `json:"failed"`
+	Skipped        int     `json:"skipped"`
+	Packages       int     `json:"packages"`
+	FailedPackages int     `json:"failed_packages"`
+	Elapsed        float64 `json:"elapsed"` // 整次运行的耗时（秒）
+}
+
+// TestReport RunTests 的结果；失败的测试排在前面
+type TestReport struct {
+	Summary  TestSummary         `json:"summary"`
+	Packages []PackageTestResult `json:"packages"`
+	Tests    []TestCaseResult    `json:"tests"`
+	ExitCode int                 `json:"exit_code"`
+	Error    string              `json:"error,omitempty"`  // 超时等非测试失败导致的错误
+	Stderr   string              `json:"stderr,omitempty"` // 未以 JSON 输出的错误（如旧版本 go 的编译错误）
+	LogID    string              `json:"log_id,omitempty"` // 完整原始输出，见 read_exec_log
+}
+
+// testEvent go test -json（test2json）输出的事件
+type testEvent struct {
+	Action      string  `json:"Action"`
+	Package     string  `json:"Package"`
+	Test        string  `json:"Test"`
+	Elapsed     float64 `json:"Elapsed"`
+	Output      string  `json:"Output"`
+	ImportPath  string  `json:"ImportPath"`  // build-output / build-fail 事件
+	FailedBuild string  `json:"FailedBuild"` // 因编译失败而失败的包
+}
+
+// RunTests 执行 go test -json 并返回结构化结果
+// 包模式无效、cwd 无效或命令被策略拒绝时返回 error（命令未执行）；
+// 命令未能启动（如沙箱不可用）、超时等执行期错误记录在 report.Error 中，返回的 error 为 nil
+func (w *OSWorkspace) RunTests(ctx context.Context, opts TestOptions) (*TestReport, error) {
+	if err := checkPackagePatterns(opts.Packages); err != nil {
+		return nil, err
+	}
+	args := []string{"test", "-json"}
+	if opts.Run != "" {
+		args = append(args, "-run="+opts.Run)
+	}
+	if opts.Skip != "" {
+		args = append(args, "-skip="+opts.Skip)
+	}
+	if len(opts.Packages) == 0 {
+		args = append(args, "./...")
+	} else {
+		args = append(args, opts.Packages...)
+	}
+
+	// 策略拒绝、cwd 无效时直接返回错误（命令未执行）
+	dir, err := w.execDir(opts.Cwd)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.checkCommand(dir, "go", args); err != nil {
+		return nil, err
+	}
+	p := newTestParser(w.root, dir)
+	started := time.Now()
+	res, err := w.ExecuteStream(ctx, "go", args, ExecOptions{
+		TimeoutSeconds: opts.TimeoutSeconds,
+		Cwd:            opts.Cwd,
+		OnOutput: func(stream string, chunk []byte) {
+			if stream == "stdout" {
+				p.Write(chunk)
+			}
+			if opts.OnOutput != nil {
+				opts.OnOutput(stream, chunk)
+			}
+		},
+	})
+
+	report := p.report()
+	report.Summary.Elapsed = time.Since(started).Seconds()
+	report.ExitCode = res.ExitCode
+	report.LogID = res.LogID
+	report.Stderr = TruncateOutputString(res.Stderr, 2000)
+	if err != nil && res.ExitCode <= 0 {
+		// 非零退出码已由测试结果体现，只记录超时等其他错误
+		report.Error = err.Error()
+	}
+	return report, nil
+}
+
+// checkPackagePatterns 拒绝以 - 开头的包模式（会被 go 命令当作 flag）
+func checkPackagePatterns(pkgs []string) error {
+	for _, pkg := range pkgs {
+		if strings.HasPrefix(pkg, "-") {
+			return fmt.Errorf("invalid package pattern %q: must not start with '-'", pkg)
+		}
+	}
+	return nil
+}
+
+// testParser 逐行解析 test2json 事件，并发安全
+type testParser struct {
+	mu       sync.Mutex
+	root     string
+	modPath  string // go test 工作目录所在模块的模块路径
+	modDir   string // 模块根目录
+	partial  []byte
+	running  map[string]*testState // 包名 + "\x00" + 测试名（包级输出的测试名为空）
+	builds   map[string]*ringBuffer
+	tests    []TestCaseResult
+	packages []PackageTestResult
+}
+
+type testState struct {
+	pkg, test string
+	output    *ringBuffer
+}
+
+func newTestParser(root, dir string) *testParser {
+	p := &testParser{
+		root:    root,
+		running: make(map[string]*testState),
+		builds:  make(map[string]*ringBuffer),
+	}
+	p.modPath, p.modDir = findModule(root, dir)
+	return p
+}
+
+// Write 接收 stdout 数据，按行解析事件
+func (p *testParser) Write(chunk []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partial = append(p.partial, chunk...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		p.handleLine(p.partial[:i])
+		p.partial = p.partial[i+1:]
+	}
+	// 异常长的行（非 JSON 输出）不再继续累积
+	if len(p.partial) > maxTestOutputBytes {
+		p.partial = p.partial[:0]
+	}
+}
+
+func (p *testParser) handleLine(line []byte) {
+	var ev testEvent
+	if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &ev) != nil {
+		return
+	}
+	switch ev.Action {
+	case "build-output":
+		buf, ok := p.builds[ev.ImportPath]
+		if !ok {
+			buf = newRingBuffer(maxTestOutputBytes)
+			p.builds[ev.ImportPath] = buf
+		}
+		buf.Write([]byte(ev.Output))
+	case "output":
+		p.state(ev.Package, ev.Test).output.Write([]byte(ev.Output))
+	case "run":
+		p.state(ev.Package, ev.Test)
+	case TestPass, TestFail, TestSkip:
+		key := ev.Package + "\x00" + ev.Test
+		st := p.running[key]
+		delete(p.running, key)
+		output := ""
+		if ev.Action == TestFail && st != nil {
+			output = st.output.String()
+		}
+		if ev.Test == "" {
+			if ev.FailedBuild != "" {
+				if buf, ok := p.builds[ev.FailedBuild]; ok {
+					output = buf.String() + output
+				}
+			}
+			p.packages = append(p.packages, PackageTestResult{Package: ev.Package, Status: ev.Action, Elapsed: ev.Elapsed, Output: output})
+			return
+		}
+		p.tests = append(p.tests, p.testResult(ev.Package, ev.Test, ev.Action, ev.Elapsed, output))
+	}
+}
+
+// state 返回运行中的测试状态，不存在时创建
+func (p *testParser) state(pkg, test string) *testState {
+	key := pkg + "\x00" + test
+	st, ok := p.running[key]
+	if !ok {
+		st = &testState{pkg: pkg, test: test, output: newRingBuffer(maxTestOutputBytes)}
+		p.running[key] = st
+	}
+	return st
+}
+
+func (p *testParser) testResult(pkg, test, status string, elapsed float64, output string) TestCaseResult {
+	r := TestCaseResult{Package: pkg, Test: test, Status: status, Elapsed: elapsed}
+	if status == TestFail {
+		r.Output = output
+		r.Locations = p.locations(pkg, output)
+	}
+	return r
+}
+
+// report 汇总结果；仍在运行（进程被终止或 panic）的测试记为失败
+func (p *testParser) report() *TestReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, st := range p.running {
+		if st.test != "" {
+			p.tests = append(p.tests, p.testResult(st.pkg, st.test, TestFail, 0, st.output.String()))
+		}
+	}
+	p.running = make(map[string]*testState)
+
+	r := &TestReport{Packages: p.packages, Tests: p.tests}
+	if r.Packages == nil {
+		r.Packages = []PackageTestResult{}
+	}
+	if r.Tests == nil {
+		r.Tests = []TestCaseResult{}
+	}
+	sort.SliceStable(r.Tests, func(i, j int) bool {
+		fi, fj := r.Tests[i].Status == TestFail, r.Tests[j].Status == TestFail
+		if fi != fj {
+			return fi
+		}
+		if r.Tests[i].Package != r.Tests[j].Package {
+			return r.Tests[i].Package < r.Tests[j].Package
+		}
+		return r.Tests[i].Test < r.Tests[j].Test
+	})
+	for _, t := range r.Tests {
+		switch t.Status {
+		case TestPass:
+			r.Summary.Passed++
+		case TestFail:
+			r.Summary.Failed++
+		case TestSkip:
+			r.Summary.Skipped++
+		}
+	}
+	r.Summary.Packages = len(r.Packages)
+	for _, pkg := range r.Packages {
+		if pkg.Status == TestFail {
+			r.Summary.FailedPackages++
+		}
+	}
+	return r
+}
+
+var testLocationPattern = regexp.MustCompile(`(?m)^\s*([^\s:]+\.go):(\d+)`)
+
+// locations 提取输出中的 file:line，转换为工作区相对路径，去重后最多 maxTestLocations 个
+func (p *testParser) locations(pkg, output string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, m := range testLocationPattern.FindAllStringSubmatch(output, -1) {
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			pkgDir, ok := p.packageDir(pkg)
+			if !ok {
+				continue
+			}
+			file = filepath.Join(pkgDir, file)
+		}
+		rel, err := filepath.Rel(p.root, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		loc := filepath.ToSlash(rel) + ":" + m[2]
+		if !seen[loc] {
+			seen[loc] = true
+			out = append(out, loc)
+		}
+		if len(out) >= maxTestLocations {
+			break
+		}
+	}
+	return out
+}
+
+// packageDir 按模块路径推算包目录
+func (p *testParser) packageDir(pkg string) (string, bool) {
+	if p.modPath == "" {
+		return "", false
+	}
+	if pkg == p.modPath {
+		return p.modDir, true
+	}
+	if rest, ok := strings.CutPrefix(pkg, p.modPath+"/"); ok {
+		return filepath.Join(p.modDir, filepath.FromSlash(rest)), true
+	}
+	return "", false
+}
+
+// findModule 从 dir 向上（不超出 root）查找 go.mod，返回模块路径与模块根目录
+func findModule(root, dir string) (modPath, modDir string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if f, err := os.Open(filepath.Join(d, "go.mod")); err == nil {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) >= 2 && fields[0] == "module" {
+					f.Close()
+					return strings.Trim(fields[1], `"`), d
+				}
+			}
+			f.Close()
+			return "", ""
+		}
+		if d == root || filepath.Dir(d) == d {
+			return "", ""
+		}
+	}
+}
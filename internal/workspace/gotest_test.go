@@ -0,0 +1,107 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_RunTestsRejectsFlagPackages(t *testing.T) {
+	// 旧配置的 "go test" 条目不检查参数；以 - 开头的包模式在执行前被拒绝
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: t.TempDir(), AllowedBuildCommands: []string{"go test", "go build", "go vet"}, BuildTimeout: 5})
+	for _, pkg := range []string{"-exec=sh", "-toolexec=x", "--exec=sh"} {
+		if _, err := ws.RunTests(context.Background(), TestOptions{Packages: []string{"./...", pkg}}); err == nil || !strings.Contains(err.Error(), "invalid package pattern") {
+			t.Errorf("RunTests with package %q: err = %v", pkg, err)
+		}
+		if _, err := ws.Diagnostics(context.Background(), DiagnosticsOptions{Packages: []string{pkg}}); err == nil || !strings.Contains(err.Error(), "invalid package pattern") {
+			t.Errorf("Diagnostics with package %q: err = %v", pkg, err)
+		}
+	}
+}
+
+func TestOSWorkspace_RunTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"mod/go.mod": "module example.com/m\n\ngo 1.21\n",
+		"mod/calc/calc_test.go": `package calc
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestFail(t *testing.T) {
+	t.Log("some detail")
+	t.Errorf("want 2, got 3")
+}
+
+func TestSkip(t *testing.T) { t.Skip("not today") }
+`,
+		"mod/broken/broken.go": "package broken\n\nfunc F() int { return \"x\" }\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		AllowedBuildCommands: []string{"go test"},
+		BuildTimeout:         120,
+		Env:                  config.EnvPolicy{Set: map[string]string{"GOFLAGS": "-mod=mod", "GOTOOLCHAIN": "local"}},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	report, err := ws.RunTests(context.Background(), TestOptions{Cwd: "mod"})
+	if err != nil {
+		t.Fatalf("RunTests failed: %v", err)
+	}
+	s := report.Summary
+	if s.Passed != 1 || s.Failed != 1 || s.Skipped != 1 || s.FailedPackages != 2 {
+		t.Fatalf("unexpected summary %+v (tests %+v)", s, report.Tests)
+	}
+	first := report.Tests[0]
+	if first.Test != "TestFail" || first.Package != "example.com/m/calc" {
+		t.Fatalf("failures should come first, got %+v", first)
+	}
+	if !strings.Contains(first.Output, "want 2, got 3") {
+		t.Errorf("failure output missing message: %q", first.Output)
+	}
+	if len(first.Locations) == 0 || first.Locations[0] != "mod/calc/calc_test.go:8" {
+		t.Errorf("locations = %v, want [mod/calc/calc_test.go:8 ...]", first.Locations)
+	}
+	for _, tc := range report.Tests[1:] {
+		if tc.Output != "" {
+			t.Errorf("passing/skipped test %s should not carry output", tc.Test)
+		}
+	}
+	var broken *PackageTestResult
+	for i := range report.Packages {
+		if report.Packages[i].Package == "example.com/m/broken" {
+			broken = &report.Packages[i]
+		}
+	}
+	if broken == nil || broken.Status != TestFail {
+		t.Errorf("expected failed broken package, got %+v", report.Packages)
+	} else if !strings.Contains(broken.Output+report.Stderr, "broken.go:3") {
+		t.Errorf("build error missing: %q / %q", broken.Output, report.Stderr)
+	}
+
+	// -run 过滤与策略拒绝
+	report, err = ws.RunTests(context.Background(), TestOptions{Cwd: "mod", Packages: []string{"./calc"}, Run: "TestPass"})
+	if err != nil || report.Summary.Passed != 1 || report.Summary.Failed != 0 {
+		t.Errorf("run filter: %+v, %v", report, err)
+	}
+	cfg.AllowedBuildCommands = []string{"go build"}
+	ws, _ = NewOSWorkspace(cfg)
+	if _, err := ws.RunTests(context.Background(), TestOptions{Cwd: "mod"}); err == nil {
+		t.Error("expected policy error")
+	}
+}
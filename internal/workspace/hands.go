@@ -1,202 +1,327 @@
 package workspace
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 // TODO(hands_file_overview):
 //  本文件实现“精准修改模块（The Hands）”：
-//  1. ApplyUnifiedDiff：接收 unified diff 文本，解析为 DiffPatch 结构，对每个文件执行安全校验 + 原子写入。
-//  2. SearchAndReplace：对单个文件执行精确字符串替换，支持 expectedOccurrences 一致性校验和 dry-run。
-//  3. parseUnifiedDiff / parseHunkHeader / applyPatchToContent 等辅助方法用于解析和应用补丁。
+//  1. ApplyUnifiedDiff / ApplyPatch：接收 unified diff 文本，解析为 DiffPatch 结构，逐 hunk 校验上下文（见 patch.go），
+//     全部文件校验通过后再原子写入。
+//  2. SearchAndReplace：字面量或正则替换，支持多组替换、第 N 处匹配与按 glob 批量替换（见 replace.go）。
+//  3. parseUnifiedDiff / parseHunkHeader 等辅助方法用于解析补丁，applyPatchToContent 见 patch.go。
 //  参考实现已经提供，但仍需按各 TODO 检查逻辑正确性、错误信息和性能是否满足当前设计。
 
-// ApplyUnifiedDiff 应用 unified diff 补丁
-// diffText 是标准 unified diff 格式的文本，dryRun 仅验证不写盘
-// TODO(hands_apply_unified_diff_impl):
-//  1. 先调用 parseUnifiedDiff(diffText)，将 diff 文本解析为 []DiffPatch。
-//  2. 对于每个 patch：
-//     - 使用 w.sanitizePath(patch.FilePath) 校验路径安全；
-//     - 使用 w.isBlockedExtension 拦截敏感扩展名；
-//     - 根据 patch.IsNewFile 判断是否允许新建文件。
-//  3. 读取原始文件内容（若存在），调用 applyPatchToContent(original, patch) 得到 patchedContent。
-//  4. 若 dryRun = true，仅将 patch.FilePath 追加到 appliedFiles，不写盘。
-//  5. 若 dryRun = false：
-//     - 在写入前，考虑调用 BackupAndRollback 提供备份能力；
-//     - 使用临时文件 + 原子替换的方式写入 patchedContent；
-//     - 任何一步出错都应恢复备份并返回详细错误。
-//  6. 全程检查 ctx.Done()，支持取消。
+// ApplyUnifiedDiff 应用 unified diff 补丁（默认 fuzz、不限偏移，任一 hunk 失败时不写盘），返回修改的文件
 func (w *OSWorkspace) ApplyUnifiedDiff(ctx context.Context, diffText string, dryRun bool) (appliedFiles []string, err error) {
-	// 解析 diff
+	result, err := w.ApplyPatch(ctx, diffText, PatchOptions{DryRun: dryRun, Fuzz: DefaultPatchFuzz, MaxOffset: -1})
+	if err != nil {
+		return nil, err
+	}
+	return result.Changed, nil
+}
+
+// PatchOptions ApplyPatch 的参数
+type PatchOptions struct {
+	DryRun       bool              // 只校验不写盘
+	Fuzz         int               // 最多忽略的首尾上下文行数，0 表示上下文必须完全一致
+	MaxOffset    int               // hunk 相对 @@ 行号最多移动的行数，< 0 不限制
+	WriteRejects bool              // 失败的 hunk 写入 <file>.rej，其余 hunk 照常应用
+	IfMatch      map[string]string // 文件路径 -> 读取时的 sha256，内容不一致时返回 *ConflictError 且不修改任何文件
+}
+
+// FilePatchResult 单个文件的补丁结果
+type FilePatchResult struct {
+	Path       string       `json:"path"`
+	OldPath    string       `json:"old_path,omitempty"` // 重命名/复制的源文件
+	Operation  string       `json:"operation"`          // modify / create / delete / rename / copy
+	Mode       string       `json:"mode,omitempty"`     // git 文件模式变更（如 100755）
+	Status     string       `json:"status"`             // applied / partial（部分 hunk 写入 .rej）/ failed
+	Hunks      []HunkResult `json:"hunks"`
+	RejectFile string       `json:"reject_file,omitempty"` // 工作区相对路径
+	Hash       string       `json:"hash,omitempty"`        // 应用后内容的 sha256（文件被删除时为空），可作为下一次写入的 ifMatch
+}
+
+// PatchResult ApplyPatch 的结果
+type PatchResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Changed []string          `json:"changed"` // 实际修改（dry-run 时为将会修改）的文件，含新建、删除的文件与 .rej 文件
+	Files   []FilePatchResult `json:"files"`
+	EditID  int64             `json:"edit_id,omitempty"` // 编辑日志中的条目 ID（可用于 workspace.undo）
+}
+
+// ApplyPatch 校验并应用 unified diff，多文件之间全有或全无：
+//  1. 先在内存中对所有文件应用补丁（路径安全、扩展名、新建/修改校验同 WriteFile），同一文件出现多次时依次叠加；
+//     新建、删除、重命名、复制与模式变更同样先在内存中完成，二进制补丁、符号链接与子模块直接拒绝。
+//  2. 有 hunk 失败时返回 error（含第一处期望行与实际行），未开启 WriteRejects 时所有文件都不修改；
+//     开启时修改类补丁中成功的 hunk 照常写入，失败的 hunk 写入 <file>.rej（其他操作要求全部 hunk 成功）。
+//  3. 没有变化的文件不写入；其余文件经 commitFiles 一并提交，任一文件失败时全部回滚（见 txn.go）。
+func (w *OSWorkspace) ApplyPatch(ctx context.Context, diffText string, opts PatchOptions) (*PatchResult, error) {
 	patches, err := parseUnifiedDiff(diffText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse diff: %w", err)
 	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("failed to parse diff: no file headers (--- / +++ or diff --git) found")
+	}
 
-	appliedFiles = make([]string, 0, len(patches))
+	result := &PatchResult{DryRun: opts.DryRun, Changed: []string{}, Files: make([]FilePatchResult, 0, len(patches))}
+	t := &patchTxn{byPath: make(map[string]*fileChange)}
+	rejects := make(map[*fileChange]bool)
+	var failures []string
+	failedHunks, totalHunks := 0, 0
 
-	// 逐个文件处理
+	// 0. 校验 ifMatch（按路径排序，结果稳定）
+	ifMatchPaths := make([]string, 0, len(opts.IfMatch))
+	for path := range opts.IfMatch {
+		ifMatchPaths = append(ifMatchPaths, path)
+	}
+	sort.Strings(ifMatchPaths)
+	for _, path := range ifMatchPaths {
+		c, err := w.patchFile(t, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.checkIfMatch(w.relSlash(c.absPath), c, opts.IfMatch[path]); err != nil {
+			return nil, err
+		}
+	}
+	var targets []*fileChange // 与 result.Files 对应
+
+	// 1. 在内存中应用所有补丁
 	for _, patch := range patches {
 		select {
 		case <-ctx.Done():
-			return appliedFiles, ctx.Err()
+			return nil, ctx.Err()
 		default:
 		}
 
-		// 安全检查：目标文件必须在工作区内且不在黑名单
-		absPath, err := w.sanitizePath(patch.FilePath)
-		if err != nil {
-			return appliedFiles, fmt.Errorf("invalid file path %q in diff: %w", patch.FilePath, err)
+		op := patchOperation(patch)
+		if patch.IsBinary {
+			return nil, fmt.Errorf("binary patch for %s is not supported: regenerate the diff without binary changes or write the file with workspace.write_file", patch.FilePath)
 		}
-		if w.isBlockedExtension(absPath) {
-			return appliedFiles, fmt.Errorf("blocked extension for file %s", absPath)
+		if _, err := applyGitMode(0, patch.OriginalMode); err != nil {
+			return nil, fmt.Errorf("%s: %w", patch.FilePath, err)
 		}
-
-		// 检查文件是否存在（除非是新增文件）
-		_, statErr := os.Stat(absPath)
-		if patch.IsNewFile {
-			// 新增文件：允许目标文件不存在；其他 stat 错误需要上抛
-			if statErr != nil && !os.IsNotExist(statErr) {
-				return appliedFiles, fmt.Errorf("failed to stat target file %s: %w", absPath, statErr)
-			}
-		} else {
-			// 修改已有文件：目标文件必须存在
-			if statErr != nil {
-				if os.IsNotExist(statErr) {
-					return appliedFiles, fmt.Errorf("file %s does not exist (but diff indicates modification)", absPath)
-				}
-				return appliedFiles, fmt.Errorf("failed to stat target file %s: %w", absPath, statErr)
-			}
+		if _, err := applyGitMode(0, patch.NewMode); err != nil {
+			return nil, fmt.Errorf("%s: %w", patch.FilePath, err)
 		}
 
-		// 读取原始内容
-		var original []byte
-		if statErr == nil {
-			original, err = os.ReadFile(absPath)
-			if err != nil {
-				return appliedFiles, fmt.Errorf("failed to read original file %s: %w", absPath, err)
-			}
+		// 补丁作用于 src 的内容，结果写入 dst（重命名/复制时两者不同）
+		srcPath := patch.FilePath
+		if op == "rename" || op == "copy" {
+			srcPath = patch.OldPath
 		}
-
-		// 应用补丁（简化实现：基于行号定位和替换）
-		patchedContent, err := applyPatchToContent(original, patch)
+		src, err := w.patchFile(t, srcPath)
 		if err != nil {
-			return appliedFiles, fmt.Errorf("failed to apply patch to %s: %w", absPath, err)
+			return nil, err
 		}
-
-		// dryRun 模式下只做验证不写盘
-		if dryRun {
-			appliedFiles = append(appliedFiles, patch.FilePath)
-			continue
+		dst := src
+		if srcPath != patch.FilePath {
+			if dst, err = w.patchFile(t, patch.FilePath); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case op == "create" && dst.present():
+			return nil, fmt.Errorf("file %s already exists (but diff creates it)", patch.FilePath)
+		case op != "create" && !src.present():
+			return nil, fmt.Errorf("file %s does not exist (but diff indicates modification)", srcPath)
+		case src != dst && dst.present():
+			return nil, fmt.Errorf("%s target %s already exists", op, patch.FilePath)
 		}
 
-		// 原子写入：复制到临时文件后 rename（备份原文件）
-		backupPath := absPath + ".bak"
-		if statErr == nil {
-			if err := os.Rename(absPath, backupPath); err != nil {
-				return appliedFiles, fmt.Errorf("failed to backup original file: %w", err)
+		patched, hunks := applyPatchToContent(src.data, patch, opts.Fuzz, opts.MaxOffset)
+		fr := FilePatchResult{Path: patch.FilePath, Operation: op, Mode: patch.NewMode, Status: "applied", Hunks: hunks}
+		if src != dst {
+			fr.OldPath = srcPath
+		}
+		failed := 0
+		for _, h := range hunks {
+			if h.Status == HunkFailed {
+				if failed == 0 {
+					failures = append(failures, patch.FilePath+": "+hunkFailure(h))
+				}
+				failed++
 			}
-			defer func() {
+		}
+		totalHunks += len(hunks)
+		failedHunks += failed
+		apply := failed == 0 || (op == "modify" && failed < len(hunks))
+		if failed > 0 {
+			fr.Status = "failed"
+			if opts.WriteRejects {
+				rej, err := w.patchFile(t, patch.FilePath+".rej")
 				if err != nil {
-					// 失败时恢复备份
-					os.Rename(backupPath, absPath)
+					return nil, err
 				}
-			}()
+				if !rejects[rej] {
+					// 覆盖上一次遗留的 .rej（回滚时恢复）
+					rejects[rej] = true
+					rej.data, rej.remove = nil, false
+				}
+				rej.data = append(rej.data, formatRejects(patch.FilePath, patch.Hunks, hunks)...)
+				fr.RejectFile = patch.FilePath + ".rej"
+				if apply {
+					fr.Status = "partial"
+				}
+			}
+		}
+		if op == "delete" && apply && len(patched) > 0 {
+			return nil, fmt.Errorf("file %s is deleted by the diff but %d bytes of it are not covered by the hunks", patch.FilePath, len(patched))
 		}
 
-		// 写入新内容
-		if err := os.WriteFile(absPath, patchedContent, 0644); err != nil {
-			return appliedFiles, fmt.Errorf("failed to write patched file: %w", err)
+		if apply {
+			switch op {
+			case "delete":
+				src.remove = true
+			default:
+				mode := src.mode
+				if op == "create" {
+					mode = 0644
+				}
+				dst.mode, _ = applyGitMode(mode, patch.NewMode)
+				dst.data, dst.remove = patched, false
+				if op == "rename" {
+					src.remove = true
+				}
+			}
+		}
+		result.Files = append(result.Files, fr)
+		targets = append(targets, dst)
+	}
+	for i, c := range targets {
+		if c.present() {
+			result.Files[i].Hash = contentHash(c.data, true)
 		}
+	}
 
-		// 写入成功，删除备份
-		if statErr == nil {
-			os.Remove(backupPath)
+	var patchErr error
+	if failedHunks > 0 {
+		patchErr = fmt.Errorf("patch does not apply: %d of %d hunks failed; %s", failedHunks, totalHunks, strings.Join(failures, "; "))
+		if !opts.WriteRejects {
+			return result, patchErr
 		}
+	}
 
-		appliedFiles = append(appliedFiles, patch.FilePath)
+	// 2. 只提交有变化的文件，并记入编辑日志
+	var commit []*fileChange
+	var paths []string
+	for i, c := range t.changes {
+		if c.changed() {
+			commit = append(commit, c)
+			paths = append(paths, w.relSlash(c.absPath))
+			result.Changed = append(result.Changed, t.paths[i])
+		}
+	}
+	if opts.DryRun {
+		return result, patchErr
+	}
+	id, err := w.commitEdit(EditApplyDiff, paths, commit)
+	if err != nil && id == 0 {
+		result.Changed = []string{}
+		return result, err
+	}
+	for _, c := range commit {
+		if c.present() {
+			w.rememberContent(c.data)
+		}
 	}
+	result.EditID = id
+	return result, errors.Join(patchErr, err)
+}
 
-	return appliedFiles, nil
+// patchTxn 一次 ApplyPatch 涉及的所有文件（按首次出现的顺序）
+type patchTxn struct {
+	changes []*fileChange
+	paths   []string // 与 changes 对应的工作区相对路径
+	byPath  map[string]*fileChange
 }
 
-// SearchAndReplace 在指定文件中进行精确字符串替换
-// expectedOccurrences 期望替换的次数，实际次数不符时返回错误
-// TODO(hands_search_and_replace_impl):
-//  1. 检查 old 非空，expectedOccurrences >= 0，否则返回参数错误。
-//  2. 使用 w.sanitizePath(path) 和 w.isBlockedExtension 校验路径与扩展名。
-//  3. 读取文件内容为字符串，统计 strings.Count(content, old) 为 actualOccurrences。
-//  4. 若 expectedOccurrences > 0 且 actualOccurrences != expectedOccurrences：
-//     - 返回错误，提醒 Agent 先检查上下文，避免误替换。
-//  5. 若 expectedOccurrences == 0：
-//     - 代表 dry-run，只返回 actualOccurrences，不写入文件。
-//  6. 执行 strings.ReplaceAll(content, old, new)，并采用 tmp + rename 的原子写入方式写回文件。
-func (w *OSWorkspace) SearchAndReplace(ctx context.Context, path, old, new string, expectedOccurrences int) (actualOccurrences int, err error) {
-	// 参数检查
-	if old == "" {
-		return 0, fmt.Errorf("search string cannot be empty")
-	}
-	if expectedOccurrences < 0 {
-		return 0, fmt.Errorf("expectedOccurrences cannot be negative")
-	}
-
-	// 安全检查
+// patchFile 校验路径并返回文件的待写入状态；同一文件多次出现时返回同一个状态，补丁依次叠加
+func (w *OSWorkspace) patchFile(t *patchTxn, path string) (*fileChange, error) {
 	absPath, err := w.sanitizePath(path)
 	if err != nil {
-		return 0, fmt.Errorf("path security check failed: %w", err)
+		return nil, fmt.Errorf("invalid file path %q in diff: %w", path, err)
 	}
 	if w.isBlockedExtension(absPath) {
-		return 0, fmt.Errorf("file extension is blocked")
+		return nil, fmt.Errorf("blocked extension for file %s", absPath)
 	}
-
-	// 读取文件内容
-	data, err := os.ReadFile(absPath)
+	if c, ok := t.byPath[absPath]; ok {
+		return c, nil
+	}
+	c, err := newFileChange(absPath)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read target file %s: %w", path, err)
 	}
+	c.data = c.original
+	t.byPath[absPath] = c
+	t.changes = append(t.changes, c)
+	t.paths = append(t.paths, path)
+	return c, nil
+}
 
-	content := string(data)
-
-	// 计数并替换
-	actualOccurrences = strings.Count(content, old)
-	if expectedOccurrences > 0 && actualOccurrences != expectedOccurrences {
-		return actualOccurrences, fmt.Errorf("occurrence count mismatch: expected %d, found %d", expectedOccurrences, actualOccurrences)
+// patchOperation 返回补丁对文件的操作
+func patchOperation(patch DiffPatch) string {
+	switch {
+	case patch.IsNewFile:
+		return "create"
+	case patch.IsDeleted:
+		return "delete"
+	case patch.IsRename:
+		return "rename"
+	case patch.IsCopy:
+		return "copy"
+	default:
+		return "modify"
 	}
+}
 
-	// 如果 expectedOccurrences == 0，视为只查询不写入
-	if expectedOccurrences == 0 {
-		return actualOccurrences, nil
+// applyGitMode 按 git 文件模式（100644 / 100755）设置或清除可执行位；空字符串不改变权限。
+// git 只记录可执行位，其余权限位保持不变；符号链接（120000）与子模块（160000）不支持。
+func applyGitMode(perm os.FileMode, gitMode string) (os.FileMode, error) {
+	if gitMode == "" {
+		return perm, nil
 	}
-
-	// 执行替换
-	newContent := strings.ReplaceAll(content, old, new)
-
-	// 原子写入
-	tmpPath := absPath + ".tmp"
-	if err := os.WriteFile(tmpPath, []byte(newContent), 0644); err != nil {
-		return actualOccurrences, fmt.Errorf("failed to write temporary file: %w", err)
+	m, err := strconv.ParseUint(gitMode, 8, 32)
+	if err != nil {
+		return perm, fmt.Errorf("invalid file mode %q", gitMode)
 	}
-	if err := os.Rename(tmpPath, absPath); err != nil {
-		os.Remove(tmpPath)
-		return actualOccurrences, fmt.Errorf("failed to rename temp file: %w", err)
+	switch m &^ 0o7777 {
+	case 0o100000:
+	case 0o120000:
+		return perm, fmt.Errorf("symlink patches (mode %s) are not supported", gitMode)
+	case 0o160000:
+		return perm, fmt.Errorf("submodule patches (mode %s) are not supported", gitMode)
+	default:
+		return perm, fmt.Errorf("unsupported file mode %q", gitMode)
 	}
-
-	return actualOccurrences, nil
+	if m&0o111 != 0 {
+		return perm | 0o111, nil
+	}
+	return perm &^ 0o111, nil
 }
 
 // --- unified diff 解析辅助结构 ---
 
 type DiffPatch struct {
-	FilePath     string `json:"file_path"`     // 目标文件路径
-	IsNewFile    bool   `json:"is_new_file"`   // 是否为新增文件
-	Hunks        []Hunk `json:"hunks"`         // 补丁块列表
-	OriginalMode string `json:"original_mode"` // 原始文件模式（可选）
-	NewMode      string `json:"new_mode"`      // 新文件模式（可选）
+	FilePath     string `json:"file_path"`          // 目标文件路径（删除时为被删除的文件）
+	OldPath      string `json:"old_path,omitempty"` // 重命名/复制的源文件路径
+	IsNewFile    bool   `json:"is_new_file"`        // 是否为新增文件
+	IsDeleted    bool   `json:"is_deleted"`         // 是否删除文件
+	IsRename     bool   `json:"is_rename"`          // rename from/to
+	IsCopy       bool   `json:"is_copy"`            // copy from/to
+	IsBinary     bool   `json:"is_binary"`          // 二进制补丁（不支持）
+	Hunks        []Hunk `json:"hunks"`              // 补丁块列表
+	OriginalMode string `json:"original_mode"`      // 原始文件模式（git 的 old mode / deleted file mode）
+	NewMode      string `json:"new_mode"`           // 新文件模式（git 的 new mode / new file mode）
 }
 
 type Hunk struct {
@@ -208,161 +333,214 @@ type Hunk struct {
 }
 
 type Line struct {
-	Type string `json:"type"` // "+" 添加, "-" 删除, " " 上下文
-	Text string `json:"text"` // 行内容（不含前导符号）
+	Type      string `json:"type"`                 // "+" 添加, "-" 删除, " " 上下文
+	Text      string `json:"text"`                 // 行内容（不含前导符号）
+	NoNewline bool   `json:"no_newline,omitempty"` // 其后跟有 "\ No newline at end of file"
 }
 
-// parseUnifiedDiff 解析 unified diff 格式文本
-// 简化实现，支持标准 --- a/file / +++ b/file 格式
-// TODO(hands_parse_unified_diff_impl):
-//  1. 将 diff 文本按行分割，遍历时识别：
-//     - 文件头：以 "--- " 和 "+++ " 开头的行；
-//     - hunk 头：以 "@@ " 开头的行；
-//     - hunk 内容：以 '+', '-', ' ' 开头的行。
-//  2. 每遇到新的文件头，结束上一个 DiffPatch，将收集到的 Hunks 正常化（normalizeHunks）。
-//  3. 通过 parseHunkHeader 解析每个 hunk 的 old/new 行号与行数。
-//  4. 遍历结束后，补充最后一个 patch 和 hunk，并调用 isNewFilePatch 判断是否为新文件补丁。
+// parseUnifiedDiff 解析 unified diff 格式文本（git diff、diff -u 及手写的补丁）：
+//  1. "diff --git" 开始一个文件，其后的扩展头（new/deleted file mode、old/new mode、rename/copy from/to、index）
+//     与 ---/+++ 都属于该文件，因此只改模式或纯重命名（没有 hunk）的文件也会被保留。
+//  2. 没有 git 头时，文件头为紧邻的 "--- " 与 "+++ " 两行，路径去掉 a/、b/ 前缀和制表符后的时间戳；
+//     旧路径为 /dev/null 时是新建文件，新路径为 /dev/null 时是删除文件。
+//  3. hunk 内以 ' '、'+'、'-' 开头的行为内容，空行视为空白上下文（编辑器常会去掉行尾空格），
+//     "\ " 开头的行标记上一行没有换行符；其他行结束当前 hunk。hunk 末尾超出 @@ 行数的空行不计入上下文。
+//  4. "Binary files ... differ" 与 "GIT binary patch" 标记为二进制补丁，由 ApplyPatch 拒绝。
 func parseUnifiedDiff(diffText string) ([]DiffPatch, error) {
 	var patches []DiffPatch
 	lines := strings.Split(diffText, "\n")
 
 	var currentPatch *DiffPatch
 	var currentHunk *Hunk
-	inHunk := false
+	gitPatch := false // currentPatch 以 diff --git 开始
+	flushHunk := func() {
+		if currentHunk == nil {
+			return
+		}
+		for n := len(currentHunk.Lines); n > 0 && currentHunk.Lines[n-1] == (Line{Type: " "}) && oldLen(currentHunk.Lines) > currentHunk.OldCount; n-- {
+			currentHunk.Lines = currentHunk.Lines[:n-1]
+		}
+		currentPatch.Hunks = append(currentPatch.Hunks, *currentHunk)
+		currentHunk = nil
+	}
+	flushPatch := func() {
+		flushHunk()
+		if currentPatch != nil && (len(currentPatch.Hunks) > 0 || gitPatch || currentPatch.IsBinary) {
+			currentPatch.Hunks = normalizeHunks(currentPatch.Hunks)
+			currentPatch.IsNewFile = currentPatch.IsNewFile || isNewFilePatch(*currentPatch)
+			patches = append(patches, *currentPatch)
+		}
+		currentPatch = nil
+		gitPatch = false
+	}
 
 	for i := 0; i < len(lines); i++ {
 		line := lines[i]
-
-		// 检测文件头：--- a/path / +++ b/path
-		if strings.HasPrefix(line, "--- ") && !strings.HasPrefix(line, "--- /dev/null") {
-			// 结束上一个 patch
-			if currentPatch != nil && currentHunk != nil {
-				currentPatch.Hunks = append(currentPatch.Hunks, *currentHunk)
-				currentPatch.Hunks = normalizeHunks(currentPatch.Hunks)
-				patches = append(patches, *currentPatch)
-			}
-
-			// 提取文件路径（移除 a/ 或 b/ 前缀）
-			origPath := strings.TrimPrefix(line[4:], "a/")
-			origPath = strings.TrimPrefix(origPath, "b/")
-			currentPatch = &DiffPatch{
-				FilePath: origPath,
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushPatch()
+			_, newPath := splitGitDiffPaths(line[len("diff --git "):])
+			currentPatch = &DiffPatch{FilePath: newPath}
+			gitPatch = true
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// 文件头：--- a/path / +++ b/path；紧跟 git 头时属于同一个文件
+			if !gitPatch || currentHunk != nil || len(currentPatch.Hunks) > 0 {
+				flushPatch()
+				currentPatch = &DiffPatch{}
 			}
-			currentHunk = nil
-			inHunk = false
-			continue
-		}
-
-		if strings.HasPrefix(line, "+++ ") && currentPatch != nil {
-			// 提取文件路径（同上）
-			newPath := strings.TrimPrefix(line[4:], "b/")
-			newPath = strings.TrimPrefix(newPath, "a/")
-			// 通常新旧路径相同
-			if newPath != "/dev/null" {
+			oldPath, newPath := diffHeaderPath(line[4:]), diffHeaderPath(lines[i+1][4:])
+			switch {
+			case oldPath == "/dev/null":
+				currentPatch.IsNewFile = true
+				currentPatch.FilePath = newPath
+			case newPath == "/dev/null":
+				currentPatch.IsDeleted = true
+				currentPatch.FilePath = oldPath
+			default:
 				currentPatch.FilePath = newPath
 			}
-			continue
-		}
-
-		// 检测 hunk 头：@@ -oldStart,oldCount +newStart,newCount @@
-		if strings.HasPrefix(line, "@@ ") && currentPatch != nil {
+			i++
+		case strings.HasPrefix(line, "@@ ") && currentPatch != nil:
+			// hunk 头：@@ -oldStart,oldCount +newStart,newCount @@
+			flushHunk()
 			hunk, err := parseHunkHeader(line)
 			if err != nil {
 				return nil, fmt.Errorf("invalid hunk header at line %d: %w", i+1, err)
 			}
-			// 保存上一个 hunk
-			if currentHunk != nil {
-				currentPatch.Hunks = append(currentPatch.Hunks, *currentHunk)
-			}
 			currentHunk = &hunk
-			inHunk = true
-			continue
-		}
-
-		// 处理行内容
-		if inHunk && currentHunk != nil && (len(line) > 0) {
-			lineType := line[0]
-			lineContent := line[1:]
-			if lineType == '+' || lineType == '-' || lineType == ' ' {
-				currentHunk.Lines = append(currentHunk.Lines, Line{
-					Type: string(lineType),
-					Text: lineContent,
-				})
+		case currentHunk != nil && (line == "" || line[0] == ' ' || line[0] == '+' || line[0] == '-'):
+			l := Line{Type: " "}
+			if line != "" {
+				l = Line{Type: line[:1], Text: line[1:]}
+			}
+			currentHunk.Lines = append(currentHunk.Lines, l)
+		case currentHunk != nil && strings.HasPrefix(line, "\\"):
+			// "\ No newline at end of file" 作用于上一行
+			if n := len(currentHunk.Lines); n > 0 {
+				currentHunk.Lines[n-1].NoNewline = true
+			}
+		case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"):
+			flushHunk()
+			if currentPatch == nil {
+				// diff -u 对二进制文件只输出这一行
+				oldPath, newPath, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(line, "Binary files "), " differ"), " and ")
+				currentPatch = &DiffPatch{FilePath: diffHeaderPath(newPath)}
+				if currentPatch.FilePath == "/dev/null" {
+					currentPatch.FilePath = diffHeaderPath(oldPath)
+				}
 			}
+			currentPatch.IsBinary = true
+		case line == "GIT binary patch" && currentPatch != nil:
+			flushHunk()
+			currentPatch.IsBinary = true
+		case gitPatch && currentHunk == nil && len(currentPatch.Hunks) == 0 && parseGitHeader(currentPatch, line):
+			// git 扩展头
+		default:
+			flushHunk()
 		}
 	}
+	flushPatch()
 
-	// 最后一个 hunk 和 patch
-	if currentPatch != nil && currentHunk != nil {
-		currentPatch.Hunks = append(currentPatch.Hunks, *currentHunk)
-		currentPatch.Hunks = normalizeHunks(currentPatch.Hunks)
-		patches = append(patches, *currentPatch)
+	return patches, nil
+}
+
+// parseGitHeader 解析 git 扩展头，不是扩展头时返回 false
+func parseGitHeader(p *DiffPatch, line string) bool {
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		p.IsNewFile = true
+		p.NewMode = strings.TrimPrefix(line, "new file mode ")
+	case strings.HasPrefix(line, "deleted file mode "):
+		p.IsDeleted = true
+		p.OriginalMode = strings.TrimPrefix(line, "deleted file mode ")
+	case strings.HasPrefix(line, "old mode "):
+		p.OriginalMode = strings.TrimPrefix(line, "old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		p.NewMode = strings.TrimPrefix(line, "new mode ")
+	case strings.HasPrefix(line, "rename from "):
+		p.IsRename = true
+		p.OldPath = gitPath(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		p.IsRename = true
+		p.FilePath = gitPath(strings.TrimPrefix(line, "rename to "))
+	case strings.HasPrefix(line, "copy from "):
+		p.IsCopy = true
+		p.OldPath = gitPath(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		p.IsCopy = true
+		p.FilePath = gitPath(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "index "), strings.HasPrefix(line, "similarity index "), strings.HasPrefix(line, "dissimilarity index "):
+	default:
+		return false
 	}
+	return true
+}
 
-	// 检测是否为新增文件
-	for i := range patches {
-		patches[i].IsNewFile = isNewFilePatch(patches[i])
+// splitGitDiffPaths 解析 "diff --git a/old b/new" 中的两个路径
+func splitGitDiffPaths(s string) (oldPath, newPath string) {
+	if strings.HasPrefix(s, `"`) {
+		if q, err := strconv.QuotedPrefix(s); err == nil {
+			return diffHeaderPath(q), diffHeaderPath(strings.TrimSpace(s[len(q):]))
+		}
 	}
+	// 路径可能含空格：未重命名时两侧相同，从中间切分；否则取最后一个 " b/"
+	if half := len(s) / 2; len(s)%2 == 1 && s[half] == ' ' && strings.TrimPrefix(s[:half], "a/") == strings.TrimPrefix(s[half+1:], "b/") {
+		return diffHeaderPath(s[:half]), diffHeaderPath(s[half+1:])
+	}
+	if i := strings.LastIndex(s, " b/"); i >= 0 {
+		return diffHeaderPath(s[:i]), diffHeaderPath(s[i+1:])
+	}
+	return diffHeaderPath(s), diffHeaderPath(s)
+}
 
-	return patches, nil
+// diffHeaderPath 从 ---/+++ 行提取路径：去掉制表符后的时间戳、引号和 a/、b/ 前缀
+func diffHeaderPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = gitPath(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
 }
 
-// parseHunkHeader 解析 @@ -start,count +start,count @@
-// TODO(hands_parse_hunk_header_impl):
-//  1. 去掉开头和结尾的 "@@"，并使用 strings.TrimSpace 清理空格。
-//  2. 按空格或 "+" 分割为旧行信息和新行信息两个部分。
-//  3. 对 "start,count" 格式分别解析：
-//     - 若包含逗号，拆分为 start 和 count；
-//     - 否则将 count 视为 1。
-func parseHunkHeader(line string) (Hunk, error) {
-	// 移除 @@ 包裹
-	content := strings.TrimSpace(line[2 : len(line)-2])
-	parts := strings.Split(content, " +")
-	if len(parts) != 2 {
-		return Hunk{}, fmt.Errorf("invalid format")
-	}
-
-	var hunk Hunk
-	// 解析旧部分：-start,count
-	oldPart := strings.TrimPrefix(parts[0], "-")
-	if strings.Contains(oldPart, ",") {
-		p := strings.Split(oldPart, ",")
-		if len(p) == 2 {
-			fmt.Sscanf(p[0], "%d", &hunk.OldStart)
-			fmt.Sscanf(p[1], "%d", &hunk.OldCount)
+// gitPath 还原 git 对含特殊字符路径的引号转义（"a/\303\244.txt"）
+func gitPath(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
 		}
-	} else {
-		fmt.Sscanf(oldPart, "%d", &hunk.OldStart)
-		hunk.OldCount = 1
-	}
-
-	// 解析新部分：+start,count
-	newPart := parts[1]
-	if strings.Contains(newPart, ",") {
-		p := strings.Split(newPart, ",")
-		if len(p) == 2 {
-			fmt.Sscanf(p[0], "%d", &hunk.NewStart)
-			fmt.Sscanf(p[1], "%d", &hunk.NewCount)
-		}
-	} else {
-		fmt.Sscanf(newPart, "%d", &hunk.NewStart)
-		hunk.NewCount = 1
 	}
+	return s
+}
 
-	return hunk, nil
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseHunkHeader 解析 @@ -start,count +start,count @@（count 省略时为 1，@@ 之后的函数名等忽略）
+func parseHunkHeader(line string) (Hunk, error) {
+	m := hunkHeaderPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Hunk{}, fmt.Errorf("invalid format %q", line)
+	}
+	num := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return Hunk{OldStart: num(m[1]), OldCount: num(m[2]), NewStart: num(m[3]), NewCount: num(m[4])}, nil
 }
 
-// isNewFilePatch 判断是否为新增文件（新旧内容都源自 /dev/null 或旧文件为空）
-// TODO(hands_is_new_file_patch_impl):
-//  1. 当前简化策略：如果所有 hunk 中没有删除行（'-'），则视为新文件补丁。
-//  2. 如后续需要更精确，可结合 diff 头信息（/dev/null）进行判断。
+// isNewFilePatch 没有 /dev/null 或 git 头时推断是否为新增文件：所有 hunk 的旧范围都为空（@@ -0,0 ...）
 func isNewFilePatch(patch DiffPatch) bool {
-	// 如果所有 hunk 的行都是添加（+），没有删除（-）和上下文（ ），视为新文件
+	if len(patch.Hunks) == 0 || patch.IsDeleted || patch.IsRename || patch.IsCopy {
+		return false
+	}
 	for _, hunk := range patch.Hunks {
-		for _, line := range hunk.Lines {
-			if line.Type == "-" {
-				return false
-			}
+		if hunk.OldStart != 0 || hunk.OldCount != 0 {
+			return false
 		}
 	}
 	return true
@@ -378,75 +556,3 @@ func normalizeHunks(hunks []Hunk) []Hunk {
 	})
 	return hunks
 }
-
-// applyPatchToContent 将 parsed patch 应用到原始内容
-// TODO(hands_apply_patch_to_content_impl):
-//  1. 将 original 按行分割为切片 originalLines，并保留换行符。
-//  2. 遍历每个 Hunk：
-//     - 将 [oldLineNum, hunk.OldStart-1] 范围的原始行复制到结果。
-//     - 在 Hunk 内部：
-//     - '-' 行跳过相应原始行；
-//     - '+' 行直接追加新行；
-//     - ' ' 行复制对应原始行。
-//  3. 所有 Hunk 应用完之后，将剩余原始行追加到结果中，最终 Join 成字符串。
-//  4. 注意保持最后一个换行符的语义与原始文件一致。
-func applyPatchToContent(original []byte, patch DiffPatch) ([]byte, error) {
-	originalLines := strings.Split(string(original), "\n")
-	if len(originalLines) > 0 && originalLines[len(originalLines)-1] == "" {
-		originalLines = originalLines[:len(originalLines)-1]
-	}
-
-	// 将行索引构建为切片（1-indexed）
-	for i := range originalLines {
-		originalLines[i] = originalLines[i] + "\n"
-	}
-
-	var result []string
-	oldLineNum := 1
-
-	for _, hunk := range patch.Hunks {
-		// 处理 hunk 开始前的上下文：复制 [oldLineNum, hunk.OldStart-1] 的原始行
-		for oldLineNum < hunk.OldStart {
-			if oldLineNum-1 < len(originalLines) {
-				result = append(result, originalLines[oldLineNum-1])
-			}
-			oldLineNum++
-		}
-
-		// 处理 hunk 内部
-		for _, line := range hunk.Lines {
-			if line.Type == "-" {
-				// 删除行：跳过原始文件的这一行
-				if oldLineNum-1 < len(originalLines) && strings.TrimSuffix(originalLines[oldLineNum-1], "\n") == line.Text {
-					oldLineNum++
-				}
-			} else if line.Type == "+" {
-				// 添加行：直接追加到结果
-				result = append(result, line.Text+"\n")
-			} else if line.Type == " " {
-				// 上下文行：复制原始文件
-				if oldLineNum-1 < len(originalLines) {
-					result = append(result, originalLines[oldLineNum-1])
-					oldLineNum++
-				} else {
-					// 如果原始文件行数不足，添加空行
-					result = append(result, line.Text+"\n")
-				}
-			}
-		}
-	}
-
-	// 追加剩余部分
-	for oldLineNum-1 < len(originalLines) {
-		result = append(result, originalLines[oldLineNum-1])
-		oldLineNum++
-	}
-
-	final := strings.Join(result, "")
-	// 确保最后没有多余的换行符（与原始文件行为一致）
-	if len(original) > 0 && !bytes.HasSuffix(original, []byte{'\n'}) {
-		final = strings.TrimSuffix(final, "\n")
-	}
-
-	return []byte(final), nil
-}
@@ -38,6 +38,30 @@ func TestParseUnifiedDiff(t *testing.T) {
 	}
 }
 
+func TestParseUnifiedDiff_GitHeaders(t *testing.T) {
+	diff := "diff --git a/my file.txt b/my file.txt\nold mode 100644\nnew mode 100755\n" +
+		"diff --git \"a/caf\\303\\251.txt\" \"b/caf\\303\\251.txt\"\ndeleted file mode 100644\n"
+	patches, err := parseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatalf("parseUnifiedDiff failed: %v", err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("expected 2 patches, got %+v", patches)
+	}
+	if p := patches[0]; p.FilePath != "my file.txt" || p.OriginalMode != "100644" || p.NewMode != "100755" || p.IsNewFile {
+		t.Errorf("mode change parsed as %+v", p)
+	}
+	if p := patches[1]; p.FilePath != "café.txt" || !p.IsDeleted || p.IsBinary {
+		t.Errorf("quoted delete parsed as %+v", p)
+	}
+
+	// diff -u 对二进制文件只输出一行
+	patches, _ = parseUnifiedDiff("Binary files a/img.png and /dev/null differ\n")
+	if len(patches) != 1 || patches[0].FilePath != "img.png" || !patches[0].IsBinary {
+		t.Errorf("binary parsed as %+v", patches)
+	}
+}
+
 func TestApplyPatchToContent(t *testing.T) {
 	original := []byte("line1\nline2\nline3\n")
 	diff := `--- a/test.txt
@@ -49,9 +73,9 @@ func TestApplyPatchToContent(t *testing.T) {
  line3
 `
 	patches, _ := parseUnifiedDiff(diff)
-	patched, err := applyPatchToContent(original, patches[0])
-	if err != nil {
-		t.Fatalf("applyPatchToContent failed: %v", err)
+	patched, results := applyPatchToContent(original, patches[0], 0, 0)
+	if len(results) != 1 || results[0].Status != HunkApplied {
+		t.Fatalf("unexpected hunk results: %+v", results)
 	}
 	expected := "line1\nnew line2\nline3\n"
 	if string(patched) != expected {
@@ -93,6 +117,186 @@ func TestOSWorkspace_ApplyUnifiedDiff(t *testing.T) {
 	}
 }
 
+func TestOSWorkspace_ApplyPatchFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: tmpDir})
+	os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("one\ntwo\nthree\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("1\n2\n3\n4\n5\n6\n7\n8\n"), 0644)
+
+	// a.txt 可以应用，b.txt 的第二个 hunk 已过期：两个文件都不应被修改
+	diff := `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+-1
++one
+ 2
+@@ -6,3 +6,3 @@
+ 6
+-seven
++SEVEN
+ 8
+`
+	result, err := ws.ApplyPatch(context.Background(), diff, PatchOptions{Fuzz: DefaultPatchFuzz, MaxOffset: -1})
+	if err == nil || !strings.Contains(err.Error(), `expected "seven", found "7"`) {
+		t.Fatalf("expected stale hunk error with expected/actual lines, got %v", err)
+	}
+	if result == nil || len(result.Changed) != 0 || len(result.Files) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	h := result.Files[1].Hunks[1]
+	if h.Status != HunkFailed || h.Line != 6 || strings.Join(h.Actual, ",") != "6,7,8" || strings.Join(h.Expected, ",") != "6,seven,8" {
+		t.Errorf("unexpected failed hunk %+v", h)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "a.txt")); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("a.txt modified despite failure: %q", data)
+	}
+
+	// writeRejects：成功的 hunk 写入，失败的 hunk 写入 .rej
+	result, err = ws.ApplyPatch(context.Background(), diff, PatchOptions{Fuzz: DefaultPatchFuzz, MaxOffset: -1, WriteRejects: true})
+	if err == nil {
+		t.Fatal("expected error for failed hunk")
+	}
+	if strings.Join(result.Changed, ",") != "a.txt,b.txt,b.txt.rej" || result.Files[1].Status != "partial" || result.Files[1].RejectFile != "b.txt.rej" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "b.txt")); string(data) != "one\n2\n3\n4\n5\n6\n7\n8\n" {
+		t.Errorf("b.txt = %q", data)
+	}
+	rej, _ := os.ReadFile(filepath.Join(tmpDir, "b.txt.rej"))
+	if !strings.Contains(string(rej), "@@ -6,3 +6,3 @@\n 6\n-seven\n+SEVEN\n 8\n") || strings.Contains(string(rej), "+one") {
+		t.Errorf("unexpected reject file:\n%s", rej)
+	}
+
+	// 新建文件（--- /dev/null），末尾无换行
+	diff = `diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+\ No newline at end of file
+`
+	if _, err := ws.ApplyPatch(context.Background(), diff, PatchOptions{}); err != nil {
+		t.Fatalf("new file patch failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "new.txt")); string(data) != "hello\nworld" {
+		t.Errorf("new.txt = %q", data)
+	}
+}
+
+func TestOSWorkspace_ApplyPatchGitOperations(t *testing.T) {
+	tmpDir := t.TempDir()
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: tmpDir})
+	write := func(name, content string, mode os.FileMode) {
+		os.WriteFile(filepath.Join(tmpDir, name), []byte(content), mode)
+	}
+	write("old.txt", "one\ntwo\n", 0644)
+	write("gone.txt", "bye\n", 0644)
+	write("run.sh", "echo hi\n", 0644)
+	write("src.txt", "shared\n", 0644)
+
+	diff := `diff --git a/old.txt b/renamed.txt
+similarity index 50%
+rename from old.txt
+rename to renamed.txt
+index 1111111..2222222 100644
+--- a/old.txt
++++ b/renamed.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 3333333..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/src.txt b/copy.txt
+similarity index 100%
+copy from src.txt
+copy to copy.txt
+diff --git a/tool.sh b/tool.sh
+new file mode 100755
+index 0000000..4444444
+--- /dev/null
++++ b/tool.sh
+@@ -0,0 +1 @@
++echo tool
+diff --git a/empty.txt b/empty.txt
+new file mode 100644
+index 0000000..e69de29
+`
+	result, err := ws.ApplyPatch(context.Background(), diff, PatchOptions{})
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	ops := make([]string, 0, len(result.Files))
+	for _, f := range result.Files {
+		ops = append(ops, f.Operation+":"+f.Path)
+	}
+	if got := strings.Join(ops, ","); got != "rename:renamed.txt,delete:gone.txt,modify:run.sh,copy:copy.txt,create:tool.sh,create:empty.txt" {
+		t.Errorf("operations = %s", got)
+	}
+	if got := strings.Join(result.Changed, ","); got != "old.txt,renamed.txt,gone.txt,run.sh,copy.txt,tool.sh,empty.txt" {
+		t.Errorf("changed = %s", got)
+	}
+
+	checks := map[string]string{"renamed.txt": "one\nTWO\n", "copy.txt": "shared\n", "src.txt": "shared\n", "tool.sh": "echo tool\n", "empty.txt": ""}
+	for name, want := range checks {
+		if data, err := os.ReadFile(filepath.Join(tmpDir, name)); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+	for _, name := range []string{"old.txt", "gone.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed", name)
+		}
+	}
+	for name, want := range map[string]os.FileMode{"run.sh": 0755, "tool.sh": 0755, "empty.txt": 0644} {
+		if info, err := os.Stat(filepath.Join(tmpDir, name)); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s mode = %v, want %v", name, info.Mode().Perm(), want)
+		}
+	}
+
+	// 删除补丁与文件内容不一致时拒绝
+	write("keep.txt", "a\nb\n", 0644)
+	diff = `diff --git a/keep.txt b/keep.txt
+deleted file mode 100644
+--- a/keep.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-a
+`
+	if _, err := ws.ApplyPatch(context.Background(), diff, PatchOptions{}); err == nil || !strings.Contains(err.Error(), "not covered") {
+		t.Errorf("expected partial delete rejection, got %v", err)
+	}
+
+	// 二进制补丁明确拒绝
+	diff = `diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+`
+	if _, err := ws.ApplyPatch(context.Background(), diff, PatchOptions{}); err == nil || !strings.Contains(err.Error(), "binary patch for logo.png is not supported") {
+		t.Errorf("expected binary rejection, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "keep.txt")); err != nil {
+		t.Errorf("keep.txt should still exist: %v", err)
+	}
+}
+
 func TestOSWorkspace_SearchAndReplace(t *testing.T) {
 	tmpDir := t.TempDir()
 	cfg := &config.Config{
@@ -104,21 +308,24 @@ func TestOSWorkspace_SearchAndReplace(t *testing.T) {
 	os.WriteFile(file, []byte("foo bar foo baz foo"), 0644)
 
 	// dry-run: 仅统计
-	count, err := ws.SearchAndReplace(context.Background(), "swap.txt", "foo", "qux", 0)
+	res, err := ws.SearchAndReplace(context.Background(), ReplaceOptions{Path: "swap.txt", Edits: []ReplaceEdit{{Old: "foo", New: "qux"}}, DryRun: true})
 	if err != nil {
 		t.Fatalf("SearchAndReplace dry-run failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("expected 3 occurrences, got %d", count)
+	if res.Occurrences[0] != 3 || len(res.Files[0].Matches) != 3 {
+		t.Errorf("expected 3 occurrences, got %+v", res)
+	}
+	if m := res.Files[0].Matches[1]; m.Line != 1 || m.After != "foo bar qux baz foo" {
+		t.Errorf("unexpected preview: %+v", m)
 	}
 
 	// 实际替换
-	count, err = ws.SearchAndReplace(context.Background(), "swap.txt", "foo", "qux", 3)
+	res, err = ws.SearchAndReplace(context.Background(), ReplaceOptions{Path: "swap.txt", Edits: []ReplaceEdit{{Old: "foo", New: "qux", ExpectedOccurrences: 3}}})
 	if err != nil {
 		t.Fatalf("SearchAndReplace replace failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("expected 3, got %d", count)
+	if res.Replacements != 3 {
+		t.Errorf("expected 3, got %d", res.Replacements)
 	}
 	data, _ := os.ReadFile(file)
 	if !strings.Contains(string(data), "qux") {
@@ -126,8 +333,92 @@ func TestOSWorkspace_SearchAndReplace(t *testing.T) {
 	}
 
 	// 次数不匹配应失败
-	_, err = ws.SearchAndReplace(context.Background(), "swap.txt", "qux", "foo", 2)
+	_, err = ws.SearchAndReplace(context.Background(), ReplaceOptions{Path: "swap.txt", Edits: []ReplaceEdit{{Old: "qux", New: "foo", ExpectedOccurrences: 2}}})
 	if err == nil {
 		t.Error("expected mismatch error")
 	}
 }
+
+func TestOSWorkspace_SearchAndReplaceEdits(t *testing.T) {
+	tmpDir := t.TempDir()
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: tmpDir})
+	ctx := context.Background()
+	file := filepath.Join(tmpDir, "calc.go")
+	os.WriteFile(file, []byte("func add(a, b int) int {\n\treturn a + b\n}\n\nfunc sub(a, b int) int {\n\treturn a - b\n}\n"), 0644)
+
+	// 正则 + 捕获组、只替换第 2 处、按顺序作用于前一组的结果
+	res, err := ws.SearchAndReplace(ctx, ReplaceOptions{Path: "calc.go", Edits: []ReplaceEdit{
+		{Old: `func (\w+)\(a, b int\)`, New: "func ${1}Int(a, b int)", Regex: true, ExpectedOccurrences: 2},
+		{Old: "int {", New: "int64 {", Occurrence: 2},
+		{Old: "subInt", New: "minus"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "func addInt(a, b int) int {\n\treturn a + b\n}\n\nfunc minus(a, b int) int64 {\n\treturn a - b\n}\n"
+	if got, _ := os.ReadFile(file); string(got) != want {
+		t.Fatalf("content = %q, want %q", got, want)
+	}
+	if res.Replacements != 4 || res.Occurrences[1] != 2 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	// 任一组失败时所有替换都不写入
+	_, err = ws.SearchAndReplace(ctx, ReplaceOptions{Path: "calc.go", Edits: []ReplaceEdit{
+		{Old: "addInt", New: "plus"},
+		{Old: "missing", New: "x"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "edit 2: no matches") {
+		t.Fatalf("expected no matches error, got %v", err)
+	}
+	if got, _ := os.ReadFile(file); string(got) != want {
+		t.Errorf("file changed by failed edits: %q", got)
+	}
+	if _, err := ws.SearchAndReplace(ctx, ReplaceOptions{Path: "calc.go", Edits: []ReplaceEdit{{Old: "return", New: "x", Occurrence: 3}}}); err == nil {
+		t.Error("expected occurrence out of range error")
+	}
+}
+
+func TestOSWorkspace_SearchAndReplaceGlob(t *testing.T) {
+	tmpDir := t.TempDir()
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: tmpDir})
+	ctx := context.Background()
+	os.MkdirAll(filepath.Join(tmpDir, "pkg", "sub"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("x := OldName()\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "pkg", "sub", "a.go"), []byte("package sub\n\nfunc OldName() {}\n// OldName again\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "pkg", "notes.txt"), []byte("OldName\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "pkg", "bin.go"), []byte("OldName\x00"), 0644)
+
+	edits := []ReplaceEdit{{Old: "OldName", New: "NewName", ExpectedOccurrences: 3}}
+	res, err := ws.SearchAndReplace(ctx, ReplaceOptions{Glob: "**/*.go", Edits: edits, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 2 || res.Files[1].Path != "pkg/sub/a.go" || len(res.Files[1].Matches) != 2 {
+		t.Fatalf("unexpected dry-run result: %+v", res)
+	}
+	if m := res.Files[1].Matches[1]; m.Line != 4 || m.Before != "// OldName again" || m.After != "// NewName again" {
+		t.Errorf("unexpected preview: %+v", m)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "main.go")); string(data) != "x := OldName()\n" {
+		t.Error("dry-run should not write")
+	}
+
+	if _, err := ws.SearchAndReplace(ctx, ReplaceOptions{Glob: "**/*.go", Edits: edits}); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"main.go":       "x := NewName()\n",
+		"pkg/sub/a.go":  "package sub\n\nfunc NewName() {}\n// NewName again\n",
+		"pkg/notes.txt": "OldName\n",
+		"pkg/bin.go":    "OldName\x00",
+	} {
+		if data, _ := os.ReadFile(filepath.Join(tmpDir, path)); string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+
+	if _, err := ws.SearchAndReplace(ctx, ReplaceOptions{Path: "main.go", Glob: "*.go", Edits: edits}); err == nil {
+		t.Error("expected path/glob conflict error")
+	}
+}
@@ -0,0 +1,221 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 本文件实现遍历类工具（InspectWorkspace / Grep / FindFiles）共用的忽略规则：
+//  1. 规则语法与 .gitignore 一致：# 注释、! 取反、尾部 / 仅匹配目录、含 / 的模式相对所在目录锚定、** 跨层级。
+//  2. 规则来源按优先级从低到高：内置默认规则 < .git/info/exclude < .gitignore（越深的目录优先）< .agentignore。
+//  3. 同一路径以最后一条命中的规则为准，因此 .agentignore 中的 !vendor/ 可以重新包含内置忽略的目录。
+//  4. 与 git 相同，已被忽略的目录不会再进入，其中的文件无法被取反规则重新包含。
+
+// AgentIgnoreFile 项目级忽略文件名（位于工作区根目录），优先级高于 .gitignore
+const AgentIgnoreFile = ".agentignore"
+
+// builtinIgnorePatterns 内置默认规则（常见构建产物、依赖与 IDE 目录），可被 .gitignore/.agentignore 取反覆盖
+var builtinIgnorePatterns = []string{
+	".git/",
+	"node_modules/",
+	"dist/",
+	"build/",
+	".next/",
+	"vendor/",
+	".cache/",
+	".venv/",
+	"__pycache__/",
+	".idea/",
+	".vscode/",
+	"coverage/",
+	".nyc_output/",
+	".DS_Store",
+}
+
+// IgnoreRule 描述一条生效的忽略规则（用于 workspace.health 展示）
+type IgnoreRule struct {
+	Source  string `json:"source"`  // 规则来源，如 "builtin"、".gitignore:3"、"src/.gitignore:1"
+	Pattern string `json:"pattern"` // 原始模式文本
+}
+
+// ignoreRule 解析后的单条规则
+type ignoreRule struct {
+	IgnoreRule
+	base     string   // 规则所在目录（相对工作区根，/ 分隔，根目录为空）
+	segments []string // 按 / 切分的匹配段（未锚定的模式前置 **）
+	negate   bool     // ! 取反
+	dirOnly  bool     // 尾部 /，仅匹配目录
+}
+
+// match 判断相对工作区根的路径 rel 是否命中本规则
+func (r *ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	parts := strings.Split(rel, "/")
+	if !matchSegments(r.segments, parts) {
+		return false
+	}
+	// "dir/**" 只匹配目录内部的内容，不匹配目录本身
+	if n := len(r.segments); n > 1 && r.segments[n-1] == "**" && matchSegments(r.segments[:n-1], parts) {
+		return false
+	}
+	return true
+}
+
+// ignoreMatcher 一次遍历使用的规则集合，.gitignore 在首次访问对应目录时懒加载
+type ignoreMatcher struct {
+	root     string
+	base     []ignoreRule            // 内置规则 + .git/info/exclude
+	agent    []ignoreRule            // .agentignore（最高优先级）
+	dirRules map[string][]ignoreRule // 目录（相对路径）→ 该目录下 .gitignore 的规则
+}
+
+// newIgnoreMatcher 为工作区根目录创建规则集合
+func (w *OSWorkspace) newIgnoreMatcher() *ignoreMatcher {
+	m := &ignoreMatcher{
+		root:     w.root,
+		dirRules: make(map[string][]ignoreRule),
+	}
+	for _, p := range builtinIgnorePatterns {
+		if rule, ok := parseIgnoreLine(p, "", "builtin"); ok {
+			m.base = append(m.base, rule)
+		}
+	}
+	m.base = append(m.base, loadIgnoreFile(filepath.Join(w.root, ".git", "info", "exclude"), "", ".git/info/exclude")...)
+	m.agent = loadIgnoreFile(filepath.Join(w.root, AgentIgnoreFile), "", AgentIgnoreFile)
+	return m
+}
+
+// IgnoreRules 返回当前生效的根级忽略规则（用于 workspace.health 展示）
+func (w *OSWorkspace) IgnoreRules() []IgnoreRule {
+	return w.newIgnoreMatcher().Rules()
+}
+
+// Match 判断条目本身是否被忽略（不检查祖先目录，适用于自顶向下的遍历）
+func (m *ignoreMatcher) Match(rel string, isDir bool) bool {
+	rel = strings.Trim(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+
+	ignored := false
+	apply := func(rules []ignoreRule) {
+		for i := range rules {
+			if rules[i].match(rel, isDir) {
+				ignored = !rules[i].negate
+			}
+		}
+	}
+
+	apply(m.base)
+	// 从根目录到父目录依次应用各级 .gitignore（越深越靠后，优先级越高）
+	apply(m.rulesFor(""))
+	dirs := strings.Split(rel, "/")
+	for i := 1; i < len(dirs); i++ {
+		apply(m.rulesFor(strings.Join(dirs[:i], "/")))
+	}
+	apply(m.agent)
+
+	return ignored
+}
+
+// rulesFor 返回目录 dir（相对路径）下 .gitignore 的规则，带缓存
+func (m *ignoreMatcher) rulesFor(dir string) []ignoreRule {
+	if rules, ok := m.dirRules[dir]; ok {
+		return rules
+	}
+	source := ".gitignore"
+	if dir != "" {
+		source = dir + "/.gitignore"
+	}
+	rules := loadIgnoreFile(filepath.Join(m.root, filepath.FromSlash(dir), ".gitignore"), dir, source)
+	m.dirRules[dir] = rules
+	return rules
+}
+
+// Rules 返回根级别的生效规则（内置、.git/info/exclude、根 .gitignore、.agentignore）
+// 子目录中的 .gitignore 在遍历时按需加载，不在此列出
+func (m *ignoreMatcher) Rules() []IgnoreRule {
+	var all []ignoreRule
+	all = append(all, m.base...)
+	all = append(all, m.rulesFor("")...)
+	all = append(all, m.agent...)
+	result := make([]IgnoreRule, len(all))
+	for i, r := range all {
+		result[i] = r.IgnoreRule
+	}
+	return result
+}
+
+// loadIgnoreFile 读取并解析忽略文件，文件不存在或不可读时返回空
+func loadIgnoreFile(path, base, source string) []ignoreRule {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseIgnoreFile(data, base, source)
+}
+
+// parseIgnoreFile 按行解析 .gitignore 格式内容
+func parseIgnoreFile(data []byte, base, source string) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if rule, ok := parseIgnoreLine(scanner.Text(), base, fmt.Sprintf("%s:%d", source, lineNum)); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine 解析单行规则，空行和注释返回 ok=false
+func parseIgnoreLine(line, base, source string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// 去掉未转义的行尾空格
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{
+		IgnoreRule: IgnoreRule{Source: source, Pattern: line},
+		base:       base,
+	}
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// 开头或中间含 / 的模式相对所在目录锚定，否则匹配任意层级的名称
+	if strings.Contains(line, "/") {
+		rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	} else {
+		rule.segments = []string{"**", line}
+	}
+	return rule, true
+}
@@ -0,0 +1,120 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	cases := []struct {
+		line    string
+		rel     string
+		isDir   bool
+		matched bool
+		negate  bool
+	}{
+		{"*.log", "a/b/debug.log", false, true, false},
+		{"/root.txt", "root.txt", false, true, false},
+		{"/root.txt", "sub/root.txt", false, false, false},
+		{"docs/*.md", "docs/a.md", false, true, false},
+		{"docs/*.md", "x/docs/a.md", false, false, false},
+		{"tmp/", "tmp", true, true, false},
+		{"tmp/", "tmp", false, false, false},
+		{"**/gen", "a/b/gen", true, true, false},
+		{"out/**", "out", true, false, false},
+		{"out/**", "out/x/y.txt", false, true, false},
+		{"!keep.log", "keep.log", false, true, true},
+		{`\#hash`, "#hash", false, true, false},
+	}
+	for _, c := range cases {
+		rule, ok := parseIgnoreLine(c.line, "", "test")
+		if !ok {
+			t.Fatalf("parseIgnoreLine(%q) returned !ok", c.line)
+		}
+		if got := rule.match(c.rel, c.isDir); got != c.matched {
+			t.Errorf("rule %q match(%q, dir=%v) = %v, want %v", c.line, c.rel, c.isDir, got, c.matched)
+		}
+		if rule.negate != c.negate {
+			t.Errorf("rule %q negate = %v, want %v", c.line, rule.negate, c.negate)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment"} {
+		if _, ok := parseIgnoreLine(line, "", "test"); ok {
+			t.Errorf("expected %q to be skipped", line)
+		}
+	}
+}
+
+func TestOSWorkspace_IgnoreRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{RootDir: tmpDir}
+	ws, _ := NewOSWorkspace(cfg)
+
+	write := func(rel, content string) {
+		p := filepath.Join(tmpDir, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(content), 0644)
+	}
+	write(".gitignore", "*.log\n!keep.log\n/generated/\n")
+	write(".git/info/exclude", "scratch.txt\n")
+	write(".agentignore", "!vendor/\nsecret/\n")
+	write(".github/workflows/ci.yml", "on: push")
+	write("app.log", "x")
+	write("keep.log", "x")
+	write("scratch.txt", "x")
+	write("generated/out.go", "package generated")
+	write("pkg/generated/ok.go", "package generated")
+	write("pkg/.gitignore", "local.txt\n")
+	write("pkg/local.txt", "x")
+	write("local.txt", "x")
+	write("vendor/lib/lib.go", "package lib")
+	write("secret/token.txt", "x")
+	write("node_modules/pkg/index.js", "x")
+
+	nodes, err := ws.InspectWorkspace(context.Background(), ".", 5)
+	if err != nil {
+		t.Fatalf("InspectWorkspace failed: %v", err)
+	}
+	paths := make(map[string]bool)
+	for _, n := range nodes {
+		paths[filepath.ToSlash(n.Path)] = true
+	}
+
+	for _, p := range []string{".github/workflows/ci.yml", "keep.log", "pkg/generated/ok.go", "local.txt", "vendor/lib/lib.go", ".gitignore"} {
+		if !paths[p] {
+			t.Errorf("expected %s to be listed", p)
+		}
+	}
+	for _, p := range []string{".git", "app.log", "scratch.txt", "generated", "pkg/local.txt", "secret", "node_modules"} {
+		if paths[p] {
+			t.Errorf("expected %s to be ignored", p)
+		}
+	}
+
+	// grep 与 find_files 使用同一套规则
+	found, _ := ws.FindFiles(context.Background(), FindOptions{Pattern: "*.log"})
+	if found.Total != 1 || found.Files[0].Path != "keep.log" {
+		t.Errorf("unexpected find_files result: %+v", found.Files)
+	}
+	grep, _ := ws.Grep(context.Background(), GrepOptions{Pattern: "package generated"})
+	if len(grep.Matches) != 1 || grep.Matches[0].Path != "pkg/generated/ok.go" {
+		t.Errorf("unexpected grep result: %+v", grep.Matches)
+	}
+
+	// 生效规则可供 health 展示
+	rules := ws.IgnoreRules()
+	sources := make(map[string]bool)
+	for _, r := range rules {
+		sources[r.Source] = true
+	}
+	for _, s := range []string{"builtin", ".git/info/exclude:1", ".gitignore:1", ".agentignore:1"} {
+		if !sources[s] {
+			t.Errorf("missing rule source %s in %+v", s, rules)
+		}
+	}
+}
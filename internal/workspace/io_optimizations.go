@@ -2,7 +2,6 @@ package workspace
 
 import (
 	"fmt"
-	"io"
 	"os"
 )
 
@@ -35,101 +34,6 @@ func (w *OSWorkspace) ensureStreamingIO() {
 	// 无需额外实现，此 TODO 仅作为设计确认
 }
 
-// BackupAndRollback 提供写操作前的自动备份和回滚能力
-// 返回两个函数：createBackup 和 rollback，分别用于创建备份和执行回滚
-func (w *OSWorkspace) BackupAndRollback(path string) (createBackup func() error, rollback func() error) {
-	absPath, err := w.sanitizePath(path)
-	if err != nil {
-		return func() error { return fmt.Errorf("invalid path: %w", err) },
-			func() error { return fmt.Errorf("invalid path: %w", err) }
-	}
-
-	backupPath := absPath + ".bak"
-
-	createBackup = func() error {
-		// 检查原文件是否存在
-		_, err := os.Stat(absPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				// 文件不存在，无需备份，返回成功
-				return nil
-			}
-			return fmt.Errorf("failed to stat file for backup: %w", err)
-		}
-
-		// 复制到备份文件
-		src, err := os.Open(absPath)
-		if err != nil {
-			return fmt.Errorf("failed to open source for backup: %w", err)
-		}
-		defer src.Close()
-
-		dst, err := os.Create(backupPath)
-		if err != nil {
-			return fmt.Errorf("failed to create backup file: %w", err)
-		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, src); err != nil {
-			os.Remove(backupPath)
-			return fmt.Errorf("failed to copy to backup: %w", err)
-		}
-
-		// 同步到磁盘
-		dst.Sync()
-		return nil
-	}
-
-	rollback = func() error {
-		// 检查备份文件是否存在
-		_, err := os.Stat(backupPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("backup file not found")
-			}
-			return fmt.Errorf("failed to stat backup: %w", err)
-		}
-
-		// 将备份复制回原文件（原子替换）
-		// 先删除可能存在的中间 .tmp 文件
-		tmpPath := absPath + ".tmp"
-		os.Remove(tmpPath)
-
-		// 复制
-		src, err := os.Open(backupPath)
-		if err != nil {
-			return fmt.Errorf("failed to open backup for restore: %w", err)
-		}
-		defer src.Close()
-
-		dst, err := os.Create(tmpPath)
-		if err != nil {
-			return fmt.Errorf("failed to create temp for rollback: %w", err)
-		}
-		defer func() {
-			dst.Close()
-			if err != nil {
-				os.Remove(tmpPath)
-			}
-		}()
-
-		if _, err := io.Copy(dst, src); err != nil {
-			return fmt.Errorf("failed to copy during rollback: %w", err)
-		}
-		dst.Close()
-
-		// 原子替换
-		if err := os.Rename(tmpPath, absPath); err != nil {
-			os.Remove(tmpPath)
-			return fmt.Errorf("failed to rename during rollback: %w", err)
-		}
-
-		return nil
-	}
-
-	return createBackup, rollback
-}
-
 // PhysicalFileSize 获取文件的实际磁盘占用大小（用于估算磁盘空间）
 // 注意：这返回的是 st_blocks * 512，而非 st_size
 func (w *OSWorkspace) PhysicalFileSize(path string) (int64, error) {
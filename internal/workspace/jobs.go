@@ -0,0 +1,368 @@
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现后台任务（开发服务器、watch 模式测试等长时间运行的命令）：
+//  1. JobManager.Start 在后台 goroutine 中调用 ExecuteStream（NoTimeout），命令策略与 secure_exec 相同；
+//     进程启动后才返回，策略拒绝或命令不存在等错误同步返回给调用方。
+//  2. 每个任务的输出写入独立的执行日志（见 exec_log.go），通过 Output 按 offset/limit 读取；
+//     任务记录保留期间日志不会被执行日志的数量与大小上限清理。
+//  3. 同时运行的任务数受 max_jobs 限制；已结束的任务保留最近 maxFinishedJobs 个，更早的连同日志一起删除。
+//  4. 每个任务记录启动它的客户端会话与工作区（JobScope），Status / List / Output / Wait / Kill
+//     只能看到和操作同一客户端、同一工作区的任务；会话结束时 RemoveClient 终止并删除它的任务。
+//  5. Shutdown 终止所有运行中的任务并删除全部任务日志（服务退出时调用）。
+
+const maxFinishedJobs = 50
+
+// 任务状态
+const (
+	JobRunning = "running"
+	JobExited  = "exited" // 进程已退出（exit_code 可能非 0）
+	JobFailed  = "failed" // 超时或执行失败
+	JobKilled  = "killed" // 被 job_kill 或服务退出终止
+)
+
+// ErrJobNotFound 任务不存在（或已被清理，或属于其他客户端、其他工作区）
+var ErrJobNotFound = errors.New("job not found")
+
+// JobScope 任务的归属：启动任务的客户端会话 ID（stdio 为空）与工作区名称
+type JobScope struct {
+	Client    string
+	Workspace string
+}
+
+// JobInfo 任务状态快照
+type JobInfo struct {
+	ID        string     `json:"id"`
+	Workspace string     `json:"workspace,omitempty"`
+	Command   string     `json:"command"`
+	Args      []string   `json:"args"`
+	State     string     `json:"state"`
+	PID       int        `json:"pid"`
+	ExitCode  *int       `json:"exit_code,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	LogID     string     `json:"log_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+type job struct {
+	info   JobInfo
+	client string
+	cancel context.CancelFunc
+	killed bool
+	done   chan struct{}
+}
+
+// JobManager 管理后台任务，并发安全
+type JobManager struct {
+	mu    sync.Mutex
+	limit int
+	jobs  map[string]*job
+}
+
+// NewJobManager 创建任务管理器，limit <= 0 时使用默认上限
+func NewJobManager(limit int) *JobManager {
+	m := &JobManager{jobs: make(map[string]*job)}
+	m.SetLimit(limit)
+	return m
+}
+
+// SetLimit 更新同时运行的任务上限（配置热重载时调用，不影响已在运行的任务）
+func (m *JobManager) SetLimit(limit int) {
+	if limit <= 0 {
+		limit = config.DefaultMaxJobs
+	}
+	m.mu.Lock()
+	m.limit = limit
+	m.mu.Unlock()
+}
+
+// Start 在工作区 ws（名称为 scope.Workspace）中启动后台任务，进程启动后返回任务信息
+func (m *JobManager) Start(ws Workspace, scope JobScope, cmd string, args []string) (JobInfo, error) {
+	m.mu.Lock()
+	if running := m.countRunningLocked(); running >= m.limit {
+		m.mu.Unlock()
+		return JobInfo{}, fmt.Errorf("too many running jobs (%d/%d), wait for or kill one first", running, m.limit)
+	}
+	id, err := newJobID()
+	if err != nil {
+		m.mu.Unlock()
+		return JobInfo{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		info: JobInfo{
+			ID:        id,
+			Workspace: scope.Workspace,
+			Command:   cmd,
+			Args:      append([]string{}, args...),
+			State:     JobRunning,
+			StartedAt: time.Now(),
+		},
+		client: scope.Client,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if j.info.Args == nil {
+		j.info.Args = []string{}
+	}
+	// 先占位，保证并发 Start 不会超过上限
+	m.jobs[id] = j
+	m.mu.Unlock()
+
+	started := make(chan struct{})
+	go func() {
+		res, err := ws.ExecuteStream(ctx, cmd, args, ExecOptions{
+			NoTimeout: true,
+			OnStart: func(pid int, logID string) {
+				if logID != "" {
+					retainExecLog(logID)
+				}
+				m.mu.Lock()
+				j.info.PID = pid
+				j.info.LogID = logID
+				m.mu.Unlock()
+				close(started)
+			},
+		})
+		m.finish(j, res, err)
+	}()
+
+	select {
+	case <-started:
+		return m.snapshot(j), nil
+	case <-j.done:
+		// 未能启动（策略拒绝、命令不存在等）：不保留任务记录
+		info := m.snapshot(j)
+		m.mu.Lock()
+		delete(m.jobs, id)
+		m.mu.Unlock()
+		if info.LogID != "" {
+			_ = RemoveExecLog(info.LogID)
+		}
+		return JobInfo{}, errors.New(info.Error)
+	}
+}
+
+// finish 记录任务结束状态并清理过多的已结束任务
+func (m *JobManager) finish(j *job, res *ExecResult, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	j.info.EndedAt = &now
+	if res != nil && res.LogID != "" {
+		j.info.LogID = res.LogID
+	}
+	switch {
+	case j.killed:
+		j.info.State = JobKilled
+	case res != nil && res.ExitCode >= 0:
+		j.info.State = JobExited
+		code := res.ExitCode
+		j.info.ExitCode = &code
+	default:
+		j.info.State = JobFailed
+	}
+	if err != nil && j.info.State != JobKilled {
+		j.info.Error = err.Error()
+	}
+	j.cancel()
+	close(j.done)
+	m.pruneFinishedLocked()
+}
+
+// pruneFinishedLocked 只保留最近 maxFinishedJobs 个已结束任务
+func (m *JobManager) pruneFinishedLocked() {
+	var finished []*job
+	for _, j := range m.jobs {
+		if j.info.State != JobRunning {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].info.EndedAt.Before(*finished[b].info.EndedAt) })
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, j.info.ID)
+		if j.info.LogID != "" {
+			_ = RemoveExecLog(j.info.LogID)
+		}
+	}
+}
+
+// Status 返回单个任务的状态
+func (m *JobManager) Status(scope JobScope, id string) (JobInfo, error) {
+	j, err := m.get(scope, id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	return m.snapshot(j), nil
+}
+
+// List 返回属于 scope 的所有任务（按启动时间排序）
+func (m *JobManager) List(scope JobScope) []JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]JobInfo, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if j.inScope(scope) {
+			result = append(result, j.info)
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].StartedAt.Before(result[b].StartedAt) })
+	return result
+}
+
+// Output 按 offset/limit 读取任务输出（任务运行期间也可读取）
+func (m *JobManager) Output(scope JobScope, id string, offset, limit int64) (*ExecLogChunk, JobInfo, error) {
+	info, err := m.Status(scope, id)
+	if err != nil {
+		return nil, JobInfo{}, err
+	}
+	if info.LogID == "" {
+		return nil, info, fmt.Errorf("job %s has no output log", id)
+	}
+	chunk, err := ReadExecLog(info.LogID, offset, limit)
+	return chunk, info, err
+}
+
+// Wait 等待任务结束，超时或 ctx 取消时返回当前状态
+func (m *JobManager) Wait(ctx context.Context, scope JobScope, id string, timeout time.Duration) (JobInfo, error) {
+	j, err := m.get(scope, id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-j.done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return m.snapshot(j), nil
+}
+
+// Kill 终止任务并等待其退出（最多 grace）
+func (m *JobManager) Kill(scope JobScope, id string, grace time.Duration) (JobInfo, error) {
+	j, err := m.get(scope, id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	m.mu.Lock()
+	if j.info.State == JobRunning {
+		j.killed = true
+		j.cancel()
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-j.done:
+	case <-time.After(grace):
+	}
+	return m.snapshot(j), nil
+}
+
+// Len 返回所有客户端的任务总数（含已结束的任务）
+func (m *JobManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.jobs)
+}
+
+// RemoveClient 终止客户端 client 的运行中任务并删除它的全部任务及日志（会话结束时调用）
+func (m *JobManager) RemoveClient(client string, grace time.Duration) {
+	m.remove(func(j *job) bool { return j.client == client }, grace)
+}
+
+// Shutdown 终止所有运行中的任务并删除全部任务及其日志
+func (m *JobManager) Shutdown(grace time.Duration) {
+	m.remove(func(*job) bool { return true }, grace)
+}
+
+// remove 终止 match 选中的运行中任务，等待其退出（最多 grace）后删除这些任务及其日志
+func (m *JobManager) remove(match func(*job) bool, grace time.Duration) {
+	m.mu.Lock()
+	var jobs []*job
+	for _, j := range m.jobs {
+		if !match(j) {
+			continue
+		}
+		if j.info.State == JobRunning {
+			j.killed = true
+			j.cancel()
+		}
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+
+	deadline := time.After(grace)
+	for _, j := range jobs {
+		select {
+		case <-j.done:
+		case <-deadline:
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range jobs {
+		delete(m.jobs, j.info.ID)
+		if j.info.LogID != "" {
+			_ = RemoveExecLog(j.info.LogID)
+		}
+	}
+}
+
+// get 返回属于 scope 的任务；其他客户端或工作区的任务与不存在的任务一样返回 ErrJobNotFound
+func (m *JobManager) get(scope JobScope, id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || !j.inScope(scope) {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return j, nil
+}
+
+// inScope 判断任务是否属于 scope，调用方需持有 m.mu
+func (j *job) inScope(scope JobScope) bool {
+	return j.client == scope.Client && j.info.Workspace == scope.Workspace
+}
+
+func (m *JobManager) snapshot(j *job) JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return j.info
+}
+
+func (m *JobManager) countRunningLocked() int {
+	n := 0
+	for _, j := range m.jobs {
+		if j.info.State == JobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+func newJobID() (string, error) {
+	var raw [4]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return "job-" + hex.EncodeToString(raw[:]), nil
+}
@@ -0,0 +1,176 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestJobManager(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		BuildTimeout:         1, // 后台任务不受 BuildTimeout 限制
+		AllowedBuildCommands: []string{"sh"},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+	m := NewJobManager(2)
+	defer m.Shutdown(time.Second)
+
+	// 策略拒绝同步返回，不留任务记录
+	if _, err := m.Start(ws, JobScope{}, "rm", []string{"-rf", "."}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected policy error, got %v", err)
+	}
+	if len(m.List(JobScope{})) != 0 {
+		t.Errorf("rejected job should not be listed: %+v", m.List(JobScope{}))
+	}
+
+	server, err := m.Start(ws, JobScope{}, "sh", []string{"-c", "echo ready; sleep 30"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if server.State != JobRunning || server.PID == 0 || server.LogID == "" {
+		t.Errorf("unexpected job info: %+v", server)
+	}
+
+	// 运行期间可以读取输出，且超过 BuildTimeout 仍在运行
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		chunk, info, err := m.Output(JobScope{}, server.ID, 0, 1024)
+		if err != nil {
+			t.Fatalf("Output failed: %v", err)
+		}
+		if chunk.Content == "ready\n" && info.State == JobRunning && time.Since(server.StartedAt) > 1100*time.Millisecond {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected output %q state %s", chunk.Content, info.State)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	quick, err := m.Start(ws, JobScope{}, "sh", []string{"-c", "exit 3"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 达到并发上限
+	if info, _ := m.Status(JobScope{}, quick.ID); info.State == JobRunning {
+		if _, err := m.Start(ws, JobScope{}, "sh", []string{"-c", "true"}); err == nil {
+			t.Error("expected concurrency limit error")
+		}
+	}
+
+	info, err := m.Wait(context.Background(), JobScope{}, quick.ID, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if info.State != JobExited || info.ExitCode == nil || *info.ExitCode != 3 {
+		t.Errorf("unexpected finished job: %+v", info)
+	}
+
+	info, err = m.Kill(JobScope{}, server.ID, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if info.State != JobKilled || info.EndedAt == nil {
+		t.Errorf("unexpected killed job: %+v", info)
+	}
+
+	// Shutdown 删除任务及其日志
+	m.Shutdown(time.Second)
+	if _, err := m.Status(JobScope{}, server.ID); err == nil {
+		t.Error("expected job to be removed after shutdown")
+	}
+	if _, err := ReadExecLog(server.LogID, 0, 10); err == nil {
+		t.Error("expected job log to be removed after shutdown")
+	}
+}
+
+func TestJobManager_LogSurvivesPrune(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: t.TempDir(), BuildTimeout: 5, AllowedBuildCommands: []string{"sh"}})
+	m := NewJobManager(2)
+	defer m.Shutdown(time.Second)
+
+	job, err := m.Start(ws, JobScope{}, "sh", []string{"-c", "echo done"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if info, _ := m.Wait(context.Background(), JobScope{}, job.ID, 5*time.Second); info.State != JobExited {
+		t.Fatalf("unexpected job state: %+v", info)
+	}
+
+	// 其他命令触发的日志清理不会删除仍在任务列表中的日志
+	dir, _ := execLogDir()
+	pruneExecLogs(dir, 0, 0)
+	chunk, _, err := m.Output(JobScope{}, job.ID, 0, 1024)
+	if err != nil || chunk.Content != "done\n" {
+		t.Fatalf("job output after prune = %+v, %v", chunk, err)
+	}
+
+	// 任务记录删除后日志不再保留
+	m.Shutdown(time.Second)
+	if _, retained := retainedLogs.Load(filepath.Join(dir, job.LogID+".log")); retained {
+		t.Error("log still retained after shutdown")
+	}
+}
+
+func TestJobManager_Scope(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: t.TempDir(), BuildTimeout: 5, AllowedBuildCommands: []string{"sh"}})
+	m := NewJobManager(4)
+	defer m.Shutdown(time.Second)
+
+	owner := JobScope{Client: "a", Workspace: "main"}
+	job, err := m.Start(ws, owner, "sh", []string{"-c", "sleep 30"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 其他客户端或其他工作区看不到、也操作不了该任务
+	for _, scope := range []JobScope{{Client: "b", Workspace: "main"}, {Client: "a", Workspace: "other"}} {
+		if list := m.List(scope); len(list) != 0 {
+			t.Errorf("%+v lists foreign jobs: %+v", scope, list)
+		}
+		if _, err := m.Status(scope, job.ID); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("%+v Status: %v", scope, err)
+		}
+		if _, _, err := m.Output(scope, job.ID, 0, 10); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("%+v Output: %v", scope, err)
+		}
+		if _, err := m.Wait(context.Background(), scope, job.ID, time.Millisecond); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("%+v Wait: %v", scope, err)
+		}
+		if _, err := m.Kill(scope, job.ID, time.Second); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("%+v Kill: %v", scope, err)
+		}
+	}
+	if list := m.List(owner); len(list) != 1 || list[0].State != JobRunning {
+		t.Errorf("owner List = %+v", list)
+	}
+
+	// 客户端结束后其任务被终止并删除
+	m.RemoveClient("a", 5*time.Second)
+	if m.Len() != 0 {
+		t.Errorf("jobs left after RemoveClient: %d", m.Len())
+	}
+	if _, err := ReadExecLog(job.LogID, 0, 10); err == nil {
+		t.Error("job log kept after RemoveClient")
+	}
+}
@@ -0,0 +1,621 @@
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现编辑日志（workspace.history / undo / redo 使用）：
+//  1. write_file、search_and_replace、apply_unified_diff、replace_lines、insert_lines 的写入都经 commitEdit 提交：提交前把修改前后的内容
+//     按 sha256 存入日志目录的 objects/（相同内容只存一份），提交成功后追加一条 EditEntry。
+//     make_dir、move、copy、delete 经 commitTreeEdit 提交，条目中另外记录创建与删除的目录。
+//  2. 日志位于工作区之外（history.dir/<根目录哈希>/），journal.json 保存全部条目，每次变更以临时文件 + rename 替换。
+//  3. undo 把条目涉及的文件恢复为修改前的内容，redo 恢复为修改后的内容；执行前校验每个文件的当前哈希，
+//     之后又被改过的文件报冲突而不覆盖。一个条目内的文件经 commitFiles 全部成功或全部不变。
+//  4. 条目数超过 max_entries 或快照总量超过 max_mb 时删除最旧的条目（至少保留最新一条），并删除不再引用的快照。
+
+const journalFile = "journal.json"
+
+// 编辑来源
+const (
+	EditWriteFile        = "write_file"
+	EditSearchAndReplace = "search_and_replace"
+	EditApplyDiff        = "apply_unified_diff"
+	EditReplaceLines     = "replace_lines"
+	EditInsertLines      = "insert_lines"
+	EditMakeDir          = "make_dir"
+	EditMove             = "move"
+	EditCopy             = "copy"
+	EditDelete           = "delete"
+)
+
+// 目录变化
+const (
+	DirCreated = "created"
+	DirRemoved = "removed"
+)
+
+// EditFile 一次编辑中单个文件的变化；哈希为空表示文件不存在
+type EditFile struct {
+	Path       string `json:"path"` // 工作区相对路径
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+	BeforeMode uint32 `json:"before_mode,omitempty"`
+	AfterMode  uint32 `json:"after_mode,omitempty"`
+}
+
+// EditDir 一次编辑中创建或删除的目录
+type EditDir struct {
+	Path   string `json:"path"`   // 工作区相对路径
+	Action string `json:"action"` // created / removed
+}
+
+// EditEntry 编辑日志中的一条记录
+type EditEntry struct {
+	ID      int64      `json:"id"`
+	Time    time.Time  `json:"time"`
+	Tool    string     `json:"tool"`
+	Files   []EditFile `json:"files"`
+	Dirs    []EditDir  `json:"dirs,omitempty"`
+	Undone  bool       `json:"undone"`
+	UndoSeq int64      `json:"undo_seq,omitempty"` // 撤销顺序，redo 先恢复最近撤销的条目
+}
+
+// HistoryReport History 的结果
+type HistoryReport struct {
+	Entries       []EditEntry `json:"entries"` // 最新的在前
+	Total         int         `json:"total"`
+	SnapshotBytes int64       `json:"snapshot_bytes"`
+	MaxEntries    int         `json:"max_entries"`
+	MaxBytes      int64       `json:"max_bytes"`
+}
+
+// editJournal 一个工作区的编辑日志（同一目录在进程内共享一个实例）
+type editJournal struct {
+	mu      sync.Mutex
+	dir     string
+	loaded  bool
+	pending map[string]int // 已保存快照但尚未提交的编辑引用的快照，清理时保留
+	journalState
+}
+
+// journalState journal.json 的内容
+type journalState struct {
+	Root    string      `json:"root"`
+	NextID  int64       `json:"next_id"`
+	UndoSeq int64       `json:"undo_seq"`
+	Entries []EditEntry `json:"entries"` // 最旧的在前
+}
+
+var (
+	journalsMu sync.Mutex
+	journals   = make(map[string]*editJournal)
+)
+
+// openJournal 返回工作区的编辑日志；未启用时返回 nil
+func openJournal(cfg *config.Config, root string) (*editJournal, error) {
+	h := cfg.History
+	if h.Disabled || h.Dir == "" {
+		return nil, nil
+	}
+	base, err := filepath.Abs(h.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if isWithin(root, base) {
+		if h.Dir == config.DefaultStateDir("history") {
+			// 以 $HOME 等为根目录时默认目录落在工作区内：不记录编辑历史，写入照常进行
+			return nil, errHistoryInRoot
+		}
+		return nil, fmt.Errorf("history dir %s must be outside the workspace root %s", base, root)
+	}
+	dir := filepath.Join(base, workspaceKey(root))
+
+	journalsMu.Lock()
+	defer journalsMu.Unlock()
+	j, ok := journals[dir]
+	if !ok {
+		j = &editJournal{dir: dir, pending: make(map[string]int), journalState: journalState{Root: root}}
+		journals[dir] = j
+	}
+	return j, nil
+}
+
+// workspaceKey 返回工作区根目录在日志目录、回收站中使用的子目录名
+func workspaceKey(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return hex.EncodeToString(sum[:8])
+}
+
+// isWithin 判断 path 是否为 root 或位于 root 之下
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// journal 返回工作区的编辑日志；未启用时返回 errHistoryDisabled 说明原因
+func (w *OSWorkspace) journal() (*editJournal, error) {
+	j, err := openJournal(w.cfg, w.root)
+	if err != nil {
+		return nil, fmt.Errorf("edit history unavailable: %w", err)
+	}
+	if j == nil {
+		return nil, errHistoryDisabled
+	}
+	return j, nil
+}
+
+var errHistoryDisabled = errors.New("edit history is disabled (history.disabled or empty history.dir)")
+
+var errHistoryInRoot = errors.New("edit history is disabled: the default history dir is inside the workspace root (set history.dir to a directory outside the workspace)")
+
+// historyWarning 编辑日志因默认目录位于工作区内而未启用时返回原因，否则为空
+func (w *OSWorkspace) historyWarning() string {
+	if _, err := openJournal(w.cfg, w.root); errors.Is(err, errHistoryInRoot) {
+		return err.Error()
+	}
+	return ""
+}
+
+// load 首次使用时从磁盘读取日志（调用方持有 mu）
+func (j *editJournal) load() error {
+	if j.loaded {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(j.dir, "objects"), 0700); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(j.dir, journalFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &j.journalState); err != nil {
+			return fmt.Errorf("corrupt %s: %w", filepath.Join(j.dir, journalFile), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	j.loaded = true
+	return nil
+}
+
+// save 原子地写回 journal.json（调用方持有 mu）
+func (j *editJournal) save() error {
+	data, err := json.MarshalIndent(j.journalState, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(j.dir, journalFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(j.dir, journalFile)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (j *editJournal) objectPath(hash string) string {
+	return filepath.Join(j.dir, "objects", hash)
+}
+
+// storeObject 保存一份快照并返回其哈希（已存在时不重复写入）
+func (j *editJournal) storeObject(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := j.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return hash, nil
+}
+
+// readObject 读取快照并校验哈希
+func (j *editJournal) readObject(hash string) ([]byte, error) {
+	data, err := os.ReadFile(j.objectPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot %s is missing from the history", shortHash(hash))
+		}
+		return nil, err
+	}
+	if contentHash(data, true) != hash {
+		return nil, fmt.Errorf("snapshot %s is corrupt", shortHash(hash))
+	}
+	return data, nil
+}
+
+// contentHash 返回内容的 sha256；文件不存在时为空
+func contentHash(data []byte, exists bool) string {
+	if !exists {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func shortHash(hash string) string {
+	if hash == "" {
+		return "(none)"
+	}
+	return hash[:min(12, len(hash))]
+}
+
+// prepare 保存修改前后的快照并生成条目（尚未加入日志）
+func (j *editJournal) prepare(tool string, paths []string, changes []*fileChange, dirs []dirChange) (*EditEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	entry := &EditEntry{Time: time.Now().UTC(), Tool: tool}
+	for i, c := range changes {
+		f := EditFile{Path: paths[i]}
+		if c.exists {
+			hash, err := j.storeObject(c.original)
+			if err != nil {
+				return nil, err
+			}
+			f.Before, f.BeforeMode = hash, uint32(c.origMode)
+		}
+		if c.present() {
+			after := c.data
+			if after == nil {
+				after = c.original
+			}
+			hash, err := j.storeObject(after)
+			if err != nil {
+				return nil, err
+			}
+			f.After, f.AfterMode = hash, uint32(c.mode)
+		}
+		entry.Files = append(entry.Files, f)
+	}
+	for _, d := range dirs {
+		action := DirRemoved
+		if d.create {
+			action = DirCreated
+		}
+		entry.Dirs = append(entry.Dirs, EditDir{Path: d.path, Action: action})
+	}
+	j.hold(entry, 1)
+	return entry, nil
+}
+
+// hold 调整待提交编辑对快照的引用（调用方持有 mu）
+func (j *editJournal) hold(entry *EditEntry, delta int) {
+	for _, f := range entry.Files {
+		for _, hash := range []string{f.Before, f.After} {
+			if hash == "" {
+				continue
+			}
+			if j.pending[hash] += delta; j.pending[hash] <= 0 {
+				delete(j.pending, hash)
+			}
+		}
+	}
+}
+
+// discard 放弃未提交的编辑
+func (j *editJournal) discard(entry *EditEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.hold(entry, -1)
+}
+
+// record 把已提交的编辑加入日志并按保留限制清理
+func (j *editJournal) record(entry *EditEntry, limits config.HistoryConfig) (int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.hold(entry, -1)
+	j.NextID++
+	entry.ID = j.NextID
+	j.Entries = append(j.Entries, *entry)
+	j.prune(limits)
+	return entry.ID, j.save()
+}
+
+// prune 删除超出保留限制的最旧条目以及不再引用的快照（调用方持有 mu）
+func (j *editJournal) prune(limits config.HistoryConfig) {
+	if n := len(j.Entries) - limits.MaxEntries; n > 0 {
+		j.Entries = append([]EditEntry{}, j.Entries[n:]...)
+	}
+	refs, sizes := j.objectRefs()
+	var total int64
+	for _, s := range sizes {
+		total += s
+	}
+	for total > limits.MaxMB<<20 && len(j.Entries) > 1 {
+		for _, f := range j.Entries[0].Files {
+			for _, hash := range []string{f.Before, f.After} {
+				if hash == "" {
+					continue
+				}
+				if refs[hash]--; refs[hash] == 0 {
+					total -= sizes[hash]
+				}
+			}
+		}
+		j.Entries = j.Entries[1:]
+	}
+
+	entries, err := os.ReadDir(filepath.Join(j.dir, "objects"))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if refs[e.Name()] == 0 && j.pending[e.Name()] == 0 {
+			os.Remove(j.objectPath(e.Name()))
+		}
+	}
+}
+
+// objectRefs 统计各快照被引用的次数与大小（调用方持有 mu）
+func (j *editJournal) objectRefs() (refs map[string]int, sizes map[string]int64) {
+	refs, sizes = make(map[string]int), make(map[string]int64)
+	for _, e := range j.Entries {
+		for _, f := range e.Files {
+			for _, hash := range []string{f.Before, f.After} {
+				if hash == "" {
+					continue
+				}
+				if refs[hash]++; refs[hash] == 1 {
+					if info, err := os.Stat(j.objectPath(hash)); err == nil {
+						sizes[hash] = info.Size()
+					}
+				}
+			}
+		}
+	}
+	return refs, sizes
+}
+
+// commitEdit 提交文件修改并记入编辑日志，返回编辑 ID（未启用日志时为 0）
+// 快照保存失败时不写入任何文件；文件已提交但条目写入失败时返回错误说明修改已生效
+func (w *OSWorkspace) commitEdit(tool string, paths []string, changes []*fileChange) (int64, error) {
+	return w.commitTreeEdit(tool, paths, changes, nil)
+}
+
+// commitTreeEdit 同 commitEdit，同时创建与删除目录（见 commitTree）
+func (w *OSWorkspace) commitTreeEdit(tool string, paths []string, changes []*fileChange, dirs []dirChange) (int64, error) {
+	if len(changes) == 0 && len(dirs) == 0 {
+		return 0, nil
+	}
+	j, err := w.journal()
+	if errors.Is(err, errHistoryDisabled) || errors.Is(err, errHistoryInRoot) {
+		return 0, commitTree(dirs, changes)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w (set history.dir to a directory outside the workspace, or history.disabled)", err)
+	}
+	entry, err := j.prepare(tool, paths, changes, dirs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record edit history (no files were changed): %w", err)
+	}
+	if err := commitTree(dirs, changes); err != nil {
+		j.discard(entry)
+		return 0, err
+	}
+	id, err := j.record(entry, w.cfg.HistoryLimits())
+	if err != nil {
+		return id, fmt.Errorf("edit applied but not saved to history: %w", err)
+	}
+	return id, nil
+}
+
+// History 列出最近的编辑（最新的在前），limit <= 0 时返回全部
+func (w *OSWorkspace) History(ctx context.Context, limit int) (*HistoryReport, error) {
+	j, err := w.journal()
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	limits := w.cfg.HistoryLimits()
+	report := &HistoryReport{
+		Entries:    []EditEntry{},
+		Total:      len(j.Entries),
+		MaxEntries: limits.MaxEntries,
+		MaxBytes:   limits.MaxMB << 20,
+	}
+	_, sizes := j.objectRefs()
+	for _, s := range sizes {
+		report.SnapshotBytes += s
+	}
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(report.Entries) >= limit {
+			break
+		}
+		report.Entries = append(report.Entries, j.Entries[i])
+	}
+	return report, nil
+}
+
+// Undo 撤销编辑：id > 0 时撤销该条目，否则按从新到旧撤销 steps 条（至少 1 条）尚未撤销的编辑
+// 返回已撤销的条目；中途失败时已撤销的条目保持撤销状态
+func (w *OSWorkspace) Undo(ctx context.Context, steps int, id int64) ([]EditEntry, error) {
+	return w.revert(ctx, steps, id, true)
+}
+
+// Redo 重做被撤销的编辑：id > 0 时重做该条目，否则按撤销的逆序重做 steps 条（至少 1 条）
+func (w *OSWorkspace) Redo(ctx context.Context, steps int, id int64) ([]EditEntry, error) {
+	return w.revert(ctx, steps, id, false)
+}
+
+func (w *OSWorkspace) revert(ctx context.Context, steps int, id int64, undo bool) ([]EditEntry, error) {
+	j, err := w.journal()
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	verb := "undo"
+	if !undo {
+		verb = "redo"
+	}
+
+	var targets []int
+	if id > 0 {
+		i := j.find(id)
+		switch {
+		case i < 0:
+			return nil, fmt.Errorf("edit %d not found in history (it may have been pruned)", id)
+		case undo && j.Entries[i].Undone:
+			return nil, fmt.Errorf("edit %d is already undone", id)
+		case !undo && !j.Entries[i].Undone:
+			return nil, fmt.Errorf("edit %d is not undone", id)
+		}
+		targets = []int{i}
+	} else {
+		targets = j.candidates(max(steps, 1), undo)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("nothing to %s", verb)
+		}
+	}
+
+	done := []EditEntry{}
+	for _, i := range targets {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		if err := w.applyEntry(j, &j.Entries[i], undo); err != nil {
+			if saveErr := j.save(); saveErr != nil {
+				err = errors.Join(err, saveErr)
+			}
+			return done, fmt.Errorf("cannot %s edit %d: %w", verb, j.Entries[i].ID, err)
+		}
+		if undo {
+			j.UndoSeq++
+			j.Entries[i].Undone, j.Entries[i].UndoSeq = true, j.UndoSeq
+		} else {
+			j.Entries[i].Undone, j.Entries[i].UndoSeq = false, 0
+		}
+		done = append(done, j.Entries[i])
+	}
+	return done, j.save()
+}
+
+// find 返回条目下标，不存在时为 -1（调用方持有 mu）
+func (j *editJournal) find(id int64) int {
+	for i, e := range j.Entries {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// candidates 返回下一步 undo（最新的未撤销条目）或 redo（最近撤销的条目）的下标，最多 n 个
+func (j *editJournal) candidates(n int, undo bool) []int {
+	var out []int
+	if undo {
+		for i := len(j.Entries) - 1; i >= 0 && len(out) < n; i-- {
+			if !j.Entries[i].Undone {
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+	taken := make(map[int]bool)
+	for len(out) < n {
+		best := -1
+		for i, e := range j.Entries {
+			if e.Undone && !taken[i] && (best < 0 || e.UndoSeq > j.Entries[best].UndoSeq) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		taken[best] = true
+		out = append(out, best)
+	}
+	return out
+}
+
+// applyEntry 把条目涉及的文件与目录恢复为修改前（undo）或修改后（redo）的状态
+// 每个文件的当前内容必须与另一侧的哈希一致，否则报冲突且不修改任何文件
+func (w *OSWorkspace) applyEntry(j *editJournal, e *EditEntry, undo bool) error {
+	changes := make([]*fileChange, 0, len(e.Files))
+	for _, f := range e.Files {
+		expect, target, mode := f.After, f.Before, f.BeforeMode
+		if !undo {
+			expect, target, mode = f.Before, f.After, f.AfterMode
+		}
+		absPath, err := w.sanitizePath(f.Path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if w.isBlockedExtension(absPath) {
+			return fmt.Errorf("extension blocked for file %q", f.Path)
+		}
+		c, err := newFileChange(absPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if current := contentHash(c.original, c.exists); current != expect {
+			return fmt.Errorf("conflict: %s has changed since the edit (expected %s, found %s)", f.Path, shortHash(expect), shortHash(current))
+		}
+		if target == "" {
+			c.remove = true
+		} else {
+			if c.data, err = j.readObject(target); err != nil {
+				return fmt.Errorf("%s: %w", f.Path, err)
+			}
+			c.mode = os.FileMode(mode).Perm()
+		}
+		changes = append(changes, c)
+	}
+	// undo 时重建编辑删除的目录、删除编辑创建的目录，redo 时相反
+	dirs := make([]dirChange, 0, len(e.Dirs))
+	for _, d := range e.Dirs {
+		absPath, err := w.sanitizePath(d.Path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.Path, err)
+		}
+		dirs = append(dirs, dirChange{absPath: absPath, path: d.Path, create: (d.Action == DirCreated) != undo})
+	}
+	return commitTree(dirs, changes)
+}
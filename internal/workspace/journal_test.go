@@ -0,0 +1,175 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func newJournalWorkspace(t *testing.T, history config.HistoryConfig) (*OSWorkspace, string) {
+	t.Helper()
+	root := t.TempDir()
+	if history.Dir == "" {
+		history.Dir = t.TempDir()
+	}
+	ws, err := NewOSWorkspace(&config.Config{RootDir: root, History: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws.(*OSWorkspace), root
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestOSWorkspace_UndoRedo(t *testing.T) {
+	ws, root := newJournalWorkspace(t, config.HistoryConfig{})
+	ctx := context.Background()
+	a := filepath.Join(root, "a.txt")
+
+	if err := ws.WriteFile(ctx, "a.txt", []byte("one\n"), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.SearchAndReplace(ctx, ReplaceOptions{Path: "a.txt", Edits: []ReplaceEdit{{Old: "one", New: "two"}}}); err != nil {
+		t.Fatal(err)
+	}
+	diff := "--- /dev/null\n+++ b/b.txt\n@@ -0,0 +1 @@\n+new\n"
+	res, err := ws.ApplyPatch(ctx, diff, PatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ws.History(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Entries[0].ID != res.EditID || report.Entries[0].Tool != EditApplyDiff {
+		t.Fatalf("unexpected history: %+v", report)
+	}
+	if f := report.Entries[1].Files[0]; f.Path != "a.txt" || f.Before == "" || f.After == "" || f.Before == f.After {
+		t.Errorf("unexpected search_and_replace entry: %+v", f)
+	}
+	if f := report.Entries[2].Files[0]; f.Before != "" {
+		t.Errorf("created file should have no before hash: %+v", f)
+	}
+
+	// 撤销两步：删除 b.txt，a.txt 恢复为 one
+	undone, err := ws.Undo(ctx, 2, 0)
+	if err != nil || len(undone) != 2 {
+		t.Fatalf("Undo: %v (%d entries)", err, len(undone))
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("b.txt should be removed by undo, stat err = %v", err)
+	}
+	if got := readString(t, a); got != "one\n" {
+		t.Errorf("a.txt after undo = %q", got)
+	}
+
+	// 重做最近撤销的一条（search_and_replace）
+	redone, err := ws.Redo(ctx, 1, 0)
+	if err != nil || len(redone) != 1 || redone[0].Tool != EditSearchAndReplace {
+		t.Fatalf("Redo: %v %+v", err, redone)
+	}
+	if got := readString(t, a); got != "two\n" {
+		t.Errorf("a.txt after redo = %q", got)
+	}
+
+	// 按 ID 撤销第一条编辑时 a.txt 已被后续编辑修改：报冲突且不改文件
+	first := report.Entries[2].ID
+	if _, err := ws.Undo(ctx, 0, first); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if got := readString(t, a); got != "two\n" {
+		t.Errorf("a.txt changed by failed undo: %q", got)
+	}
+
+	if _, err := ws.Redo(ctx, 0, first); err == nil || !strings.Contains(err.Error(), "not undone") {
+		t.Errorf("expected not undone error, got %v", err)
+	}
+	if _, err := ws.Redo(ctx, 1, 0); err != nil {
+		t.Fatalf("Redo b.txt: %v", err)
+	}
+	if _, err := ws.Redo(ctx, 1, 0); err == nil || !strings.Contains(err.Error(), "nothing to redo") {
+		t.Errorf("expected nothing to redo, got %v", err)
+	}
+}
+
+func TestOSWorkspace_HistoryRetention(t *testing.T) {
+	ws, _ := newJournalWorkspace(t, config.HistoryConfig{MaxEntries: 2})
+	ctx := context.Background()
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if err := ws.WriteFile(ctx, "f.txt", []byte(content), true, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := ws.History(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 2 || report.Entries[0].ID != 4 || report.Entries[1].ID != 3 {
+		t.Fatalf("unexpected entries after pruning: %+v", report.Entries)
+	}
+	// 只剩 v2、v3、v4 三份快照
+	j, _ := ws.journal()
+	objects, _ := os.ReadDir(filepath.Join(j.dir, "objects"))
+	if len(objects) != 3 {
+		t.Errorf("got %d snapshots, want 3", len(objects))
+	}
+	if _, err := ws.Undo(ctx, 0, 1); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected pruned edit error, got %v", err)
+	}
+}
+
+func TestOSWorkspace_HistoryDirInsideWorkspace(t *testing.T) {
+	root := t.TempDir()
+	ws, _ := NewOSWorkspace(&config.Config{RootDir: root, History: config.HistoryConfig{Dir: filepath.Join(root, ".history")}})
+	err := ws.WriteFile(context.Background(), "a.txt", []byte("a"), true, "")
+	if err == nil || !strings.Contains(err.Error(), "outside the workspace") {
+		t.Fatalf("expected history dir error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("file should not be written, stat err = %v", err)
+	}
+	if _, err := ws.History(context.Background(), 0); err == nil {
+		t.Error("expected History error")
+	}
+}
+
+func TestOSWorkspace_DefaultHistoryDirInsideHome(t *testing.T) {
+	// 以 $HOME 为根目录时默认日志目录落在工作区内：不记录编辑历史，写入照常进行
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+	cfg := &config.Config{RootDir: home, History: config.HistoryConfig{Dir: config.DefaultStateDir("history")}}
+	reg, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ws, _ := reg.Get("", "")
+	if err := ws.WriteFile(ctx, "a.txt", []byte("a"), true, ""); err != nil {
+		t.Fatalf("WriteFile failed under the default config: %v", err)
+	}
+	if got := readString(t, filepath.Join(home, "a.txt")); got != "a" {
+		t.Errorf("content = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".local", "state")); !os.IsNotExist(err) {
+		t.Errorf("history written inside the workspace: %v", err)
+	}
+	if _, err := ws.History(ctx, 0); err == nil || !strings.Contains(err.Error(), "inside the workspace root") {
+		t.Errorf("expected history disabled error, got %v", err)
+	}
+	if infos := reg.List(""); len(infos) != 1 || !strings.Contains(infos[0].Warning, "history.dir") {
+		t.Errorf("expected workspace warning, got %+v", infos)
+	}
+}
@@ -0,0 +1,166 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 本文件实现按行号编辑（replace_lines / insert_lines），行号与 read_code_fragment 一致（从 1 开始）：
+//  1. 新文本按行拆分，末尾的一个换行符可省略；文件使用 CRLF 时新行同样以 CRLF 结尾，文件末尾是否有换行保持不变。
+//  2. Expected 不为空时先校验原有行：ReplaceLines 校验被替换的行，InsertLines 校验插入点之前（以 afterLine 结尾）的行；
+//     比较时忽略行尾的 \r，不一致时返回第一处不同的行且不写入。
+//  3. 写入与 search_and_replace 相同：校验 ifMatch 后经 commitEdit 原子写入（tmp + rename）并记入编辑日志。
+
+// LineEditOptions ReplaceLines / InsertLines 的可选参数
+type LineEditOptions struct {
+	Expected string // 不为空时原有行必须与之逐行一致（单独一个空行写作 "\n"）
+	IfMatch  string // 语义同 WriteFile
+	DryRun   bool
+}
+
+// LineEditResult 按行编辑的结果；StartLine..EndLine 为编辑后新内容所在的行
+type LineEditResult struct {
+	Path         string `json:"path"`
+	StartLine    int    `json:"start_line"`
+	EndLine      int    `json:"end_line"` // 只删除了行时为 StartLine-1
+	LinesRemoved int    `json:"lines_removed"`
+	LinesAdded   int    `json:"lines_added"`
+	TotalLines   int    `json:"total_lines"` // 编辑后文件的总行数
+	Hash         string `json:"hash"`        // 编辑后（dry-run 时为将会写入的）内容的 sha256
+	EditID       int64  `json:"edit_id,omitempty"`
+	DryRun       bool   `json:"dry_run,omitempty"`
+}
+
+// ReplaceLines 把 startLine..endLine（包含）替换为 text；text 为空时删除这些行
+func (w *OSWorkspace) ReplaceLines(ctx context.Context, path string, startLine, endLine int, text string, opts LineEditOptions) (*LineEditResult, error) {
+	if startLine <= 0 || endLine < startLine {
+		return nil, fmt.Errorf("invalid line range: %d-%d", startLine, endLine)
+	}
+	return w.editLines(ctx, EditReplaceLines, path, startLine, endLine, text, opts)
+}
+
+// InsertLines 在第 afterLine 行之后插入 text；afterLine 为 0 时插入到文件开头
+func (w *OSWorkspace) InsertLines(ctx context.Context, path string, afterLine int, text string, opts LineEditOptions) (*LineEditResult, error) {
+	if afterLine < 0 {
+		return nil, fmt.Errorf("invalid line number: %d", afterLine)
+	}
+	if text == "" {
+		return nil, fmt.Errorf("text to insert cannot be empty")
+	}
+	return w.editLines(ctx, EditInsertLines, path, afterLine+1, afterLine, text, opts)
+}
+
+// editLines 把 start..end 行替换为 text；end == start-1 时为在 end 之后插入
+func (w *OSWorkspace) editLines(ctx context.Context, tool, path string, start, end int, text string, opts LineEditOptions) (*LineEditResult, error) {
+	// 安全检查
+	absPath, err := w.sanitizePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("path security check failed: %w", err)
+	}
+	if w.isBlockedExtension(absPath) {
+		return nil, fmt.Errorf("file extension is blocked")
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	// 读取文件内容并校验 ifMatch
+	c, err := newFileChange(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !c.exists {
+		return nil, fmt.Errorf("failed to read file: %s does not exist", path)
+	}
+	rel := w.relSlash(absPath)
+	if err := w.checkIfMatch(rel, c, opts.IfMatch); err != nil {
+		return nil, err
+	}
+
+	f := splitFileLines(c.original)
+	if end > len(f.lines) {
+		if start > end {
+			return nil, fmt.Errorf("cannot insert after line %d: %s has %d lines", end, path, len(f.lines))
+		}
+		return nil, fmt.Errorf("invalid line range %d-%d: %s has %d lines", start, end, path, len(f.lines))
+	}
+	if opts.Expected != "" {
+		want := splitTextLines(opts.Expected)
+		from := start
+		if start > end {
+			from = end - len(want) + 1
+		}
+		if err := checkExpectedLines(f.lines, from, end, want); err != nil {
+			return nil, err
+		}
+	}
+
+	// 新行沿用文件的换行风格
+	crlf := len(f.lines) > 0 && strings.HasSuffix(f.lines[0], "\r")
+	added := splitTextLines(text)
+	for i, l := range added {
+		l = strings.TrimSuffix(l, "\r")
+		if crlf {
+			l += "\r"
+		}
+		added[i] = l
+	}
+
+	lines := make([]string, 0, len(f.lines)-(end-start+1)+len(added))
+	lines = append(lines, f.lines[:start-1]...)
+	lines = append(lines, added...)
+	lines = append(lines, f.lines[end:]...)
+	c.data = fileLines{lines: lines, noEOL: f.noEOL}.bytes()
+
+	result := &LineEditResult{
+		Path:         rel,
+		StartLine:    start,
+		EndLine:      start + len(added) - 1,
+		LinesRemoved: end - start + 1,
+		LinesAdded:   len(added),
+		TotalLines:   len(lines),
+		DryRun:       opts.DryRun,
+	}
+	if opts.DryRun {
+		result.Hash = contentHash(c.data, true)
+		return result, nil
+	}
+
+	// 原子写入并记入编辑日志
+	if c.changed() {
+		if result.EditID, err = w.commitEdit(tool, []string{rel}, []*fileChange{c}); err != nil {
+			return nil, fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	result.Hash = w.rememberContent(c.data)
+	return result, nil
+}
+
+// splitTextLines 把工具传入的文本拆成行：末尾的一个换行符可省略，空文本为零行
+func splitTextLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// checkExpectedLines 校验 from..to 行与 want 一致（忽略行尾的 \r）
+func checkExpectedLines(lines []string, from, to int, want []string) error {
+	if from < 1 {
+		return fmt.Errorf("expected text has %d lines but only %d lines precede the insertion point; nothing was written", len(want), to)
+	}
+	if len(want) != to-from+1 {
+		return fmt.Errorf("expected text has %d lines but lines %d-%d are %d lines; nothing was written", len(want), from, to, to-from+1)
+	}
+	for i, l := range want {
+		got := strings.TrimSuffix(lines[from-1+i], "\r")
+		if got != strings.TrimSuffix(l, "\r") {
+			return fmt.Errorf("expected text does not match line %d: want %q, got %q; nothing was written, re-read the lines and retry", from+i, strings.TrimSuffix(l, "\r"), got)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_ReplaceLines(t *testing.T) {
+	ws, root := newJournalWorkspace(t, config.HistoryConfig{})
+	ctx := context.Background()
+	file := filepath.Join(root, "main.go")
+	os.WriteFile(file, []byte("package main\n\nfunc a() {\n\treturn\n}\n"), 0644)
+
+	// 两行替换为三行，返回新内容所在的行号
+	res, err := ws.ReplaceLines(ctx, "main.go", 3, 4, "func b() {\n\tx := 1\n\t_ = x\n", LineEditOptions{Expected: "func a() {\n\treturn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\nfunc b() {\n\tx := 1\n\t_ = x\n}\n"
+	if got := readString(t, file); got != want {
+		t.Fatalf("content = %q, want %q", got, want)
+	}
+	if res.StartLine != 3 || res.EndLine != 5 || res.LinesRemoved != 2 || res.LinesAdded != 3 || res.TotalLines != 6 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if res.Hash != ContentHash([]byte(want)) || res.EditID == 0 {
+		t.Errorf("unexpected hash or edit id: %+v", res)
+	}
+
+	// 原有行与 expected 不一致时不写入
+	if _, err := ws.ReplaceLines(ctx, "main.go", 4, 4, "\ty := 2", LineEditOptions{Expected: "\tx := 2"}); err == nil || !strings.Contains(err.Error(), "does not match line 4") {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+	if got := readString(t, file); got != want {
+		t.Errorf("file changed by failed edit: %q", got)
+	}
+
+	// 空文本删除行；dry-run 不写入
+	res, err = ws.ReplaceLines(ctx, "main.go", 4, 5, "", LineEditOptions{DryRun: true})
+	if err != nil || res.StartLine != 4 || res.EndLine != 3 || res.TotalLines != 4 {
+		t.Fatalf("dry-run delete: %+v, %v", res, err)
+	}
+	if got := readString(t, file); got != want {
+		t.Errorf("dry-run wrote the file: %q", got)
+	}
+
+	if _, err := ws.ReplaceLines(ctx, "main.go", 6, 7, "x", LineEditOptions{}); err == nil || !strings.Contains(err.Error(), "has 6 lines") {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+	var conflict *ConflictError
+	if _, err := ws.ReplaceLines(ctx, "main.go", 1, 1, "package x", LineEditOptions{IfMatch: ContentHash([]byte("old"))}); !errors.As(err, &conflict) {
+		t.Errorf("expected ConflictError, got %v", err)
+	}
+}
+
+func TestOSWorkspace_InsertLines(t *testing.T) {
+	ws, root := newJournalWorkspace(t, config.HistoryConfig{})
+	ctx := context.Background()
+	file := filepath.Join(root, "a.txt")
+	os.WriteFile(file, []byte("one\r\ntwo\r\nthree"), 0644)
+
+	res, err := ws.InsertLines(ctx, "a.txt", 0, "zero", LineEditOptions{})
+	if err != nil || res.StartLine != 1 || res.EndLine != 1 {
+		t.Fatalf("insert at top: %+v, %v", res, err)
+	}
+	// 文件使用 CRLF 且末尾没有换行：新行同样以 CRLF 结尾，末尾保持不变
+	res, err = ws.InsertLines(ctx, "a.txt", 2, "1.5\n1.75\n", LineEditOptions{Expected: "zero\none"})
+	if err != nil || res.StartLine != 3 || res.EndLine != 4 || res.TotalLines != 6 {
+		t.Fatalf("insert with expected: %+v, %v", res, err)
+	}
+	if got, want := readString(t, file), "zero\r\none\r\n1.5\r\n1.75\r\ntwo\r\nthree"; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+
+	if _, err := ws.InsertLines(ctx, "a.txt", 1, "x", LineEditOptions{Expected: "a\nb"}); err == nil || !strings.Contains(err.Error(), "precede the insertion point") {
+		t.Errorf("expected context error, got %v", err)
+	}
+	if _, err := ws.InsertLines(ctx, "a.txt", 7, "x", LineEditOptions{}); err == nil || !strings.Contains(err.Error(), "cannot insert after line 7") {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+
+	// 两次插入各记一条编辑日志，撤销后恢复原文件
+	if _, err := ws.Undo(ctx, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readString(t, file); got != "one\r\ntwo\r\nthree" {
+		t.Errorf("content after undo = %q", got)
+	}
+}
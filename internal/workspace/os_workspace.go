@@ -77,6 +77,21 @@ func NewOSWorkspace(cfg *config.Config) (Workspace, error) {
 
 // ReadFile 读取文件内容，支持最大字节限制和上下文取消
 func (w *OSWorkspace) ReadFile(ctx context.Context, path string, maxBytes int64) ([]byte, error) {
+	return w.readFile(ctx, path, maxBytes, nil)
+}
+
+// ReadFileHashed 读取文件内容，同时返回全文的 sha256（超过 maxBytes 的部分也计入哈希），可作为写入时的 ifMatch
+func (w *OSWorkspace) ReadFileHashed(ctx context.Context, path string, maxBytes int64) ([]byte, string, error) {
+	sink := w.newVersionSink()
+	data, err := w.readFile(ctx, path, maxBytes, sink)
+	if data == nil {
+		return nil, "", err
+	}
+	return data, sink.finish(), err
+}
+
+// readFile 读取文件内容；sink 不为 nil 时同时接收文件的全部字节
+func (w *OSWorkspace) readFile(ctx context.Context, path string, maxBytes int64, sink io.Writer) ([]byte, error) {
 	// 1. 路径安全与扩展名检查
 	absPath, err := w.sanitizePath(path)
 	if err != nil {
@@ -99,7 +114,11 @@ func (w *OSWorkspace) ReadFile(ctx context.Context, path string, maxBytes int64)
 	defer file.Close()
 	
 	// 4. 使用 LimitedReader 限制读取量
-	limited := io.LimitReader(file, maxBytes)
+	var src io.Reader = file
+	if sink != nil {
+		src = io.TeeReader(file, sink)
+	}
+	limited := io.LimitReader(src, maxBytes)
 	
 	// 5. 分块读取，定期检查上下文取消
 	const bufSize = 32 * 1024 // 32KB
@@ -133,7 +152,14 @@ func (w *OSWorkspace) ReadFile(ctx context.Context, path string, maxBytes int64)
 		}
 	}
 	
-	// 6. 获取文件真实大小，判断是否被截断
+	// 6. 超出限制的部分只计入 sink
+	if sink != nil {
+		if _, err := io.Copy(sink, file); err != nil {
+			return nil, fmt.Errorf("read error: %w", err)
+		}
+	}
+
+	// 7. 获取文件真实大小，判断是否被截断
 	fileInfo, err := file.Stat()
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file %q: %w", absPath, err)
@@ -147,7 +173,8 @@ func (w *OSWorkspace) ReadFile(ctx context.Context, path string, maxBytes int64)
 }
 
 // WriteFile 写入文件，原子操作，支持创建/覆盖
-func (w *OSWorkspace) WriteFile(ctx context.Context, path string, data []byte, allowCreate bool) error {
+// ifMatch 不为空时，文件当前内容的 sha256 必须与之相同，否则返回 *ConflictError
+func (w *OSWorkspace) WriteFile(ctx context.Context, path string, data []byte, allowCreate bool, ifMatch string) error {
 	// 1. 路径安全与扩展名检查
 	absPath, err := w.sanitizePath(path)
 	if err != nil {
@@ -165,125 +192,248 @@ func (w *OSWorkspace) WriteFile(ctx context.Context, path string, data []byte, a
 		return fmt.Errorf("failed to stat parent directory %q: %w", dir, err)
 	}
 	
-	// 3. 原子写入：临时文件 + rename
-	tmpPath := absPath + ".tmp"
-	tmpFile, err := os.Create(tmpPath)
-	if err != nil {
-		return fmt.Errorf("failed to create temp file %q: %w", tmpPath, err)
-	}
-	
-	// 检查上下文取消（在写入前）
+	// 3. 检查上下文取消（在写入前）
 	select {
 	case <-ctx.Done():
-		tmpFile.Close()
-		os.Remove(tmpPath)
 		return ctx.Err()
 	default:
 	}
-	
-	// 写入所有数据
-	_, err = tmpFile.Write(data)
+
+	// 4. 读取当前状态；如果 allowCreate=false，检查目标文件是否存在
+	c, err := newFileChange(absPath)
 	if err != nil {
-		tmpFile.Close()
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to write data to temp file: %w", err)
+		return fmt.Errorf("failed to check target file: %w", err)
 	}
-	
-	// 确保数据落盘
-	if err := tmpFile.Sync(); err != nil {
-		tmpFile.Close()
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to sync temp file: %w", err)
+	if !allowCreate && !c.exists {
+		return fmt.Errorf("file %q does not exist and allowCreate is false", absPath)
 	}
-	if err := tmpFile.Close(); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to close temp file: %w", err)
+	if err := w.checkIfMatch(w.relSlash(absPath), c, ifMatch); err != nil {
+		return err
 	}
-	
-	// 4. 如果 allowCreate=false，检查目标文件是否存在
-	if !allowCreate {
-		if _, err := os.Stat(absPath); os.IsNotExist(err) {
-			os.Remove(tmpPath)
-			return fmt.Errorf("file %q does not exist and allowCreate is false", absPath)
-		} else if err != nil {
-			os.Remove(tmpPath)
-			return fmt.Errorf("failed to check target file: %w", err)
+
+	// 5. 原子写入（临时文件 + rename）并记入编辑日志；内容未变化时不写入
+	c.data = data
+	if c.data == nil {
+		c.data = []byte{}
+	}
+	if c.changed() {
+		if _, err := w.commitEdit(EditWriteFile, []string{w.relSlash(absPath)}, []*fileChange{c}); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
 		}
 	}
-	
-	// 5. 原子替换
-	if err := os.Rename(tmpPath, absPath); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to rename temp file to target: %w", err)
-	}
-	
+	w.rememberContent(c.data)
 	return nil
 }
 
 // Execute 执行命令，支持超时和上下文取消，工作目录固定在 root
+// stdout/stderr 只保留最近 execRingBytes 字节，完整输出见 ExecuteStream 返回的日志
 func (w *OSWorkspace) Execute(ctx context.Context, cmd string, args []string, timeoutSeconds int64) (stdout string, stderr string, exitCode int, err error) {
-	// 1. 命令白名单校验
-	if !w.isAllowedCommand(cmd) {
-		return "", "", -1, fmt.Errorf("command not allowed: %q", cmd)
+	res, err := w.ExecuteStream(ctx, cmd, args, ExecOptions{TimeoutSeconds: timeoutSeconds})
+	return res.Stdout, res.Stderr, res.ExitCode, err
+}
+
+// ExecuteStream 执行命令，输出实时交给 opts.OnOutput，并写入环形缓冲区与磁盘日志
+func (w *OSWorkspace) ExecuteStream(ctx context.Context, cmd string, args []string, opts ExecOptions) (res *ExecResult, err error) {
+	res = &ExecResult{ExitCode: -1}
+
+	// 1. 解析工作目录，再做命令策略校验（白名单 / 参数级策略 / 按工作目录解析的路径参数）
+	dir, err := w.execDir(opts.Cwd)
+	if err != nil {
+		return res, err
 	}
-	
+	if err := w.checkCommand(dir, cmd, args); err != nil {
+		return res, err
+	}
+	// cmd 中带空格时（如 "go build"），拆出的部分作为参数
+	if fields := strings.Fields(cmd); len(fields) > 1 {
+		cmd, args = fields[0], append(fields[1:], args...)
+	}
+
 	// 2. 计算超时时间
-	timeout := timeoutSeconds
+	timeout := opts.TimeoutSeconds
 	if timeout <= 0 {
 		timeout = w.cfg.BuildTimeout
 	}
 	timeoutDuration := time.Duration(timeout) * time.Second
-	
-	// 3. 创建带超时的上下文
+
+	// 3. 创建带超时的上下文（后台任务可不设超时，由调用方取消）
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeoutDuration)
+	if opts.NoTimeout {
+		cancel()
+		ctxWithTimeout, cancel = context.WithCancel(ctx)
+	}
 	defer cancel()
-	
-	// 4. 创建命令对象，设置工作目录
+
+	// 4. 创建命令对象，设置工作目录、环境变量与标准输入
+	env, err := w.commandEnv(cmd, opts.Env)
+	if err != nil {
+		return res, err
+	}
+	stdin, err := w.execStdin(opts)
+	if err != nil {
+		return res, err
+	}
+	if c, ok := stdin.(io.Closer); ok {
+		defer c.Close()
+	}
 	execCmd := exec.CommandContext(ctxWithTimeout, cmd, args...)
-	execCmd.Dir = w.root
-	
-	// 5. 捕获 stdout/stderr
-	var stdoutBuf, stderrBuf strings.Builder
-	execCmd.Stdout = &stdoutBuf
-	execCmd.Stderr = &stderrBuf
-	
+	execCmd.Dir = dir
+	execCmd.Env = env
+	execCmd.Stdin = stdin
+	// 独立进程组，超时或取消时终止整棵进程树
+	setupProcess(execCmd)
+	// 进程被终止后，子进程可能仍持有输出管道，最多再等待 execWaitDelay 便关闭管道返回
+	execCmd.WaitDelay = execWaitDelay
+	// 配置了资源限制或沙箱时经由初始化进程执行（见 execinit_linux.go）
+	initProc, err := setupExecInit(execCmd, w.root, w.cfg.ExecLimits(), w.cfg.SandboxFor(cmd))
+	if err != nil {
+		return res, err
+	}
+	if initProc != nil {
+		defer initProc.Close()
+	}
+
+	// 5. 输出写入环形缓冲区、磁盘日志（创建失败时跳过）与回调
+	stdoutBuf, stderrBuf := newRingBuffer(execRingBytes), newRingBuffer(execRingBytes)
+	stdoutW, stderrW := []io.Writer{stdoutBuf}, []io.Writer{stderrBuf}
+	if logFile, logErr := newExecLog(w.execLogLimits()); logErr == nil {
+		defer func() {
+			res.LogBytes = logFile.Size()
+			logFile.Close()
+		}()
+		res.LogID = logFile.id
+		stdoutW, stderrW = append(stdoutW, logFile), append(stderrW, logFile)
+	}
+	if opts.OnOutput != nil {
+		stdoutW = append(stdoutW, outputFuncWriter{stream: "stdout", fn: opts.OnOutput})
+		stderrW = append(stderrW, outputFuncWriter{stream: "stderr", fn: opts.OnOutput})
+	}
+	execCmd.Stdout = io.MultiWriter(stdoutW...)
+	execCmd.Stderr = io.MultiWriter(stderrW...)
+
 	// 6. 执行
-	runErr := execCmd.Run()
-	
+	runErr := execCmd.Start()
+	if runErr != nil && initProc != nil {
+		runErr = initProc.startError(runErr)
+	}
+	if runErr == nil {
+		if initProc != nil {
+			if initErr := initProc.wait(); initErr != nil {
+				_ = execCmd.Wait()
+				return res, initErr
+			}
+		}
+		if opts.OnStart != nil {
+			opts.OnStart(execCmd.Process.Pid, res.LogID)
+		}
+		runErr = execCmd.Wait()
+	}
+
 	// 获取输出
-	stdout = stdoutBuf.String()
-	stderr = stderrBuf.String()
-	
+	res.Stdout = stdoutBuf.String()
+	res.Stderr = stderrBuf.String()
+	res.OutputDropped = stdoutBuf.Dropped() || stderrBuf.Dropped()
+
 	// 7. 根据错误类型处理
 	if runErr != nil {
 		// 超时
 		if ctxWithTimeout.Err() == context.DeadlineExceeded {
-			exitCode = -1
-			err = fmt.Errorf("timeout after %v: %w", timeoutDuration, runErr)
-			return
+			return res, fmt.Errorf("timeout after %v: %w", timeoutDuration, runErr)
 		}
-		
+
 		// 命令退出码非零
 		if exitErr, ok := runErr.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-			err = fmt.Errorf("exited with code %d: %w", exitCode, runErr)
-			return
+			res.ExitCode = exitErr.ExitCode()
+			return res, fmt.Errorf("exited with code %d: %w", res.ExitCode, runErr)
 		}
-		
+
 		// 其他错误（如命令不存在）
-		exitCode = -1
-		err = fmt.Errorf("execution failed: %w", runErr)
-		return
+		return res, fmt.Errorf("execution failed: %w", runErr)
 	}
-	
+
 	// 8. 成功
-	exitCode = execCmd.ProcessState.ExitCode()
-	err = nil
-	return
+	res.ExitCode = execCmd.ProcessState.ExitCode()
+	return res, nil
 }
 
 // --- 辅助函数 ---
 
+// execDir 解析命令的工作目录：经 sanitizePath 校验且必须是目录，空则为工作区根目录
+func (w *OSWorkspace) execDir(cwd string) (string, error) {
+	if strings.TrimSpace(cwd) == "" {
+		return w.root, nil
+	}
+	dir, err := w.sanitizePath(cwd)
+	if err != nil {
+		return "", fmt.Errorf("invalid cwd %q: %w", cwd, err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid cwd %q: %w", cwd, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid cwd %q: not a directory", cwd)
+	}
+	return dir, nil
+}
+
+// execStdin 生成命令的标准输入（Stdin 与 StdinFile 互斥，大小不超过 MaxFileBytes），都为空时返回 nil
+func (w *OSWorkspace) execStdin(opts ExecOptions) (io.Reader, error) {
+	limit := w.cfg.MaxFileBytes
+	if limit <= 0 {
+		limit = config.DefaultMaxFileBytes
+	}
+	switch {
+	case opts.Stdin != "" && opts.StdinFile != "":
+		return nil, fmt.Errorf("stdin and stdin file cannot both be set")
+	case opts.Stdin != "":
+		if int64(len(opts.Stdin)) > limit {
+			return nil, fmt.Errorf("stdin too large (%d bytes, limit %d)", len(opts.Stdin), limit)
+		}
+		return strings.NewReader(opts.Stdin), nil
+	case opts.StdinFile != "":
+		absPath, err := w.sanitizePath(opts.StdinFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stdin file %q: %w", opts.StdinFile, err)
+		}
+		if w.isBlockedExtension(absPath) {
+			return nil, fmt.Errorf("extension blocked for file %q", absPath)
+		}
+		f, err := os.Open(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open stdin file %q: %w", opts.StdinFile, err)
+		}
+		info, err := f.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			f.Close()
+			return nil, fmt.Errorf("stdin file %q is not a regular file", opts.StdinFile)
+		}
+		if info.Size() > limit {
+			f.Close()
+			return nil, fmt.Errorf("stdin file too large (%d bytes, limit %d)", info.Size(), limit)
+		}
+		return f, nil
+	}
+	return nil, nil
+}
+
+// childConfig 返回以工作区内目录 root 为根、继承本工作区全部策略的配置（用于客户端 root）
+// 相对的 AllowedPaths 转为绝对路径，仍按本工作区的根目录解释
+func (w *OSWorkspace) childConfig(root string) *config.Config {
+	c := *w.cfg
+	c.RootDir = root
+	if len(w.cfg.AllowedPaths) > 0 {
+		c.AllowedPaths = make([]string, len(w.cfg.AllowedPaths))
+		for i, p := range w.cfg.AllowedPaths {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(w.root, p)
+			}
+			c.AllowedPaths[i] = p
+		}
+	}
+	return &c
+}
+
 // sanitizePath 路径安全检查：归一化 + 确保在 root 内 + AllowedPaths 白名单
 func (w *OSWorkspace) sanitizePath(path string) (string, error) {
 	// 1. 规范化并检查空
@@ -363,40 +513,6 @@ func (w *OSWorkspace) isBlockedExtension(path string) bool {
 	return false
 }
 
-// isAllowedCommand 检查命令是否在白名单中
-func (w *OSWorkspace) isAllowedCommand(cmd string) bool {
-	cmd = strings.TrimSpace(cmd)
-	if cmd == "" {
-		return false
-	}
-	
-	fields := strings.Fields(cmd)
-	if len(fields) == 0 {
-		return false
-	}
-	baseCmd := fields[0]
-	
-	for _, allowed := range w.allowedCommands {
-		allowed = strings.TrimSpace(allowed)
-		if allowed == "" {
-			continue
-		}
-		// 精确匹配基础命令名
-		if allowed == baseCmd {
-			return true
-		}
-		// 支持前缀匹配：例如 allowed="go" 可匹配 "go build" 等
-		if strings.HasPrefix(cmd, allowed) {
-			// 要确保匹配到完整单词（例如 "go" 应匹配 "go build"，但不匹配 "gomod"）
-			// 由于 cmd 以 allowed 开头，再检查后面是空白还是完全相等
-			if len(cmd) == len(allowed) || cmd[len(allowed)] == ' ' || cmd[len(allowed)] == '\t' {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 // SecureExec 满足 Workspace 接口，提供安全的命令执行
 // 它基于 Execute 添加白名单校验和输出截断
 func (w *OSWorkspace) SecureExec(ctx context.Context, cmd string, args []string, timeoutSeconds int64) (stdout string, stderr string, exitCode int, err error) {
@@ -96,7 +96,7 @@ func TestOSWorkspace_WriteFile(t *testing.T) {
 	ws, _ := NewOSWorkspace(cfg)
 
 	// 测试创建新文件
-	err := ws.WriteFile(context.Background(), "newfile.txt", []byte("content"), true)
+	err := ws.WriteFile(context.Background(), "newfile.txt", []byte("content"), true, "")
 	if err != nil {
 		t.Fatalf("WriteFile create failed: %v", err)
 	}
@@ -106,7 +106,7 @@ func TestOSWorkspace_WriteFile(t *testing.T) {
 	}
 
 	// 测试覆写现有文件
-	err = ws.WriteFile(context.Background(), "newfile.txt", []byte("new content"), true)
+	err = ws.WriteFile(context.Background(), "newfile.txt", []byte("new content"), true, "")
 	if err != nil {
 		t.Fatalf("WriteFile overwrite failed: %v", err)
 	}
@@ -116,13 +116,13 @@ func TestOSWorkspace_WriteFile(t *testing.T) {
 	}
 
 	// 测试 allowCreate=false 时文件不存在则报错
-	err = ws.WriteFile(context.Background(), "missing.txt", []byte("data"), false)
+	err = ws.WriteFile(context.Background(), "missing.txt", []byte("data"), false, "")
 	if err == nil {
 		t.Error("expected error for non-existent file with allowCreate=false")
 	}
 
 	// 测试黑名单扩展名
-	err = ws.WriteFile(context.Background(), "blocked.lock", []byte("bad"), true)
+	err = ws.WriteFile(context.Background(), "blocked.lock", []byte("bad"), true, "")
 	if err == nil {
 		t.Error("expected blocked extension error")
 	}
@@ -196,3 +196,50 @@ func TestOSWorkspace_PhysicalFileSize(t *testing.T) {
 		t.Errorf("expected positive size, got %d", size)
 	}
 }
+
+func TestOSWorkspace_ExecuteCwdAndStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "sub", "mod"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "input.txt"), []byte("from file"), 0644)
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		AllowedBuildCommands: []string{"sh", "cat"},
+		BuildTimeout:         10,
+		MaxFileBytes:         16,
+	}
+	ws, _ := NewOSWorkspace(cfg)
+	ctx := context.Background()
+
+	// cwd 为工作区内的子目录
+	res, err := ws.ExecuteStream(ctx, "sh", []string{"-c", "pwd"}, ExecOptions{Cwd: "sub/mod"})
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+	if got := strings.TrimSpace(res.Stdout); !strings.HasSuffix(got, filepath.Join("sub", "mod")) {
+		t.Errorf("pwd = %q, want .../sub/mod", got)
+	}
+	for _, cwd := range []string{"..", "input.txt", "missing"} {
+		if _, err := ws.ExecuteStream(ctx, "sh", []string{"-c", "pwd"}, ExecOptions{Cwd: cwd}); err == nil || !strings.Contains(err.Error(), "invalid cwd") {
+			t.Errorf("cwd %q: expected invalid cwd error, got %v", cwd, err)
+		}
+	}
+
+	// stdin 文本与文件
+	res, err = ws.ExecuteStream(ctx, "cat", nil, ExecOptions{Stdin: "hello"})
+	if err != nil || res.Stdout != "hello" {
+		t.Errorf("stdin: stdout = %q, err = %v", res.Stdout, err)
+	}
+	res, err = ws.ExecuteStream(ctx, "cat", nil, ExecOptions{StdinFile: "input.txt"})
+	if err != nil || res.Stdout != "from file" {
+		t.Errorf("stdin file: stdout = %q, err = %v", res.Stdout, err)
+	}
+	if _, err := ws.ExecuteStream(ctx, "cat", nil, ExecOptions{Stdin: strings.Repeat("x", 17)}); err == nil {
+		t.Error("expected stdin size limit error")
+	}
+	if _, err := ws.ExecuteStream(ctx, "cat", nil, ExecOptions{Stdin: "a", StdinFile: "input.txt"}); err == nil {
+		t.Error("expected error when both stdin and stdin file are set")
+	}
+}
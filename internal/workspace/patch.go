@@ -0,0 +1,284 @@
+package workspace
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 本文件实现 unified diff 的补丁引擎（apply_unified_diff 使用），行为参照 GNU patch：
+//  1. hunk 的旧内容（上下文行 + 删除行）必须与文件逐行一致，@@ 行号只作为搜索起点。
+//  2. 先在期望位置（@@ 行号 + 上一个 hunk 的偏移）匹配，失败时向前后交替搜索（不超过 maxOffset 行）；
+//     仍失败时依次忽略首尾各 1..fuzz 行上下文再搜索。
+//  3. hunk 按顺序应用且不得重叠；"\ No newline at end of file" 决定补丁触及文件末尾时是否保留末尾换行。
+//  4. 每个 hunk 报告 applied / offset / fuzz / failed；失败时给出期望的行与文件在期望位置的实际行。
+
+// DefaultPatchFuzz 默认最多忽略的首尾上下文行数（与 GNU patch 相同）
+const DefaultPatchFuzz = 2
+
+// hunk 应用状态
+const (
+	HunkApplied = "applied"
+	HunkOffset  = "offset"
+	HunkFuzz    = "fuzz"
+	HunkFailed  = "failed"
+)
+
+// HunkResult 单个 hunk 的应用结果
+type HunkResult struct {
+	Hunk     int      `json:"hunk"`   // 序号，从 1 开始
+	Status   string   `json:"status"` // applied / offset / fuzz / failed
+	Line     int      `json:"line"`   // 应用位置（原文件行号）；失败时为期望位置
+	Offset   int      `json:"offset,omitempty"`
+	Fuzz     int      `json:"fuzz,omitempty"`
+	Expected []string `json:"expected,omitempty"` // 仅失败：hunk 的旧内容
+	Actual   []string `json:"actual,omitempty"`   // 仅失败：文件在期望位置的实际内容
+}
+
+// fileLines 按行表示的文件内容
+type fileLines struct {
+	lines []string // 不含换行符
+	noEOL bool     // 最后一行没有换行符
+}
+
+func splitFileLines(data []byte) fileLines {
+	s := string(data)
+	if s == "" {
+		return fileLines{}
+	}
+	var f fileLines
+	if strings.HasSuffix(s, "\n") {
+		s = s[:len(s)-1]
+	} else {
+		f.noEOL = true
+	}
+	f.lines = strings.Split(s, "\n")
+	return f
+}
+
+func (f fileLines) bytes() []byte {
+	if len(f.lines) == 0 {
+		return []byte{}
+	}
+	s := strings.Join(f.lines, "\n")
+	if !f.noEOL {
+		s += "\n"
+	}
+	return []byte(s)
+}
+
+// applyPatchToContent 将补丁应用到原始内容，返回新内容与每个 hunk 的结果；失败的 hunk 不改变内容
+func applyPatchToContent(original []byte, patch DiffPatch, fuzz, maxOffset int) ([]byte, []HunkResult) {
+	orig := splitFileLines(original)
+	var out fileLines
+	results := make([]HunkResult, len(patch.Hunks))
+	cursor := 0     // 原文件中尚未复制的第一行
+	lastOffset := 0 // 上一个成功 hunk 的偏移
+	var tail *Hunk  // 触及文件末尾的 hunk（决定末尾换行）
+
+	for i := range patch.Hunks {
+		h := &patch.Hunks[i]
+		want := h.OldStart - 1
+		if oldLen(h.Lines) == 0 {
+			// 纯插入：插在 OldStart 行之后
+			want = h.OldStart
+		}
+		res := HunkResult{Hunk: i + 1}
+		pos, lead, trail, ok := locateHunk(orig.lines, h.Lines, want+lastOffset, cursor, fuzz, maxOffset)
+		if !ok {
+			res.Status = HunkFailed
+			res.Line = clamp(want+lastOffset, 0, len(orig.lines)) + 1
+			res.Expected = oldLines(h.Lines)
+			end := clamp(res.Line-1+len(res.Expected), 0, len(orig.lines))
+			res.Actual = append([]string{}, orig.lines[res.Line-1:end]...)
+			results[i] = res
+			continue
+		}
+
+		start := pos - lead
+		res.Line = start + 1
+		res.Offset = start - want
+		res.Fuzz = max(lead, trail)
+		switch {
+		case res.Fuzz > 0:
+			res.Status = HunkFuzz
+		case res.Offset != 0:
+			res.Status = HunkOffset
+		default:
+			res.Status = HunkApplied
+		}
+		results[i] = res
+		lastOffset = res.Offset
+
+		out.lines = append(out.lines, orig.lines[cursor:pos]...)
+		k := pos
+		for _, l := range h.Lines[lead : len(h.Lines)-trail] {
+			switch l.Type {
+			case " ":
+				out.lines = append(out.lines, orig.lines[k])
+				k++
+			case "-":
+				k++
+			case "+":
+				out.lines = append(out.lines, l.Text)
+			}
+		}
+		cursor = k
+		tail = nil
+		if cursor == len(orig.lines) && trail == 0 {
+			tail = h
+		}
+	}
+	out.lines = append(out.lines, orig.lines[cursor:]...)
+
+	out.noEOL = orig.noEOL
+	if tail != nil && cursor == len(orig.lines) {
+		// 末尾由补丁决定：新内容最后一行带 "\ No newline" 标记时不加换行
+		out.noEOL = false
+		if n := newLast(tail.Lines); n != nil {
+			out.noEOL = n.NoNewline
+		}
+	}
+	return out.bytes(), results
+}
+
+// locateHunk 查找 hunk 的应用位置；返回匹配位置（已去掉 lead 行上下文）与首尾忽略的上下文行数
+func locateHunk(lines []string, hunk []Line, want, minPos, fuzz, maxOffset int) (pos, lead, trail int, ok bool) {
+	leadCtx, trailCtx := contextRun(hunk)
+	prevLead, prevTrail := -1, -1
+	for f := 0; f <= fuzz; f++ {
+		lead, trail = min(f, leadCtx), min(f, trailCtx)
+		if lead == prevLead && trail == prevTrail {
+			break
+		}
+		prevLead, prevTrail = lead, trail
+		pattern := oldLines(hunk[lead : len(hunk)-trail])
+		if len(pattern) == 0 {
+			if oldLen(hunk) > 0 {
+				// 上下文全部被忽略时不再有可校验的内容
+				continue
+			}
+			return clamp(want, minPos, len(lines)), 0, 0, true
+		}
+		if pos, ok := searchLines(lines, pattern, want+lead, minPos, maxOffset); ok {
+			return pos, lead, trail, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// searchLines 从 start 开始向前后交替搜索 pattern，位置不小于 minPos，偏移不超过 maxOffset（< 0 不限制）
+func searchLines(lines, pattern []string, start, minPos, maxOffset int) (int, bool) {
+	last := len(lines) - len(pattern)
+	for d := 0; maxOffset < 0 || d <= maxOffset; d++ {
+		before, after := start-d, start+d
+		if after > last && before < minPos {
+			break
+		}
+		if after >= minPos && after <= last && matchLines(lines[after:], pattern) {
+			return after, true
+		}
+		if d > 0 && before >= minPos && before <= last && matchLines(lines[before:], pattern) {
+			return before, true
+		}
+	}
+	return 0, false
+}
+
+func matchLines(lines, pattern []string) bool {
+	for i, p := range pattern {
+		if lines[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// contextRun 返回 hunk 开头与结尾连续上下文行的数量
+func contextRun(hunk []Line) (lead, trail int) {
+	for lead < len(hunk) && hunk[lead].Type == " " {
+		lead++
+	}
+	if lead == len(hunk) {
+		return lead, 0
+	}
+	for trail < len(hunk) && hunk[len(hunk)-1-trail].Type == " " {
+		trail++
+	}
+	return lead, trail
+}
+
+// oldLines 返回 hunk 的旧内容（上下文行 + 删除行）
+func oldLines(hunk []Line) []string {
+	out := make([]string, 0, len(hunk))
+	for _, l := range hunk {
+		if l.Type != "+" {
+			out = append(out, l.Text)
+		}
+	}
+	return out
+}
+
+func oldLen(hunk []Line) int {
+	n := 0
+	for _, l := range hunk {
+		if l.Type != "+" {
+			n++
+		}
+	}
+	return n
+}
+
+// newLast 返回 hunk 新内容（上下文行 + 添加行）的最后一行
+func newLast(hunk []Line) *Line {
+	for i := len(hunk) - 1; i >= 0; i-- {
+		if hunk[i].Type != "-" {
+			return &hunk[i]
+		}
+	}
+	return nil
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// hunkFailure 描述失败 hunk 的第一处不一致
+func hunkFailure(r HunkResult) string {
+	for i, want := range r.Expected {
+		if i >= len(r.Actual) {
+			return fmt.Sprintf("hunk #%d failed at line %d: expected %q, found end of file", r.Hunk, r.Line+i, want)
+		}
+		if r.Actual[i] != want {
+			return fmt.Sprintf("hunk #%d failed at line %d: expected %q, found %q", r.Hunk, r.Line+i, want, r.Actual[i])
+		}
+	}
+	return fmt.Sprintf("hunk #%d failed at line %d: context matches but overlaps an earlier hunk", r.Hunk, r.Line)
+}
+
+// formatRejects 把失败的 hunk 格式化为 .rej 文件内容（unified diff）
+func formatRejects(path string, hunks []Hunk, results []HunkResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+	for i, h := range hunks {
+		if results[i].Status != HunkFailed {
+			continue
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range h.Lines {
+			if l.Type != "+" {
+				oldCount++
+			}
+			if l.Type != "-" {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, oldCount, h.NewStart, newCount)
+		for _, l := range h.Lines {
+			b.WriteString(l.Type + l.Text + "\n")
+			if l.NoNewline {
+				b.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+)
+
+func applyTestDiff(t *testing.T, original, diff string, fuzz, maxOffset int) (string, []HunkResult) {
+	t.Helper()
+	patches, err := parseUnifiedDiff(diff)
+	if err != nil || len(patches) != 1 {
+		t.Fatalf("parseUnifiedDiff: %v (%d patches)", err, len(patches))
+	}
+	out, results := applyPatchToContent([]byte(original), patches[0], fuzz, maxOffset)
+	return string(out), results
+}
+
+func TestApplyPatchToContent_Offset(t *testing.T) {
+	original := "x\ny\nline1\nline2\nline3\nline4\n"
+	diff := `--- a/f
++++ b/f
+@@ -1,3 +1,3 @@
+ line1
+-line2
++LINE2
+ line3
+`
+	out, results := applyTestDiff(t, original, diff, 0, -1)
+	if results[0].Status != HunkOffset || results[0].Offset != 2 || results[0].Line != 3 {
+		t.Fatalf("unexpected result %+v", results[0])
+	}
+	if out != "x\ny\nline1\nLINE2\nline3\nline4\n" {
+		t.Errorf("out = %q", out)
+	}
+
+	// 偏移超出 maxOffset 时失败，内容不变
+	out, results = applyTestDiff(t, original, diff, 0, 1)
+	if results[0].Status != HunkFailed || out != original {
+		t.Errorf("expected failure within maxOffset 1, got %+v, %q", results[0], out)
+	}
+}
+
+func TestApplyPatchToContent_Fuzz(t *testing.T) {
+	original := "a\nb\nc\nd\ne\n"
+	diff := `--- a/f
++++ b/f
+@@ -1,5 +1,5 @@
+ a
+ stale
+-c
++C
+ d
+ e
+`
+	out, results := applyTestDiff(t, original, diff, 0, -1)
+	if results[0].Status != HunkFailed {
+		t.Fatalf("expected failure without fuzz, got %+v", results[0])
+	}
+	if !strings.Contains(hunkFailure(results[0]), `line 2: expected "stale", found "b"`) {
+		t.Errorf("failure message = %s", hunkFailure(results[0]))
+	}
+	out, results = applyTestDiff(t, original, diff, 2, -1)
+	if results[0].Status != HunkFuzz || results[0].Fuzz != 2 {
+		t.Fatalf("expected fuzz 2, got %+v", results[0])
+	}
+	if out != "a\nb\nC\nd\ne\n" {
+		t.Errorf("out = %q", out)
+	}
+}
+
+func TestApplyPatchToContent_NoNewline(t *testing.T) {
+	// 在没有末尾换行的文件末尾追加一行
+	diff := `--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
+-b
+\ No newline at end of file
++b
++c
+`
+	out, results := applyTestDiff(t, "a\nb", diff, 0, 0)
+	if results[0].Status != HunkApplied || out != "a\nb\nc\n" {
+		t.Errorf("got %+v, %q", results[0], out)
+	}
+
+	// 去掉末尾换行
+	diff = `--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
++b
+\ No newline at end of file
+`
+	out, _ = applyTestDiff(t, "a\nb\n", diff, 0, 0)
+	if out != "a\nb" {
+		t.Errorf("out = %q", out)
+	}
+
+	// 末尾未被补丁触及时保持原样
+	diff = `--- a/f
++++ b/f
+@@ -1,1 +1,1 @@
+-a
++A
+`
+	out, _ = applyTestDiff(t, "a\nb", diff, 0, 0)
+	if out != "A\nb" {
+		t.Errorf("out = %q", out)
+	}
+}
+
+func TestParseUnifiedDiff_BlankContextAndHeader(t *testing.T) {
+	// 空行上下文（行尾空格被编辑器去掉）与带函数名的 hunk 头
+	diff := "--- a/f.go\t2024-01-01 00:00:00\n+++ b/f.go\n@@ -1,3 +1,3 @@ func main() {\n a\n\n-b\n+B\n"
+	patches, err := parseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatalf("parseUnifiedDiff failed: %v", err)
+	}
+	p := patches[0]
+	if p.FilePath != "f.go" || p.IsNewFile {
+		t.Errorf("unexpected patch %+v", p)
+	}
+	if len(p.Hunks[0].Lines) != 4 || p.Hunks[0].Lines[1] != (Line{Type: " "}) {
+		t.Errorf("unexpected lines %+v", p.Hunks[0].Lines)
+	}
+	out, results := applyPatchToContent([]byte("a\n\nb\n"), p, 0, 0)
+	if results[0].Status != HunkApplied || string(out) != "a\n\nB\n" {
+		t.Errorf("got %+v, %q", results[0], out)
+	}
+}
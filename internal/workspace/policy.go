@@ -0,0 +1,248 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现参数级命令策略（secure_exec 与 explain_policy 共用）：
+//  1. cmd 与 args 先拆成 argv，argv[0] 为可执行文件名；cmd 中带空格（如 "go build"）时拆出的部分视为参数。
+//  2. 若 command_policies 中有该可执行文件的策略，按 max_args → subcommands → deny_args → allow_args 顺序检查，
+//     此时不再参考 allowed_build_commands。
+//  3. 否则按 allowed_build_commands 做逐词前缀匹配：条目 "go build" 只允许 argv 以 go、build 开头，
+//     条目 "go" 允许任意 go 调用（兼容旧配置，建议改用 command_policies）。
+//  4. 无论通过哪条规则放行，看起来像路径的参数（含 -flag=value 的 value，以及单短横线 flag 紧跟的值，
+//     如 -C/etc、-o/tmp/x）按命令的工作目录（cwd）解析后
+//     都必须经 sanitizePath 落在工作区内（os.DevNull 除外，如 go build -o /dev/null）。
+//  5. 拒绝时 PolicyDecision.Rule 指出命中的规则，便于 Agent 调整命令。
+
+// PolicyDecision 命令策略的判定结果
+type PolicyDecision struct {
+	Allowed bool     `json:"allowed"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Rule    string   `json:"rule"`   // 命中的规则，如 command_policies[go].deny_args "-toolexec"
+	Reason  string   `json:"reason"` // 人类可读的说明
+}
+
+// ExplainCommand 判断命令在当前工作区策略下是否允许执行（不执行命令），路径参数按工作区根目录解析
+func (w *OSWorkspace) ExplainCommand(cmd string, args []string) PolicyDecision {
+	return w.explainCommand(w.root, cmd, args)
+}
+
+// explainCommand 同 ExplainCommand，相对路径参数按命令的工作目录 dir（绝对路径）解析
+func (w *OSWorkspace) explainCommand(dir, cmd string, args []string) PolicyDecision {
+	argv := append(strings.Fields(cmd), args...)
+	if len(argv) == 0 {
+		return PolicyDecision{Rule: "empty", Reason: "command cannot be empty"}
+	}
+	d := PolicyDecision{Command: argv[0], Args: argv[1:]}
+	if d.Args == nil {
+		d.Args = []string{}
+	}
+
+	if policy, ok := w.findPolicy(d.Command); ok {
+		checkPolicy(&d, policy)
+	} else {
+		w.checkAllowList(&d)
+	}
+	if d.Allowed {
+		w.checkPathArgs(&d, dir)
+	}
+	return d
+}
+
+// checkCommand 执行前的策略校验（dir 为命令的工作目录），拒绝时返回包含规则说明的错误
+func (w *OSWorkspace) checkCommand(dir, cmd string, args []string) error {
+	d := w.explainCommand(dir, cmd, args)
+	if !d.Allowed {
+		return fmt.Errorf("command not allowed: %s (rule: %s)", d.Reason, d.Rule)
+	}
+	return nil
+}
+
+func (w *OSWorkspace) findPolicy(command string) (config.CommandPolicy, bool) {
+	for _, p := range w.cfg.CommandPolicies {
+		if strings.TrimSpace(p.Command) == command {
+			return p, true
+		}
+	}
+	return config.CommandPolicy{}, false
+}
+
+// checkPolicy 按 command_policies 中的结构化策略判定
+func checkPolicy(d *PolicyDecision, p config.CommandPolicy) {
+	rule := fmt.Sprintf("command_policies[%s]", d.Command)
+
+	if p.MaxArgs > 0 && len(d.Args) > p.MaxArgs {
+		d.Rule = fmt.Sprintf("%s.max_args %d", rule, p.MaxArgs)
+		d.Reason = fmt.Sprintf("%d arguments exceed the limit of %d", len(d.Args), p.MaxArgs)
+		return
+	}
+
+	rest := d.Args
+	sub := ""
+	if len(p.Subcommands) > 0 {
+		for _, candidate := range p.Subcommands {
+			words := strings.Fields(candidate)
+			if len(words) > 0 && hasWordPrefix(d.Args, words) && len(words) > len(strings.Fields(sub)) {
+				sub = strings.Join(words, " ")
+			}
+		}
+		if sub == "" {
+			d.Rule = rule + ".subcommands"
+			d.Reason = fmt.Sprintf("subcommand %q is not in %v", firstArg(d.Args), p.Subcommands)
+			return
+		}
+		rest = d.Args[len(strings.Fields(sub)):]
+	}
+
+	for _, arg := range rest {
+		if pattern, ok := matchAnyArg(p.DenyArgs, arg); ok {
+			d.Rule = fmt.Sprintf("%s.deny_args %q", rule, pattern)
+			d.Reason = fmt.Sprintf("argument %q is denied", arg)
+			return
+		}
+	}
+	if len(p.AllowArgs) > 0 {
+		for _, arg := range rest {
+			if _, ok := matchAnyArg(p.AllowArgs, arg); !ok {
+				d.Rule = rule + ".allow_args"
+				d.Reason = fmt.Sprintf("argument %q matches none of %v", arg, p.AllowArgs)
+				return
+			}
+		}
+	}
+
+	d.Allowed = true
+	d.Rule = rule
+	if sub != "" {
+		d.Rule = fmt.Sprintf("%s.subcommands %q", rule, sub)
+	}
+	d.Reason = "allowed by command policy"
+}
+
+// checkAllowList 按 allowed_build_commands 逐词前缀匹配（兼容旧配置）
+func (w *OSWorkspace) checkAllowList(d *PolicyDecision) {
+	argv := append([]string{d.Command}, d.Args...)
+	for _, allowed := range w.allowedCommands {
+		words := strings.Fields(allowed)
+		if len(words) > 0 && hasWordPrefix(argv, words) {
+			d.Allowed = true
+			d.Rule = fmt.Sprintf("allowed_build_commands %q", strings.Join(words, " "))
+			d.Reason = "allowed by command prefix"
+			return
+		}
+	}
+	d.Rule = "allowed_build_commands"
+	d.Reason = fmt.Sprintf("%q matches no allowed command prefix", strings.Join(argv, " "))
+}
+
+// checkPathArgs 要求看起来像路径的参数按工作目录 dir 解析后仍在工作区（及 AllowedPaths）内
+func (w *OSWorkspace) checkPathArgs(d *PolicyDecision, dir string) {
+	for _, arg := range d.Args {
+		values := []string{arg}
+		if strings.HasPrefix(arg, "-") {
+			values = flagValues(arg)
+		}
+		for _, value := range values {
+			if !looksLikePath(value) || value == os.DevNull {
+				continue
+			}
+			if !filepath.IsAbs(value) {
+				// 命令在 dir 中运行，../x 之类的相对路径相对于 dir 而不是工作区根目录
+				value = filepath.Join(dir, value)
+			}
+			if _, err := w.sanitizePath(value); err != nil {
+				d.Allowed = false
+				d.Rule = "path_args"
+				d.Reason = fmt.Sprintf("argument %q: %v", arg, err)
+				return
+			}
+		}
+	}
+}
+
+// flagValues 返回 flag 参数中可能携带的值：-flag=value 的 value；
+// 单短横线 flag 没有 = 时值可能直接附在短选项后（make -C/etc、gcc -I/、-f../x），返回 arg[2:]
+func flagValues(arg string) []string {
+	if i := strings.IndexByte(arg, '='); i >= 0 {
+		return []string{arg[i+1:]}
+	}
+	if strings.HasPrefix(arg, "--") || len(arg) <= 2 {
+		return nil
+	}
+	return []string{arg[2:]}
+}
+
+// looksLikePath 粗略判断参数是否为文件路径（绝对路径、~ 开头或包含路径分隔符）
+func looksLikePath(s string) bool {
+	if s == "" || strings.Contains(s, "://") {
+		return false
+	}
+	return filepath.IsAbs(s) || strings.HasPrefix(s, "~") || s == "." || s == ".." ||
+		strings.ContainsAny(s, `/\`)
+}
+
+// hasWordPrefix 判断 argv 是否以 words 逐词开头
+func hasWordPrefix(argv, words []string) bool {
+	if len(argv) < len(words) {
+		return false
+	}
+	for i, w := range words {
+		if argv[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAnyArg 返回第一个匹配参数的模式
+func matchAnyArg(patterns []string, arg string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchArgPattern(pattern, arg) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchArgPattern 用 * / ? 通配匹配参数；flag 模式忽略前导短横线数量与 =value 部分
+func matchArgPattern(pattern, arg string) bool {
+	re := argPatternRegexp(pattern)
+	if re.MatchString(arg) {
+		return true
+	}
+	if !strings.HasPrefix(pattern, "-") || !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	name := arg
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		name = name[:i]
+	}
+	return argPatternRegexp(normalizeFlag(pattern)).MatchString(normalizeFlag(name))
+}
+
+func argPatternRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// normalizeFlag 将 --flag 统一为 -flag
+func normalizeFlag(s string) string {
+	return "-" + strings.TrimLeft(s, "-")
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
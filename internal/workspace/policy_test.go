@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_ExplainCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		BuildTimeout:         5,
+		AllowedBuildCommands: []string{"go build", "make"},
+		CommandPolicies: []config.CommandPolicy{
+			{
+				Command:     "go",
+				Subcommands: []string{"test", "vet", "mod tidy"},
+				DenyArgs:    []string{"-exec", "-toolexec"},
+				MaxArgs:     4,
+			},
+			{Command: "git", Subcommands: []string{"status", "diff"}, AllowArgs: []string{"--stat", "-*short*"}},
+		},
+	}
+	ws, err := NewOSWorkspace(cfg)
+	if err != nil {
+		t.Fatalf("NewOSWorkspace failed: %v", err)
+	}
+
+	tests := []struct {
+		cmd     string
+		args    []string
+		allowed bool
+		rule    string
+	}{
+		{"go", []string{"test", "./..."}, true, `command_policies[go].subcommands "test"`},
+		{"go mod", []string{"tidy"}, true, `command_policies[go].subcommands "mod tidy"`},
+		{"go", []string{"run", "main.go"}, false, "command_policies[go].subcommands"},
+		{"go", []string{"test", "-toolexec=/bin/sh"}, false, `command_policies[go].deny_args "-toolexec"`},
+		{"go", []string{"test", "--exec", "x"}, false, `command_policies[go].deny_args "-exec"`},
+		{"go", []string{"test", "-v", "-race", "-count=1", "./..."}, false, "command_policies[go].max_args 4"},
+		{"go", []string{"vet", "../outside"}, false, "path_args"},
+		{"go", []string{"test", "-coverprofile=" + filepath.Join(filepath.Dir(tmpDir), "c.out")}, false, "path_args"},
+		{"git", []string{"diff", "--stat"}, true, `command_policies[git].subcommands "diff"`},
+		{"git", []string{"status", "--porcelain"}, false, "command_policies[git].allow_args"},
+		{"make", []string{"all"}, true, `allowed_build_commands "make"`},
+		{"rm", []string{"-rf", "."}, false, "allowed_build_commands"},
+		{"", nil, false, "empty"},
+	}
+	for _, tt := range tests {
+		d := ws.ExplainCommand(tt.cmd, tt.args)
+		if d.Allowed != tt.allowed || d.Rule != tt.rule {
+			t.Errorf("ExplainCommand(%q, %q) = allowed %v rule %q (%s), want allowed %v rule %q",
+				tt.cmd, tt.args, d.Allowed, d.Rule, d.Reason, tt.allowed, tt.rule)
+		}
+	}
+
+	// 执行时拒绝原因包含命中的规则
+	_, _, _, err = ws.Execute(context.Background(), "go", []string{"run", "main.go"}, 0)
+	if err == nil || !strings.Contains(err.Error(), "command_policies[go].subcommands") {
+		t.Errorf("expected policy error from Execute, got %v", err)
+	}
+}
+
+func TestOSWorkspace_PathArgsRelativeToCwd(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "a", "b"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "a", "c.txt"), []byte("inside"), 0644)
+	w, err := NewOSWorkspace(&config.Config{RootDir: tmpDir, BuildTimeout: 5, AllowedBuildCommands: []string{"cat"}})
+	if err != nil {
+		t.Fatalf("NewOSWorkspace failed: %v", err)
+	}
+	ws := w.(*OSWorkspace)
+	ctx := context.Background()
+
+	// 命令在 a/b 中运行，../../../x 指向工作区之外
+	_, err = ws.ExecuteStream(ctx, "cat", []string{"../../../x"}, ExecOptions{Cwd: "a/b"})
+	if err == nil || !strings.Contains(err.Error(), "path_args") {
+		t.Errorf("expected path_args error for nested cwd, got %v", err)
+	}
+	// ../c.txt 相对于 a/b 仍在工作区内
+	res, err := ws.ExecuteStream(ctx, "cat", []string{"../c.txt"}, ExecOptions{Cwd: "a/b"})
+	if err != nil || res.Stdout != "inside" {
+		t.Errorf("expected ../c.txt to be allowed from a/b, got %+v, %v", res, err)
+	}
+}
+
+func TestOSWorkspace_AttachedFlagValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	w, err := NewOSWorkspace(&config.Config{RootDir: tmpDir, BuildTimeout: 5, CommandPolicies: []config.CommandPolicy{
+		{Command: "make"}, {Command: "tar"}, {Command: "gcc"}, {Command: "go"},
+	}})
+	if err != nil {
+		t.Fatalf("NewOSWorkspace failed: %v", err)
+	}
+	for _, tt := range []struct {
+		cmd  string
+		args []string
+		want bool
+	}{
+		{"make", []string{"-C/etc"}, false},
+		{"make", []string{"-f../../x"}, false},
+		{"gcc", []string{"-I/", "main.c"}, false},
+		{"gcc", []string{"-o/tmp/x", "main.c"}, false},
+		{"tar", []string{"-f/tmp/a.tar", "-x"}, false},
+		{"make", []string{"-Csub"}, true},
+		{"gcc", []string{"-Iinclude/x", "-o", "out", "main.c"}, true},
+		{"go", []string{"test", "-race", "-v", "-run=TestA/sub", "./..."}, true},
+		{"go", []string{"build", "-o", os.DevNull, "."}, true},
+	} {
+		d := w.ExplainCommand(tt.cmd, tt.args)
+		if d.Allowed != tt.want {
+			t.Errorf("%s %v: allowed = %v, want %v (%s)", tt.cmd, tt.args, d.Allowed, tt.want, d.Reason)
+		}
+		if !tt.want && d.Rule != "path_args" {
+			t.Errorf("%s %v: rule = %q, want path_args", tt.cmd, tt.args, d.Rule)
+		}
+	}
+}
+
+func TestMatchArgPattern(t *testing.T) {
+	tests := []struct {
+		pattern, arg string
+		want         bool
+	}{
+		{"-exec", "-exec", true},
+		{"-exec", "--exec=foo", true},
+		{"-exec", "-execute", false},
+		{"-run=*", "-run=TestFoo", true},
+		{"./*", "./...", true},
+		{"v?", "v1", true},
+		{"v?", "v10", false},
+	}
+	for _, tt := range tests {
+		if got := matchArgPattern(tt.pattern, tt.arg); got != tt.want {
+			t.Errorf("matchArgPattern(%q, %q) = %v, want %v", tt.pattern, tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package workspace
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件处理 Linux 下执行命令的进程管理（ExecuteStream 使用）：
+//  1. 每条命令在独立的进程组中运行，超时或取消时向整个进程组发送 SIGKILL，
+//     go test 派生的编译器与测试二进制等子孙进程不会遗留。
+//  2. 资源限制由初始化进程在 exec 原命令前设置（见 execinit_linux.go 与 execWithLimits），之后派生的子进程继承同样的限制。
+
+// setupProcess 让命令在独立进程组中运行，并在取消时终止整个进程组
+func setupProcess(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+}
+
+// killProcessGroup 向命令所在的进程组发送 SIGKILL（pgid 与主进程 pid 相同）
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to kill process group %d: %w", cmd.Process.Pid, err)
+	}
+	return nil
+}
+
+// execWithLimits 设置资源限制后 exec 原命令（不返回，失败时返回错误）
+// 参数在设置限制前准备好：初始化进程自身是 Go 程序，设置 RLIMIT_AS 后运行时可能无法再分配内存
+func execWithLimits(path string, argv, envv []string, limits config.ResourceLimits) error {
+	pathp, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	argvp, err := syscall.SlicePtrFromStrings(argv)
+	if err != nil {
+		return err
+	}
+	envvp, err := syscall.SlicePtrFromStrings(envv)
+	if err != nil {
+		return err
+	}
+	if err := setResourceLimits(limits); err != nil {
+		return err
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE,
+		uintptr(unsafe.Pointer(pathp)),
+		uintptr(unsafe.Pointer(&argvp[0])),
+		uintptr(unsafe.Pointer(&envvp[0])))
+	return fmt.Errorf("exec %s: %w", path, errno)
+}
+
+// setResourceLimits 为当前进程设置 rlimit，值为 0 的项不做限制
+func setResourceLimits(limits config.ResourceLimits) error {
+	const mb = 1 << 20
+	for _, l := range []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"cpu_seconds", unix.RLIMIT_CPU, limits.CPUSeconds},
+		{"address_space_mb", unix.RLIMIT_AS, limits.AddressSpaceMB * mb},
+		{"open_files", unix.RLIMIT_NOFILE, limits.OpenFiles},
+		{"max_processes", unix.RLIMIT_NPROC, limits.MaxProcesses},
+		{"file_size_mb", unix.RLIMIT_FSIZE, limits.FileSizeMB * mb},
+	} {
+		if l.value == 0 {
+			continue
+		}
+		var old unix.Rlimit
+		if err := unix.Getrlimit(l.resource, &old); err != nil {
+			return fmt.Errorf("failed to read %s limit: %w", l.name, err)
+		}
+		// 不能超过当前硬限制（非特权进程无法提高硬限制）
+		value := l.value
+		if old.Max != unix.RLIM_INFINITY && value > old.Max {
+			value = old.Max
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("failed to set %s limit: %w", l.name, err)
+		}
+	}
+	return nil
+}
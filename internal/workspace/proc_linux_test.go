@@ -0,0 +1,69 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// TestMain 执行命令前的初始化进程复用测试二进制
+func TestMain(m *testing.M) {
+	MaybeRunExecInit()
+	os.Exit(m.Run())
+}
+
+func TestOSWorkspace_ExecuteKillsProcessGroup(t *testing.T) {
+	cfg := &config.Config{RootDir: t.TempDir(), AllowedBuildCommands: []string{"sh"}}
+	ws, _ := NewOSWorkspace(cfg)
+
+	// 子 shell 派生的 sleep 是孙进程，超时后应随进程组一起被终止
+	stdout, _, _, err := ws.Execute(context.Background(), "sh", []string{"-c", "sleep 30 & echo $!; wait"}, 1)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	pid, convErr := strconv.Atoi(strings.TrimSpace(stdout))
+	if convErr != nil {
+		t.Fatalf("unexpected stdout %q", stdout)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("grandchild %d still running after timeout", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestOSWorkspace_ExecuteResourceLimits(t *testing.T) {
+	cfg := &config.Config{
+		RootDir:              t.TempDir(),
+		AllowedBuildCommands: []string{"sh"},
+		BuildTimeout:         10,
+		ResourceLimits:       config.ResourceLimits{OpenFiles: 64},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	stdout, _, _, err := ws.Execute(context.Background(), "sh", []string{"-c", "ulimit -n"}, 0)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := strings.TrimSpace(stdout); got != "64" {
+		t.Errorf("ulimit -n = %q, want 64", got)
+	}
+
+	// LowResourceMode 下未配置的项使用默认值
+	cfg.LowResourceMode = true
+	stdout, stderr, _, err := ws.Execute(context.Background(), "sh", []string{"-c", "ulimit -t; ulimit -n"}, 0)
+	if err != nil {
+		t.Fatalf("Execute failed: %v (stderr %q)", err, stderr)
+	}
+	if got := strings.Fields(stdout); len(got) != 2 || got[0] != "600" || got[1] != "64" {
+		t.Errorf("limits = %q, want [600 64]", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package workspace
+
+import "os/exec"
+
+// 非 Linux 平台：不使用独立进程组，取消时只终止直接子进程（exec.CommandContext 默认行为）
+
+func setupProcess(cmd *exec.Cmd) {}
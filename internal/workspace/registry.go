@@ -0,0 +1,286 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现多工作区注册表：
+//  1. 配置了 workspaces 时，每个命名工作区拥有独立的根目录、AllowedPaths、BlockedExtensions 与命令白名单，
+//     第一个即为默认工作区；未配置时只有一个名为 "default" 的工作区（RootDir 或当前目录）。
+//  2. 客户端通过 MCP roots/list 提供的根目录作为 source=client 的工作区追加在后面，每次刷新整体替换；
+//     与已有工作区根目录相同的 root 会被跳过。客户端 root 按会话分别保存（stdio 只有一个会话 ""，
+//     HTTP 模式下每个会话只看到自己的 root），会话结束时由 RemoveClient 删除。
+//  3. 客户端 root 必须位于某个已配置工作区之内（经 sanitizePath 校验，含 AllowedPaths 与符号链接），
+//     并继承该工作区的全部策略；之外的 root 被忽略，除非配置 allow_client_roots 显式允许（此时继承全局配置）。
+//  4. 未配置 workspaces 且未设置 RootDir 时，默认工作区改为该会话的第一个客户端 root（进程的当前目录往往不是项目目录）。
+//  5. 工作区实例创建后不再修改；配置热重载时整体重建并在锁内替换，进行中的工具调用继续使用旧实例，
+//     之后的调用通过 Get 拿到新策略。
+
+// DefaultWorkspaceName 未配置命名工作区时默认工作区的名称
+const DefaultWorkspaceName = "default"
+
+// WorkspaceInfo 描述一个已注册的工作区（用于 workspace.list_workspaces）
+type WorkspaceInfo struct {
+	Name    string `json:"name"`
+	Root    string `json:"root"`
+	Source  string `json:"source"` // "config" 或 "client"
+	Default bool   `json:"default"`
+	Warning string `json:"warning,omitempty"` // 配置问题（如编辑日志未启用的原因），启动与重载时记入日志
+}
+
+// ClientRoot 客户端通过 roots/list 提供的根目录
+type ClientRoot struct {
+	Name string // 客户端提供的名称，可为空
+	Path string // 本地绝对路径
+}
+
+type namedWorkspace struct {
+	info WorkspaceInfo
+	ws   Workspace
+}
+
+// clientState 一个客户端会话提供的 root 及对应的工作区
+type clientState struct {
+	roots      []ClientRoot // 最近一次 roots/list 的结果，重载时据此重建客户端工作区
+	workspaces []namedWorkspace
+}
+
+// Registry 按名称管理多个工作区，并发安全
+type Registry struct {
+	mu         sync.RWMutex
+	cfg        *config.Config
+	configured []namedWorkspace
+	clients    map[string]*clientState // 按客户端会话 ID 保存，stdio 为 ""
+	implicit   bool                    // 默认工作区未显式指定根目录，可由客户端 root 替代
+}
+
+// NewRegistry 根据配置创建工作区注册表
+func NewRegistry(cfg *config.Config) (*Registry, error) {
+	configured, err := buildConfigured(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		cfg:        cfg,
+		configured: configured,
+		clients:    make(map[string]*clientState),
+		implicit:   len(cfg.Workspaces) == 0 && cfg.RootDir == "",
+	}, nil
+}
+
+// Reload 按新配置重建所有工作区（包括客户端提供的 root）并原子替换；失败时保留原状态
+func (r *Registry) Reload(cfg *config.Config) error {
+	configured, err := buildConfigured(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make(map[string]*clientState, len(r.clients))
+	for id, c := range r.clients {
+		workspaces, _, err := buildClient(cfg, configured, c.roots)
+		if err != nil {
+			return err
+		}
+		clients[id] = &clientState{roots: c.roots, workspaces: workspaces}
+	}
+	r.cfg = cfg
+	r.configured = configured
+	r.clients = clients
+	r.implicit = len(cfg.Workspaces) == 0 && cfg.RootDir == ""
+	return nil
+}
+
+// buildConfigured 根据配置创建 source=config 的工作区
+func buildConfigured(cfg *config.Config) ([]namedWorkspace, error) {
+	if len(cfg.Workspaces) == 0 {
+		ws, err := NewOSWorkspace(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []namedWorkspace{{
+			info: WorkspaceInfo{Name: DefaultWorkspaceName, Root: ws.(*OSWorkspace).root, Source: "config", Warning: ws.(*OSWorkspace).historyWarning()},
+			ws:   ws,
+		}}, nil
+	}
+
+	var result []namedWorkspace
+	for _, wc := range cfg.Workspaces {
+		ws, err := NewOSWorkspace(cfg.ForWorkspace(wc))
+		if err != nil {
+			return nil, fmt.Errorf("workspace %q: %w", wc.Name, err)
+		}
+		result = append(result, namedWorkspace{
+			info: WorkspaceInfo{Name: wc.Name, Root: ws.(*OSWorkspace).root, Source: "config", Warning: ws.(*OSWorkspace).historyWarning()},
+			ws:   ws,
+		})
+	}
+	return result, nil
+}
+
+// Config 返回当前生效的全局配置
+func (r *Registry) Config() *config.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
+
+// Get 按名称返回客户端 client 可见的工作区，name 为空时返回默认工作区
+func (r *Registry) Get(client, name string) (Workspace, error) {
+	ws, _, err := r.Resolve(client, name)
+	return ws, err
+}
+
+// Resolve 同 Get，同时返回工作区的实际名称（name 为空时为默认工作区的名称），
+// 用于记录执行日志与后台任务属于哪个工作区
+func (r *Registry) Resolve(client, name string) (Workspace, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		nw := r.defaultLocked(client)
+		return nw.ws, nw.info.Name, nil
+	}
+	for _, list := range [][]namedWorkspace{r.configured, r.clientLocked(client)} {
+		for _, nw := range list {
+			if nw.info.Name == name {
+				return nw.ws, name, nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("unknown workspace %q", name)
+}
+
+// List 返回客户端 client 可见的所有工作区（配置的在前，该客户端提供的在后）
+func (r *Registry) List(client string) []WorkspaceInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def := r.defaultLocked(client).info.Name
+	var result []WorkspaceInfo
+	for _, list := range [][]namedWorkspace{r.configured, r.clientLocked(client)} {
+		for _, nw := range list {
+			info := nw.info
+			info.Default = info.Name == def
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// SetClientRoots 用客户端 client 提供的根目录替换它的 source=client 工作区，
+// 返回被忽略的 root 及原因（不在任何已配置工作区内且未开启 allow_client_roots）
+func (r *Registry) SetClientRoots(client string, roots []ClientRoot) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workspaces, ignored, err := buildClient(r.cfg, r.configured, roots)
+	if err != nil {
+		return nil, err
+	}
+	r.clients[client] = &clientState{roots: roots, workspaces: workspaces}
+	return ignored, nil
+}
+
+// RemoveClient 删除客户端会话提供的工作区（会话结束时调用）
+func (r *Registry) RemoveClient(client string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, client)
+}
+
+// buildClient 为客户端 root 创建 source=client 的工作区（跳过与已配置工作区相同的根目录），
+// 第二个返回值为被忽略的 root 及原因
+func buildClient(cfg *config.Config, configured []namedWorkspace, roots []ClientRoot) ([]namedWorkspace, []string, error) {
+	used := make(map[string]bool)
+	knownRoots := make(map[string]bool)
+	for _, nw := range configured {
+		used[nw.info.Name] = true
+		knownRoots[nw.info.Root] = true
+	}
+
+	var client []namedWorkspace
+	var ignored []string
+	for _, root := range roots {
+		abs, err := filepath.Abs(root.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid root %q: %w", root.Path, err)
+		}
+		if knownRoots[abs] {
+			continue
+		}
+		var wsCfg *config.Config
+		if parent, real := containingWorkspace(configured, abs); parent != nil {
+			abs = real
+			if knownRoots[abs] {
+				continue
+			}
+			wsCfg = parent.childConfig(abs)
+		} else if cfg.AllowClientRoots {
+			wsCfg = cfg.ForWorkspace(config.WorkspaceConfig{RootDir: abs})
+		} else {
+			ignored = append(ignored, fmt.Sprintf("%s: outside the configured workspaces (set allow_client_roots to accept it)", abs))
+			continue
+		}
+		ws, err := NewOSWorkspace(wsCfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("root %q: %w", root.Path, err)
+		}
+
+		name := uniqueName(root.Name, abs, used)
+		used[name] = true
+		knownRoots[abs] = true
+		client = append(client, namedWorkspace{
+			info: WorkspaceInfo{Name: name, Root: abs, Source: "client", Warning: ws.(*OSWorkspace).historyWarning()},
+			ws:   ws,
+		})
+	}
+	return client, ignored, nil
+}
+
+// containingWorkspace 返回包含 path 的已配置工作区及 path 解析符号链接后的真实路径，没有时返回 nil
+func containingWorkspace(configured []namedWorkspace, path string) (*OSWorkspace, string) {
+	for _, nw := range configured {
+		ow, ok := nw.ws.(*OSWorkspace)
+		if !ok {
+			continue
+		}
+		if real, err := ow.sanitizePath(path); err == nil {
+			return ow, real
+		}
+	}
+	return nil, ""
+}
+
+// clientLocked 返回客户端 client 的工作区，调用方需持有读锁
+func (r *Registry) clientLocked(client string) []namedWorkspace {
+	if c := r.clients[client]; c != nil {
+		return c.workspaces
+	}
+	return nil
+}
+
+// defaultLocked 返回客户端 client 的默认工作区，调用方需持有读锁
+func (r *Registry) defaultLocked(client string) namedWorkspace {
+	if list := r.clientLocked(client); r.implicit && len(list) > 0 {
+		return list[0]
+	}
+	return r.configured[0]
+}
+
+// uniqueName 为客户端 root 生成不重复的名称（优先使用客户端给出的名称，否则取目录名）
+func uniqueName(name, root string, used map[string]bool) string {
+	if name == "" {
+		name = filepath.Base(root)
+	}
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return candidate
+}
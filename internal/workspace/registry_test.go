@@ -0,0 +1,201 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestRegistry_NamedWorkspaces(t *testing.T) {
+	appDir := t.TempDir()
+	libDir := t.TempDir()
+	cfg := &config.Config{
+		MaxFileBytes:      1024,
+		BlockedExtensions: []string{".env"},
+		Workspaces: []config.WorkspaceConfig{
+			{Name: "app", RootDir: appDir},
+			{Name: "lib", RootDir: libDir, BlockedExtensions: []string{".txt"}},
+		},
+	}
+	reg, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(appDir, "a.txt"), []byte("app"), 0644)
+	os.WriteFile(filepath.Join(libDir, "a.txt"), []byte("lib"), 0644)
+	os.WriteFile(filepath.Join(libDir, "b.env"), []byte("lib"), 0644)
+
+	// 默认工作区为第一个
+	ws, err := reg.Get("", "")
+	if err != nil {
+		t.Fatalf("Get default failed: %v", err)
+	}
+	if data, err := ws.ReadFile(context.Background(), "a.txt", 0); err != nil || string(data) != "app" {
+		t.Errorf("default workspace read = %q, %v", data, err)
+	}
+
+	// lib 使用自己的黑名单（覆盖全局 .env）
+	ws, err = reg.Get("", "lib")
+	if err != nil {
+		t.Fatalf("Get lib failed: %v", err)
+	}
+	if _, err := ws.ReadFile(context.Background(), "a.txt", 0); err == nil {
+		t.Error("expected .txt to be blocked in lib workspace")
+	}
+	if _, err := ws.ReadFile(context.Background(), "b.env", 0); err != nil {
+		t.Errorf("expected .env to be readable in lib workspace: %v", err)
+	}
+
+	if _, err := reg.Get("", "missing"); err == nil {
+		t.Error("expected unknown workspace error")
+	}
+
+	list := reg.List("")
+	if len(list) != 2 || list[0].Name != "app" || !list[0].Default || list[1].Default {
+		t.Errorf("unexpected list: %+v", list)
+	}
+}
+
+func TestRegistry_ClientRoots(t *testing.T) {
+	cwdRoot := t.TempDir()
+	clientDir := t.TempDir()
+	cfg := &config.Config{MaxFileBytes: 1024, AllowClientRoots: true}
+	reg, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	// 模拟未设置 RootDir 时的默认工作区
+	reg.configured[0].info.Root = cwdRoot
+
+	os.WriteFile(filepath.Join(clientDir, "x.txt"), []byte("client"), 0644)
+
+	_, err = reg.SetClientRoots("", []ClientRoot{
+		{Path: clientDir},
+		{Path: cwdRoot}, // 与已有工作区相同，应跳过
+		{Name: filepath.Base(clientDir), Path: filepath.Join(clientDir, "sub")},
+	})
+	if err != nil {
+		t.Fatalf("SetClientRoots failed: %v", err)
+	}
+
+	list := reg.List("")
+	if len(list) != 3 {
+		t.Fatalf("expected 3 workspaces, got %+v", list)
+	}
+	if list[1].Source != "client" || !list[1].Default || list[0].Default {
+		t.Errorf("expected first client root to become default: %+v", list)
+	}
+	if list[2].Name != filepath.Base(clientDir)+"-2" {
+		t.Errorf("expected de-duplicated name, got %q", list[2].Name)
+	}
+
+	ws, _ := reg.Get("", "")
+	if data, err := ws.ReadFile(context.Background(), "x.txt", 0); err != nil || string(data) != "client" {
+		t.Errorf("default workspace read = %q, %v", data, err)
+	}
+
+	// 再次刷新会整体替换
+	if _, err := reg.SetClientRoots("", nil); err != nil {
+		t.Fatalf("SetClientRoots(nil) failed: %v", err)
+	}
+	if list := reg.List(""); len(list) != 1 || !list[0].Default {
+		t.Errorf("expected only default workspace, got %+v", list)
+	}
+}
+
+func TestRegistry_ClientRootsInsideWorkspace(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "src", "app"), 0755)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "src", "app", "a.env"), []byte("secret"), 0644)
+	cfg := &config.Config{
+		RootDir:           root,
+		MaxFileBytes:      1024,
+		AllowedPaths:      []string{"src"},
+		BlockedExtensions: []string{".env"},
+	}
+	reg, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+
+	// 默认只接受已配置工作区（含 AllowedPaths）之内的 root
+	ignored, err := reg.SetClientRoots("a", []ClientRoot{
+		{Path: string(filepath.Separator)},
+		{Path: filepath.Join(root, "docs")},
+		{Name: "app", Path: filepath.Join(root, "src", "app")},
+	})
+	if err != nil {
+		t.Fatalf("SetClientRoots failed: %v", err)
+	}
+	if len(ignored) != 2 {
+		t.Errorf("expected / and docs to be ignored, got %q", ignored)
+	}
+	list := reg.List("a")
+	if len(list) != 2 || list[1].Name != "app" || list[1].Source != "client" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	// 客户端工作区继承所在工作区的策略
+	ws, err := reg.Get("a", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.ReadFile(context.Background(), "a.env", 0); err == nil {
+		t.Error("expected .env to stay blocked in client workspace")
+	}
+
+	// 其他客户端看不到 a 的 root；会话结束后 root 被删除
+	if _, err := reg.Get("b", "app"); err == nil {
+		t.Error("client b should not see client a's roots")
+	}
+	if list := reg.List("b"); len(list) != 1 {
+		t.Errorf("client b list = %+v", list)
+	}
+	reg.RemoveClient("a")
+	if _, err := reg.Get("a", "app"); err == nil {
+		t.Error("expected client roots to be removed with the client")
+	}
+
+	// allow_client_roots 显式允许工作区之外的 root
+	cfg.AllowClientRoots = true
+	if ignored, err := reg.SetClientRoots("a", []ClientRoot{{Name: "docs", Path: filepath.Join(root, "docs")}}); err != nil || len(ignored) != 0 {
+		t.Errorf("allow_client_roots: ignored %q, %v", ignored, err)
+	}
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir:              tmpDir,
+		MaxFileBytes:         1024,
+		AllowedBuildCommands: []string{"go"},
+	}
+	reg, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("data"), 0644)
+
+	old, _ := reg.Get("", "")
+
+	newCfg := *cfg
+	newCfg.BlockedExtensions = []string{".txt"}
+	if err := reg.Reload(&newCfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	// 新获取的实例使用新策略，旧实例保持不变
+	ws, _ := reg.Get("", "")
+	if _, err := ws.ReadFile(context.Background(), "a.txt", 0); err == nil {
+		t.Error("expected .txt to be blocked after reload")
+	}
+	if _, err := old.ReadFile(context.Background(), "a.txt", 0); err != nil {
+		t.Errorf("old instance should keep previous policy: %v", err)
+	}
+
+}
@@ -0,0 +1,321 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 本文件实现 search_and_replace：
+//  1. 一次调用可带多组替换（Edits），按顺序作用于同一份内容，后一组看到的是前一组替换后的结果；
+//     每组可以是字面量或正则（RE2 语法，New 中可用 $1、${name} 引用捕获组），可只替换第 N 处匹配。
+//  2. 单文件模式（Path）校验 ifMatch；多文件模式（Glob）按 doublestar glob 选择文件，遍历规则与 grep 相同
+//     （忽略规则、黑名单扩展名、MaxFileBytes、二进制文件），只保留有替换的文件，其数量受 cfg.MaxSearchResults 限制。
+//  3. 每组替换的匹配次数（多文件模式为所有文件的合计）必须大于 0，且与 ExpectedOccurrences（> 0 时）一致；
+//     任一组不满足时不写入任何文件，结果中仍给出各组的匹配次数。
+//  4. DryRun 时返回每处替换的行号与替换前后的行；否则所有修改的文件经 commitEdit 一并提交（全有或全无），记为一条编辑日志。
+
+// ReplaceEdit 一组替换
+type ReplaceEdit struct {
+	Old                 string // 待搜索的文本；Regex 为 true 时为正则
+	New                 string // 替换文本；Regex 为 true 时 $1、${name} 展开为捕获组
+	Regex               bool
+	ExpectedOccurrences int // > 0 时匹配次数必须与之相同（多文件模式为所有文件的合计）
+	Occurrence          int // > 0 时只替换第 N 处匹配（仅单文件模式）
+}
+
+// ReplaceOptions SearchAndReplace 的参数；Path 与 Glob 二选一
+type ReplaceOptions struct {
+	Path    string // 单文件模式
+	Glob    string // 多文件模式：doublestar glob（语法同 find_files）
+	Edits   []ReplaceEdit
+	DryRun  bool   // 只报告匹配与替换预览，不写入
+	IfMatch string // 仅单文件模式，语义同 WriteFile
+}
+
+// ReplaceMatch dry-run 时单处替换的预览
+type ReplaceMatch struct {
+	Edit   int    `json:"edit"`   // 第几组替换（从 1 开始）
+	Line   int    `json:"line"`   // 匹配开始的行号（应用该组替换之前的内容）
+	Before string `json:"before"` // 匹配所在的行（过长时截断）
+	After  string `json:"after"`  // 替换后的行
+}
+
+// FileReplaceResult 单个文件的替换结果
+type FileReplaceResult struct {
+	Path         string         `json:"path"`
+	Replacements int            `json:"replacements"`
+	Hash         string         `json:"hash"` // 替换后（dry-run 时为将会写入的）内容的 sha256
+	Matches      []ReplaceMatch `json:"matches,omitempty"`
+}
+
+// ReplaceResult SearchAndReplace 的结果
+type ReplaceResult struct {
+	DryRun       bool                `json:"dry_run"`
+	Occurrences  []int               `json:"occurrences"`  // 每组替换的匹配次数
+	Replacements int                 `json:"replacements"` // 替换（dry-run 时为将会替换）的总次数
+	Files        []FileReplaceResult `json:"files"`
+	FilesScanned int                 `json:"files_scanned,omitempty"` // 仅多文件模式
+	Truncated    bool                `json:"truncated,omitempty"`     // 预览数量达到上限
+	EditID       int64               `json:"edit_id,omitempty"`
+}
+
+// replaceRule 编译后的一组替换
+type replaceRule struct {
+	ReplaceEdit
+	re *regexp.Regexp
+}
+
+// SearchAndReplace 按 opts 在一个文件（Path）或一组文件（Glob）中执行替换
+func (w *OSWorkspace) SearchAndReplace(ctx context.Context, opts ReplaceOptions) (*ReplaceResult, error) {
+	rules, err := compileReplaceEdits(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplaceResult{DryRun: opts.DryRun, Occurrences: make([]int, len(rules)), Files: []FileReplaceResult{}}
+	previews := w.searchLimit(0)
+	var touched []*fileChange
+	// process 对一个文件依次应用所有替换；多文件模式下没有替换的文件不保留
+	process := func(c *fileChange) {
+		content := string(c.original)
+		file := FileReplaceResult{Path: w.relSlash(c.absPath)}
+		for i, rule := range rules {
+			var found, replaced int
+			var matches []ReplaceMatch
+			content, found, replaced, matches = rule.apply(content, i+1, opts.DryRun)
+			result.Occurrences[i] += found
+			file.Replacements += replaced
+			for _, m := range matches {
+				if previews == 0 {
+					result.Truncated = true
+					break
+				}
+				file.Matches = append(file.Matches, m)
+				previews--
+			}
+		}
+		if opts.Glob != "" && file.Replacements == 0 {
+			return
+		}
+		c.data = []byte(content)
+		file.Hash = contentHash(c.data, true)
+		result.Replacements += file.Replacements
+		result.Files = append(result.Files, file)
+		touched = append(touched, c)
+	}
+
+	switch {
+	case opts.Path != "" && opts.Glob != "":
+		return nil, fmt.Errorf("path and glob are mutually exclusive")
+	case opts.Path != "":
+		c, err := w.replaceTarget(opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.checkIfMatch(w.relSlash(c.absPath), c, opts.IfMatch); err != nil {
+			return nil, err
+		}
+		process(c)
+	case opts.Glob != "":
+		if opts.IfMatch != "" {
+			return nil, fmt.Errorf("ifMatch is only supported with path")
+		}
+		limit := w.searchLimit(0)
+		err := w.walkGlob(ctx, opts.Glob, func(c *fileChange) error {
+			result.FilesScanned++
+			process(c)
+			if len(touched) > limit {
+				return fmt.Errorf("glob %q matches more than %d files to change, narrow the pattern", opts.Glob, limit)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("path or glob is required")
+	}
+
+	// 校验每组替换的匹配次数，不满足时不写入
+	var errs []error
+	for i, rule := range rules {
+		found := result.Occurrences[i]
+		switch {
+		case found == 0:
+			errs = append(errs, fmt.Errorf("edit %d: no matches for %q", i+1, rule.Old))
+		case rule.ExpectedOccurrences > 0 && found != rule.ExpectedOccurrences:
+			errs = append(errs, fmt.Errorf("edit %d: occurrence count mismatch: expected %d, found %d", i+1, rule.ExpectedOccurrences, found))
+		case rule.Occurrence > found:
+			errs = append(errs, fmt.Errorf("edit %d: occurrence %d requested but only %d found", i+1, rule.Occurrence, found))
+		}
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("nothing was written: %w", errors.Join(errs...))
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	// 原子写入并记入编辑日志
+	var commit []*fileChange
+	var paths []string
+	for _, c := range touched {
+		if c.changed() {
+			commit = append(commit, c)
+			paths = append(paths, w.relSlash(c.absPath))
+		}
+	}
+	if len(commit) > 0 {
+		if result.EditID, err = w.commitEdit(EditSearchAndReplace, paths, commit); err != nil {
+			return nil, fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	for _, c := range touched {
+		w.rememberContent(c.data)
+	}
+	return result, nil
+}
+
+// compileReplaceEdits 校验参数并编译每组替换
+func compileReplaceEdits(opts ReplaceOptions) ([]replaceRule, error) {
+	if len(opts.Edits) == 0 {
+		return nil, fmt.Errorf("at least one edit is required")
+	}
+	rules := make([]replaceRule, len(opts.Edits))
+	for i, e := range opts.Edits {
+		if e.Old == "" {
+			return nil, fmt.Errorf("edit %d: search string cannot be empty", i+1)
+		}
+		if e.ExpectedOccurrences < 0 || e.Occurrence < 0 {
+			return nil, fmt.Errorf("edit %d: expectedOccurrences and occurrence cannot be negative", i+1)
+		}
+		if e.Occurrence > 0 && opts.Glob != "" {
+			return nil, fmt.Errorf("edit %d: occurrence is only supported with path", i+1)
+		}
+		re, err := compileGrepPattern(e.Old, !e.Regex, false)
+		if err != nil {
+			return nil, fmt.Errorf("edit %d: %w", i+1, err)
+		}
+		rules[i] = replaceRule{ReplaceEdit: e, re: re}
+	}
+	return rules, nil
+}
+
+// replaceTarget 校验并读取单文件模式的目标文件
+func (w *OSWorkspace) replaceTarget(path string) (*fileChange, error) {
+	absPath, err := w.sanitizePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("path security check failed: %w", err)
+	}
+	if w.isBlockedExtension(absPath) {
+		return nil, fmt.Errorf("file extension is blocked")
+	}
+	c, err := newFileChange(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !c.exists {
+		return nil, fmt.Errorf("failed to read file: %s does not exist", path)
+	}
+	return c, nil
+}
+
+// walkGlob 依次读取工作区内匹配 glob 的文本文件并交给 visit（遍历规则同 Grep）
+func (w *OSWorkspace) walkGlob(ctx context.Context, glob string, visit func(c *fileChange) error) error {
+	glob = filepath.ToSlash(strings.TrimSpace(glob))
+	if err := validateGlob(glob); err != nil {
+		return fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	ignore := w.newIgnoreMatcher()
+	return filepath.WalkDir(w.root, func(path string, d os.DirEntry, walkErr error) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if walkErr != nil || path == w.root {
+			return nil
+		}
+		rel := w.relSlash(path)
+		if ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !matchGlob(glob, rel) {
+			return nil
+		}
+		absPath, err := w.sanitizePath(path)
+		if err != nil || w.isBlockedExtension(absPath) {
+			return nil
+		}
+		if fi, err := d.Info(); err != nil || (w.cfg.MaxFileBytes > 0 && fi.Size() > w.cfg.MaxFileBytes) {
+			return nil
+		}
+		c, err := newFileChange(absPath)
+		if err != nil || !c.exists || bytes.IndexByte(c.original[:min(len(c.original), binarySniffLength)], 0) >= 0 {
+			return nil
+		}
+		return visit(c)
+	})
+}
+
+// apply 对 content 执行替换，返回新内容、匹配次数与替换次数；preview 为 true 时同时返回每处替换的预览
+func (r replaceRule) apply(content string, edit int, preview bool) (string, int, int, []ReplaceMatch) {
+	locs := r.re.FindAllStringSubmatchIndex(content, -1)
+	var out strings.Builder
+	var matches []ReplaceMatch
+	last, replaced := 0, 0
+	line, lineAt := 1, 0 // lineAt 之前的换行数已计入 line
+	for i, m := range locs {
+		if r.Occurrence > 0 && i+1 != r.Occurrence {
+			continue
+		}
+		repl := r.New
+		if r.Regex {
+			repl = string(r.re.ExpandString(nil, r.New, content, m))
+		}
+		out.WriteString(content[last:m[0]])
+		out.WriteString(repl)
+		last = m[1]
+		replaced++
+		if preview {
+			line += strings.Count(content[lineAt:m[0]], "\n")
+			lineAt = m[0]
+			matches = append(matches, replacePreview(content, m[0], m[1], repl, edit, line))
+		}
+	}
+	if replaced == 0 {
+		return content, len(locs), 0, nil
+	}
+	out.WriteString(content[last:])
+	return out.String(), len(locs), replaced, matches
+}
+
+// replacePreview 返回 content[start:end] 替换为 repl 前后，匹配所在的行
+func replacePreview(content string, start, end int, repl string, edit, line int) ReplaceMatch {
+	lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+	// 匹配以换行结尾时，预览到该换行为止
+	from := max(start, end-1)
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[from:], '\n'); i >= 0 {
+		lineEnd = from + i
+	}
+	after := content[lineStart:start] + repl
+	if end <= lineEnd {
+		after += content[end:lineEnd]
+	}
+	return ReplaceMatch{
+		Edit:   edit,
+		Line:   line,
+		Before: truncateLine(content[lineStart:lineEnd]),
+		After:  truncateLine(after),
+	}
+}
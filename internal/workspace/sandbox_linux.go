@@ -0,0 +1,190 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现基于 Linux 命名空间的命令沙箱（sandbox.enabled 时 ExecuteStream 使用），无需 root 权限：
+//  1. 初始化进程（见 execinit_linux.go）以 CLONE_NEWUSER|CLONE_NEWNS（不允许网络时再加 CLONE_NEWNET）启动，
+//     uid/gid 映射为自身，仅为初始化保留 CAP_SYS_ADMIN 等能力。
+//  2. 初始化进程在新的挂载命名空间中：整个文件系统递归设为只读，/tmp 挂载私有 tmpfs，
+//     再把工作区根目录与 writable_paths 重新绑定为可写；独立网络命名空间中启用回环接口。
+//  3. 随后清空 ambient/bounding/inheritable 能力集并设置 no_new_privs，最后 exec 原命令。
+//  4. 任一步失败时命令不会执行，ExecuteStream 返回 ErrSandboxUnavailable。
+//     需要 Linux 5.12+（mount_setattr）且允许非特权用户命名空间。
+
+// ErrSandboxUnavailable 沙箱无法建立（内核不支持或禁用了用户命名空间等），命令未执行
+var ErrSandboxUnavailable = errors.New("sandbox unavailable")
+
+// sandboxSpec 初始化进程建立沙箱所需的描述
+type sandboxSpec struct {
+	Dir           string   `json:"dir"`            // 命令的工作目录
+	WritablePaths []string `json:"writable_paths"` // 可写路径（绝对路径，首个为工作区根目录）
+	Network       bool     `json:"network"`        // 是否共享宿主网络
+}
+
+// newSandboxSpec 生成沙箱描述，并为 execCmd 设置创建命名空间所需的进程属性；execCmd 须已设置 Dir
+func newSandboxSpec(execCmd *exec.Cmd, root string, sb config.SandboxConfig) *sandboxSpec {
+	spec := &sandboxSpec{
+		Dir:           execCmd.Dir,
+		WritablePaths: []string{root},
+		Network:       sb.AllowNetwork,
+	}
+	for _, p := range sb.WritablePaths {
+		spec.WritablePaths = append(spec.WritablePaths, expandSandboxPath(root, p))
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	if execCmd.SysProcAttr == nil {
+		execCmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := execCmd.SysProcAttr
+	attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if !sb.AllowNetwork {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	// 仅供初始化进程挂载、启用回环接口和清空能力集，exec 原命令前全部丢弃
+	attr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN, unix.CAP_SETPCAP}
+	return spec
+}
+
+// sandboxStartError 包装 Start 的错误：创建命名空间失败时给出明确提示
+func sandboxStartError(err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) && (errno == syscall.EPERM || errno == syscall.EINVAL || errno == syscall.ENOSPC || errno == syscall.EACCES) {
+		return fmt.Errorf("%w: cannot create user namespace (check kernel.unprivileged_userns_clone and user.max_user_namespaces): %v", ErrSandboxUnavailable, err)
+	}
+	return err
+}
+
+// expandSandboxPath 展开 ~/ 前缀，相对路径按工作区根目录解析
+func expandSandboxPath(root, p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	return filepath.Clean(p)
+}
+
+// initSandbox 在新的命名空间中建立文件系统视图、网络与能力限制
+func initSandbox(spec sandboxSpec) error {
+	// 1. 挂载事件不传播回宿主
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	// 2. 在 /tmp 被覆盖前打开可写路径（工作区可能位于 /tmp 下），之后从 fd 绑定
+	type writable struct {
+		path string
+		fd   int
+	}
+	var paths []writable
+	for i, p := range spec.WritablePaths {
+		fd, err := unix.Open(p, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			if i > 0 && errors.Is(err, unix.ENOENT) {
+				continue
+			}
+			return fmt.Errorf("open writable path %s: %w", p, err)
+		}
+		paths = append(paths, writable{path: p, fd: fd})
+	}
+
+	// 3. 整个文件系统只读
+	if err := unix.MountSetattr(-1, "/", unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		if errors.Is(err, unix.ENOSYS) {
+			return fmt.Errorf("mount_setattr not supported (Linux 5.12+ required): %w", err)
+		}
+		return fmt.Errorf("remount / read-only: %w", err)
+	}
+
+	// 4. 私有 /tmp
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount private /tmp: %w", err)
+	}
+
+	// 5. 工作区根目录与 writable_paths 重新绑定为可写
+	for _, w := range paths {
+		if err := os.MkdirAll(w.path, 0o755); err != nil {
+			return fmt.Errorf("create mount point %s: %w", w.path, err)
+		}
+		src := fmt.Sprintf("/proc/self/fd/%d", w.fd)
+		if err := unix.Mount(src, w.path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind writable path %s: %w", w.path, err)
+		}
+		if err := unix.MountSetattr(-1, w.path, 0, &unix.MountAttr{Attr_clr: unix.MOUNT_ATTR_RDONLY}); err != nil {
+			return fmt.Errorf("make %s writable: %w", w.path, err)
+		}
+		unix.Close(w.fd)
+	}
+
+	// 6. 独立网络命名空间中只启用回环接口
+	if !spec.Network {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bring up loopback: %w", err)
+		}
+	}
+
+	// 7. 切换到新挂载上的工作目录
+	if err := os.Chdir(spec.Dir); err != nil {
+		return fmt.Errorf("chdir %s: %w", spec.Dir, err)
+	}
+
+	return dropCapabilities()
+}
+
+// loopbackUp 启用 lo 接口
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// dropCapabilities 清空 ambient、bounding 与当前能力集，并禁止 exec 时重新获得权限（如 setuid 或文件能力）
+func dropCapabilities() error {
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clear ambient capabilities: %w", err)
+	}
+	for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("drop bounding capability %d: %w", c, err)
+		}
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("clear capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func newSandboxWorkspace(t *testing.T, sb config.SandboxConfig) Workspace {
+	t.Helper()
+	sb.Enabled = true
+	cfg := &config.Config{
+		RootDir:              t.TempDir(),
+		AllowedBuildCommands: []string{"sh"},
+		BuildTimeout:         10,
+		Sandbox:              sb,
+	}
+	ws, err := NewOSWorkspace(cfg)
+	if err != nil {
+		t.Fatalf("NewOSWorkspace failed: %v", err)
+	}
+	if _, _, _, err := ws.Execute(context.Background(), "sh", []string{"-c", "true"}, 0); errors.Is(err, ErrSandboxUnavailable) {
+		t.Skipf("sandbox not available here: %v", err)
+	}
+	return ws
+}
+
+func TestSandbox_Filesystem(t *testing.T) {
+	ws := newSandboxWorkspace(t, config.SandboxConfig{})
+	outside := t.TempDir()
+
+	script := "echo inside > out.txt && echo tmp > " + filepath.Join(outside, "tmp.txt") + " && ls " + outside + "; touch /usr/agentcode-sandbox-test"
+	stdout, stderr, exitCode, err := ws.Execute(context.Background(), "sh", []string{"-c", script}, 0)
+	if exitCode == 0 || err == nil {
+		t.Fatalf("expected write outside workspace to fail, stdout=%q stderr=%q", stdout, stderr)
+	}
+	if strings.TrimSpace(stdout) != "" {
+		// 私有 /tmp 中看不到宿主的临时目录内容
+		t.Errorf("host /tmp content visible in sandbox: %q", stdout)
+	}
+
+	root := ws.(*OSWorkspace).root
+	if data, err := os.ReadFile(filepath.Join(root, "out.txt")); err != nil || string(data) != "inside\n" {
+		t.Errorf("workspace write not visible on host: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "tmp.txt")); !os.IsNotExist(err) {
+		t.Errorf("write to private /tmp leaked to host: %v", err)
+	}
+	if _, err := os.Stat("/usr/agentcode-sandbox-test"); err == nil {
+		os.Remove("/usr/agentcode-sandbox-test")
+		t.Error("read-only filesystem was writable")
+	}
+}
+
+func TestSandbox_NetworkAndCapabilities(t *testing.T) {
+	ws := newSandboxWorkspace(t, config.SandboxConfig{})
+	stdout, _, _, err := ws.Execute(context.Background(), "sh", []string{"-c", "grep -c : /proc/net/dev; grep CapEff /proc/self/status"}, 0)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || lines[0] != "1" {
+		t.Errorf("expected only loopback interface, got %q", stdout)
+	}
+	if !strings.HasSuffix(lines[len(lines)-1], "0000000000000000") {
+		t.Errorf("expected no effective capabilities, got %q", lines[len(lines)-1])
+	}
+
+	// 按命令允许网络
+	allow := true
+	ws = newSandboxWorkspace(t, config.SandboxConfig{Commands: []config.SandboxRule{{Command: "sh", AllowNetwork: &allow}}})
+	hostDev, _ := os.ReadFile("/proc/net/dev")
+	stdout, _, _, err = ws.Execute(context.Background(), "sh", []string{"-c", "cat /proc/net/dev"}, 0)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.Count(stdout, ":") != strings.Count(string(hostDev), ":") {
+		t.Errorf("expected host network interfaces with allow_network")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 非 Linux 平台：不支持沙箱，资源限制不生效
+
+// ErrSandboxUnavailable 沙箱无法建立，命令未执行（非 Linux 平台始终如此）
+var ErrSandboxUnavailable = errors.New("sandbox unavailable")
+
+type execInit struct{}
+
+func setupExecInit(execCmd *exec.Cmd, root string, limits config.ResourceLimits, sb config.SandboxConfig) (*execInit, error) {
+	if sb.Enabled {
+		return nil, fmt.Errorf("%w: namespaces are not supported on %s", ErrSandboxUnavailable, runtime.GOOS)
+	}
+	return nil, nil
+}
+
+func (p *execInit) startError(err error) error { return err }
+
+func (p *execInit) wait() error { return nil }
+
+func (p *execInit) Close() {}
+
+// MaybeRunExecInit 非 Linux 平台无初始化进程
+func MaybeRunExecInit() {}
@@ -0,0 +1,501 @@
+package workspace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"opencode-go-mcp/internal/config"
+)
+
+// 本文件实现“内容搜索”能力（属于 The Eyes 的延伸）：
+//  1. Grep：在工作区内按正则或字面量搜索文件内容，返回 file:line:column 形式的匹配。
+//  2. 遍历规则与 InspectWorkspace 一致（newIgnoreMatcher），每个文件都经过 sanitizePath 和 isBlockedExtension。
+//  3. FindFiles：按 doublestar glob 查找文件/目录，支持大小、修改时间过滤和分页游标。
+//  4. 结果数量受 cfg.MaxSearchResults 限制，避免撑爆 Agent 上下文。
+
+// GrepOptions 内容搜索参数
+type GrepOptions struct {
+	Pattern      string   // 搜索模式（默认按正则解析）
+	Path         string   // 搜索起点（目录或单个文件，默认 "."）
+	Literal      bool     // true 时把 Pattern 当作字面量
+	IgnoreCase   bool     // 忽略大小写
+	Include      []string // 仅搜索匹配这些 glob 的文件（doublestar 语法，见 matchGlob）
+	Exclude      []string // 跳过匹配这些 glob 的文件或目录
+	ContextLines int      // 匹配行前后附带的上下文行数
+	MaxResults   int      // 最大结果数（<=0 或超过配置上限时使用 cfg.MaxSearchResults）
+}
+
+// GrepMatch 单条匹配结果
+type GrepMatch struct {
+	Path   string   `json:"path"`             // 相对工作区根的路径（使用 / 分隔）
+	Line   int      `json:"line"`             // 行号（1-indexed）
+	Column int      `json:"column"`           // 列号（字节偏移，1-indexed）
+	Text   string   `json:"text"`             // 匹配行内容（过长时截断）
+	Before []string `json:"before,omitempty"` // 匹配行之前的上下文
+	After  []string `json:"after,omitempty"`  // 匹配行之后的上下文
+}
+
+// GrepResult 搜索结果汇总
+type GrepResult struct {
+	Matches      []GrepMatch `json:"matches"`
+	FilesScanned int         `json:"files_scanned"`
+	Truncated    bool        `json:"truncated"` // 是否因达到结果上限而提前停止
+}
+
+const (
+	maxGrepContextLines = 10   // 上下文行数上限
+	maxGrepLineLength   = 500  // 单行输出上限（字符），避免压缩/生成文件占满上下文
+	binarySniffLength   = 8000 // 用于判断二进制文件的前缀长度
+)
+
+// FindOptions 文件查找参数
+type FindOptions struct {
+	Pattern        string    // doublestar glob（不含 / 时按文件名匹配任意层级）
+	Path           string    // 查找起点目录（默认 "."）
+	Type           string    // "file"、"dir" 或空（两者皆可）
+	MinSize        int64     // 最小文件大小（字节，0 表示不限）
+	MaxSize        int64     // 最大文件大小（字节，0 表示不限）
+	ModifiedAfter  time.Time // 仅返回此时间之后修改的条目（零值表示不限）
+	ModifiedBefore time.Time // 仅返回此时间之前修改的条目（零值表示不限）
+	SortBy         string    // "path"（默认）或 "relevance"
+	Cursor         string    // 上一页返回的 NextCursor
+	Limit          int       // 每页条数（<=0 或超过配置上限时使用 cfg.MaxSearchResults）
+}
+
+// FileMatch 单个查找结果
+type FileMatch struct {
+	Path    string    `json:"path"` // 相对工作区根的路径（使用 / 分隔）
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// FindResult 查找结果（一页）
+type FindResult struct {
+	Files      []FileMatch `json:"files"`
+	Total      int         `json:"total"`                 // 全部匹配条目数
+	NextCursor string      `json:"next_cursor,omitempty"` // 为空表示没有下一页
+}
+
+// errGrepLimitReached 内部哨兵错误：达到结果上限时终止遍历
+var errGrepLimitReached = errors.New("grep result limit reached")
+
+// Grep 在工作区内搜索文件内容
+func (w *OSWorkspace) Grep(ctx context.Context, opts GrepOptions) (*GrepResult, error) {
+	if opts.Pattern == "" {
+		return nil, fmt.Errorf("search pattern cannot be empty")
+	}
+	re, err := compileGrepPattern(opts.Pattern, opts.Literal, opts.IgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ContextLines < 0 {
+		opts.ContextLines = 0
+	}
+	if opts.ContextLines > maxGrepContextLines {
+		opts.ContextLines = maxGrepContextLines
+	}
+	limit := w.searchLimit(opts.MaxResults)
+
+	relPath := opts.Path
+	if relPath == "" {
+		relPath = "."
+	}
+	absPath, err := w.sanitizePath(relPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+
+	result := &GrepResult{Matches: []GrepMatch{}}
+
+	// 单文件搜索：直接扫描，不应用 include/exclude
+	if !info.IsDir() {
+		if w.isBlockedExtension(absPath) {
+			return nil, fmt.Errorf("file extension is blocked")
+		}
+		err = w.grepFile(ctx, absPath, re, opts.ContextLines, limit, result)
+		if err != nil && !errors.Is(err, errGrepLimitReached) {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	ignore := w.newIgnoreMatcher()
+	err = filepath.WalkDir(absPath, func(path string, d os.DirEntry, walkErr error) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if walkErr != nil {
+			// 不可访问的目录/文件直接跳过
+			return nil
+		}
+		if path == absPath {
+			return nil
+		}
+
+		rel := w.relSlash(path)
+		if ignore.Match(rel, d.IsDir()) || matchAnyGlob(opts.Exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if len(opts.Include) > 0 && !matchAnyGlob(opts.Include, rel) {
+			return nil
+		}
+
+		// 每个文件都重新走一遍路径沙箱（处理 AllowedPaths 与符号链接）
+		fileAbs, err := w.sanitizePath(path)
+		if err != nil || w.isBlockedExtension(fileAbs) {
+			return nil
+		}
+		if fi, err := d.Info(); err != nil || (w.cfg.MaxFileBytes > 0 && fi.Size() > w.cfg.MaxFileBytes) {
+			return nil
+		}
+
+		return w.grepFile(ctx, fileAbs, re, opts.ContextLines, limit, result)
+	})
+	if err != nil && !errors.Is(err, errGrepLimitReached) {
+		return nil, fmt.Errorf("walk error: %w", err)
+	}
+
+	return result, nil
+}
+
+// grepFile 流式扫描单个文件，将匹配追加到 result；达到 limit 时返回 errGrepLimitReached
+func (w *OSWorkspace) grepFile(ctx context.Context, absPath string, re *regexp.Regexp, contextLines, limit int, result *GrepResult) error {
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	if isBinaryPrefix(reader) {
+		return nil
+	}
+	result.FilesScanned++
+
+	rel := w.relSlash(absPath)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var before []string // 滚动保存最近 contextLines 行
+	var pending []int   // 仍在收集 After 上下文的匹配下标
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		if lineNum%1000 == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+		text := scanner.Text()
+
+		// 先为之前的匹配补齐 After 上下文
+		if len(pending) > 0 {
+			kept := pending[:0]
+			for _, idx := range pending {
+				m := &result.Matches[idx]
+				m.After = append(m.After, truncateLine(text))
+				if len(m.After) < contextLines {
+					kept = append(kept, idx)
+				}
+			}
+			pending = kept
+		}
+
+		if loc := re.FindStringIndex(text); loc != nil {
+			if len(result.Matches) >= limit {
+				result.Truncated = true
+				return errGrepLimitReached
+			}
+			match := GrepMatch{
+				Path:   rel,
+				Line:   lineNum,
+				Column: loc[0] + 1,
+				Text:   truncateLine(text),
+			}
+			if contextLines > 0 && len(before) > 0 {
+				match.Before = append([]string(nil), before...)
+			}
+			result.Matches = append(result.Matches, match)
+			if contextLines > 0 {
+				pending = append(pending, len(result.Matches)-1)
+			}
+		}
+
+		if contextLines > 0 {
+			before = append(before, truncateLine(text))
+			if len(before) > contextLines {
+				before = before[1:]
+			}
+		}
+	}
+
+	// 超长行（bufio.ErrTooLong）等扫描错误：保留已有结果，跳过文件剩余部分
+	return nil
+}
+
+// searchLimit 计算本次搜索的结果上限
+func (w *OSWorkspace) searchLimit(requested int) int {
+	limit := w.cfg.MaxSearchResults
+	if limit <= 0 {
+		limit = config.DefaultMaxSearchResults
+	}
+	if requested > 0 && requested < limit {
+		return requested
+	}
+	return limit
+}
+
+// relSlash 返回相对工作区根的路径（统一使用 / 分隔，便于 glob 匹配和 Agent 阅读）
+func (w *OSWorkspace) relSlash(absPath string) string {
+	rel, err := filepath.Rel(w.root, absPath)
+	if err != nil {
+		return filepath.ToSlash(absPath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// compileGrepPattern 将用户输入编译为正则
+func compileGrepPattern(pattern string, literal, ignoreCase bool) (*regexp.Regexp, error) {
+	if literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return re, nil
+}
+
+// matchAnyGlob 判断相对路径 rel 是否匹配任一 doublestar glob（见 matchGlob）
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		p = filepath.ToSlash(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if matchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinaryPrefix 读取文件开头（不消费数据），包含 NUL 字节时视为二进制
+func isBinaryPrefix(reader *bufio.Reader) bool {
+	// Peek 在文件不足 binarySniffLength 时返回 io.EOF，此时 head 仍包含全部内容
+	head, _ := reader.Peek(binarySniffLength)
+	return bytes.IndexByte(head, 0) >= 0
+}
+
+// truncateLine 截断过长的行
+func truncateLine(s string) string {
+	if len(s) <= maxGrepLineLength {
+		return s
+	}
+	cut := maxGrepLineLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+// FindFiles 按 glob 模式查找文件和目录
+func (w *OSWorkspace) FindFiles(ctx context.Context, opts FindOptions) (*FindResult, error) {
+	pattern := filepath.ToSlash(strings.TrimSpace(opts.Pattern))
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern cannot be empty")
+	}
+	if err := validateGlob(pattern); err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+	}
+	switch opts.Type {
+	case "", "file", "dir":
+	default:
+		return nil, fmt.Errorf("invalid type %q (want file, dir or empty)", opts.Type)
+	}
+	switch opts.SortBy {
+	case "", "path", "relevance":
+	default:
+		return nil, fmt.Errorf("invalid sortBy %q (want path or relevance)", opts.SortBy)
+	}
+	offset, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := w.searchLimit(opts.Limit)
+
+	relPath := opts.Path
+	if relPath == "" {
+		relPath = "."
+	}
+	absPath, err := w.sanitizePath(relPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a directory: %s", relPath)
+	}
+
+	var matches []FileMatch
+	ignore := w.newIgnoreMatcher()
+	err = filepath.WalkDir(absPath, func(path string, d os.DirEntry, walkErr error) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if walkErr != nil || path == absPath {
+			return nil
+		}
+		rel := w.relSlash(path)
+		if ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !matchGlob(pattern, rel) {
+			return nil
+		}
+		if (opts.Type == "file" && d.IsDir()) || (opts.Type == "dir" && !d.IsDir()) {
+			return nil
+		}
+		if _, err := w.sanitizePath(path); err != nil {
+			return nil
+		}
+		if !d.IsDir() && w.isBlockedExtension(path) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		match := FileMatch{Path: rel, IsDir: d.IsDir(), ModTime: fi.ModTime()}
+		if !d.IsDir() {
+			match.Size = fi.Size()
+			if opts.MinSize > 0 && match.Size < opts.MinSize {
+				return nil
+			}
+			if opts.MaxSize > 0 && match.Size > opts.MaxSize {
+				return nil
+			}
+		}
+		if !opts.ModifiedAfter.IsZero() && !match.ModTime.After(opts.ModifiedAfter) {
+			return nil
+		}
+		if !opts.ModifiedBefore.IsZero() && !match.ModTime.Before(opts.ModifiedBefore) {
+			return nil
+		}
+
+		matches = append(matches, match)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk error: %w", err)
+	}
+
+	if opts.SortBy == "relevance" {
+		sortByRelevance(matches, pattern)
+	} else {
+		sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+	}
+
+	result := &FindResult{Files: []FileMatch{}, Total: len(matches)}
+	if offset < len(matches) {
+		end := offset + limit
+		if end > len(matches) {
+			end = len(matches)
+		}
+		result.Files = matches[offset:end]
+		if end < len(matches) {
+			result.NextCursor = encodeCursor(end)
+		}
+	}
+	return result, nil
+}
+
+// sortByRelevance 相关度排序：文件名与模式字面量完全相同者优先，其次是目录层级更浅、路径更短的条目
+func sortByRelevance(matches []FileMatch, pattern string) {
+	lastSeg := pattern[strings.LastIndex(pattern, "/")+1:]
+	exactName := ""
+	if !hasGlobMeta(lastSeg) {
+		exactName = lastSeg
+	}
+	score := func(m FileMatch) int {
+		if exactName != "" && m.Path[strings.LastIndex(m.Path, "/")+1:] == exactName {
+			return 0
+		}
+		return 1
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		si, sj := score(matches[i]), score(matches[j])
+		if si != sj {
+			return si < sj
+		}
+		di, dj := strings.Count(matches[i].Path, "/"), strings.Count(matches[j].Path, "/")
+		if di != dj {
+			return di < dj
+		}
+		if len(matches[i].Path) != len(matches[j].Path) {
+			return len(matches[i].Path) < len(matches[j].Path)
+		}
+		return matches[i].Path < matches[j].Path
+	})
+}
+
+// encodeCursor / decodeCursor 分页游标（对偏移量做 base64 编码，对 Agent 不透明）
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), "offset:") {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
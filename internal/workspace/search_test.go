@@ -0,0 +1,178 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"opencode-go-mcp/internal/config"
+)
+
+func TestOSWorkspace_Grep(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir:           tmpDir,
+		BlockedExtensions: []string{".env"},
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	os.MkdirAll(filepath.Join(tmpDir, "src"), 0755)
+	os.MkdirAll(filepath.Join(tmpDir, "node_modules", "pkg"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "src", "main.go"), []byte("package main\n\nfunc Hello() {}\nfunc hello() {}\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "src", "notes.txt"), []byte("Hello notes\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "node_modules", "pkg", "index.go"), []byte("func Hello() {}\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "secret.env"), []byte("Hello=secret\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "blob.bin"), []byte("Hello\x00\x01"), 0644)
+
+	// 正则 + include 过滤
+	result, err := ws.Grep(context.Background(), GrepOptions{Pattern: `func \w+\(`, Include: []string{"*.go"}})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(result.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d: %+v", len(result.Matches), result.Matches)
+	}
+	first := result.Matches[0]
+	if first.Path != "src/main.go" || first.Line != 3 || first.Column != 1 {
+		t.Errorf("unexpected first match: %+v", first)
+	}
+
+	// 字面量 + 大小写：忽略目录、黑名单扩展名和二进制文件都不应出现
+	result, err = ws.Grep(context.Background(), GrepOptions{Pattern: "Hello", Literal: true})
+	if err != nil {
+		t.Fatalf("Grep literal failed: %v", err)
+	}
+	for _, m := range result.Matches {
+		if strings.HasPrefix(m.Path, "node_modules") || m.Path == "secret.env" || m.Path == "blob.bin" {
+			t.Errorf("unexpected match in ignored/blocked file: %s", m.Path)
+		}
+	}
+	if len(result.Matches) != 2 {
+		t.Errorf("expected 2 case-sensitive matches, got %d", len(result.Matches))
+	}
+
+	result, _ = ws.Grep(context.Background(), GrepOptions{Pattern: "hello", Literal: true, IgnoreCase: true, Exclude: []string{"*.txt"}})
+	if len(result.Matches) != 2 {
+		t.Errorf("expected 2 case-insensitive matches, got %d", len(result.Matches))
+	}
+
+	// 上下文行
+	result, _ = ws.Grep(context.Background(), GrepOptions{Pattern: "func Hello", Path: "src/main.go", ContextLines: 1})
+	if len(result.Matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(result.Matches))
+	}
+	if m := result.Matches[0]; len(m.Before) != 1 || m.Before[0] != "" || len(m.After) != 1 || m.After[0] != "func hello() {}" {
+		t.Errorf("unexpected context: before=%q after=%q", m.Before, m.After)
+	}
+
+	// 非法正则
+	if _, err := ws.Grep(context.Background(), GrepOptions{Pattern: "("}); err == nil {
+		t.Error("expected invalid regex error")
+	}
+
+	// 路径逃逸
+	if _, err := ws.Grep(context.Background(), GrepOptions{Pattern: "x", Path: ".."}); err == nil {
+		t.Error("expected path escape error")
+	}
+}
+
+func TestOSWorkspace_GrepMaxResults(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir:          tmpDir,
+		MaxSearchResults: 3,
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	os.WriteFile(filepath.Join(tmpDir, "many.txt"), []byte(strings.Repeat("match\n", 10)), 0644)
+
+	result, err := ws.Grep(context.Background(), GrepOptions{Pattern: "match"})
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if len(result.Matches) != 3 || !result.Truncated {
+		t.Errorf("expected 3 truncated matches, got %d (truncated=%v)", len(result.Matches), result.Truncated)
+	}
+
+	// 请求值不能超过配置上限
+	result, _ = ws.Grep(context.Background(), GrepOptions{Pattern: "match", MaxResults: 100})
+	if len(result.Matches) != 3 {
+		t.Errorf("expected cap of 3, got %d", len(result.Matches))
+	}
+}
+
+func TestOSWorkspace_FindFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		RootDir:          tmpDir,
+		MaxSearchResults: 2,
+	}
+	ws, _ := NewOSWorkspace(cfg)
+
+	os.MkdirAll(filepath.Join(tmpDir, "internal", "config"), 0755)
+	os.MkdirAll(filepath.Join(tmpDir, "internal", "workspace"), 0755)
+	os.MkdirAll(filepath.Join(tmpDir, "vendor", "x"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "internal", "config", "reloader.go"), []byte("package config"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "internal", "workspace", "eyes_test.go"), []byte("package workspace"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "internal", "workspace", "hands_test.go"), []byte("package workspace // longer"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "main_test.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "vendor", "x", "x_test.go"), []byte("package x"), 0644)
+
+	// 按文件名查找任意层级
+	result, err := ws.FindFiles(context.Background(), FindOptions{Pattern: "reloader.go"})
+	if err != nil {
+		t.Fatalf("FindFiles failed: %v", err)
+	}
+	if result.Total != 1 || result.Files[0].Path != "internal/config/reloader.go" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// ** 模式 + 分页（上限 2）
+	result, err = ws.FindFiles(context.Background(), FindOptions{Pattern: "**/*_test.go"})
+	if err != nil {
+		t.Fatalf("FindFiles failed: %v", err)
+	}
+	if result.Total != 3 || len(result.Files) != 2 || result.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v", result)
+	}
+	if result.Files[0].Path != "internal/workspace/eyes_test.go" {
+		t.Errorf("expected path order, got %s", result.Files[0].Path)
+	}
+	next, err := ws.FindFiles(context.Background(), FindOptions{Pattern: "**/*_test.go", Cursor: result.NextCursor})
+	if err != nil {
+		t.Fatalf("FindFiles page 2 failed: %v", err)
+	}
+	if len(next.Files) != 1 || next.Files[0].Path != "main_test.go" || next.NextCursor != "" {
+		t.Errorf("unexpected second page: %+v", next)
+	}
+
+	// 相关度排序：层级浅的优先
+	result, _ = ws.FindFiles(context.Background(), FindOptions{Pattern: "*_test.go", SortBy: "relevance", Limit: 1})
+	if len(result.Files) != 1 || result.Files[0].Path != "main_test.go" {
+		t.Errorf("unexpected relevance order: %+v", result.Files)
+	}
+
+	// 大小与类型过滤
+	result, _ = ws.FindFiles(context.Background(), FindOptions{Pattern: "*_test.go", MinSize: 20})
+	if result.Total != 1 || result.Files[0].Path != "internal/workspace/hands_test.go" {
+		t.Errorf("unexpected size filter result: %+v", result.Files)
+	}
+	result, _ = ws.FindFiles(context.Background(), FindOptions{Pattern: "internal/*", Type: "dir"})
+	if result.Total != 2 {
+		t.Errorf("expected 2 dirs, got %+v", result.Files)
+	}
+
+	// 修改时间过滤
+	result, _ = ws.FindFiles(context.Background(), FindOptions{Pattern: "*.go", ModifiedAfter: time.Now().Add(time.Hour)})
+	if result.Total != 0 {
+		t.Errorf("expected no files modified in the future, got %d", result.Total)
+	}
+
+	// 非法游标
+	if _, err := ws.FindFiles(context.Background(), FindOptions{Pattern: "*.go", Cursor: "bogus"}); err == nil {
+		t.Error("expected invalid cursor error")
+	}
+}
@@ -17,7 +17,7 @@ import (
 // secureExec 执行命令，封装 os/exec 并应用安全策略
 // 参数 timeoutSeconds <= 0 则使用配置中的 BuildTimeout
 // TODO(shield_secure_exec_impl):
-//  1. 首先使用 w.isAllowedCommand(cmd) 校验命令前缀是否在白名单中。
+//  1. 首先使用 w.checkCommand(w.root, cmd, args) 按命令策略校验（见 policy.go）。
 //  2. 计算真正使用的超时时间：
 //     - timeoutSeconds > 0 时使用该值；
 //     - 否则使用 cfg.BuildTimeoutSeconds。
@@ -27,9 +27,9 @@ import (
 //  5. 对返回的 stdout/stderr 调用 truncateOutputString 进行截断，默认上限可设为 2000 字符。
 //  6. 最终返回截断后的 stdout/stderr 和 exitCode。
 func (w *OSWorkspace) secureExec(ctx context.Context, cmd string, args []string, timeoutSeconds int64) (stdout string, stderr string, exitCode int, err error) {
-	// 命令白名单校验
-	if !w.isAllowedCommand(cmd) {
-		return "", "", 0, fmt.Errorf("command not allowed: %s", cmd)
+	// 命令策略校验（cmd 与 args 一起判定，见 policy.go）
+	if err := w.checkCommand(w.root, cmd, args); err != nil {
+		return "", "", 0, err
 	}
 
 	// 确定超时
@@ -42,17 +42,6 @@ func (w *OSWorkspace) secureExec(ctx context.Context, cmd string, args []string,
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// 构建完整命令字符串（用于白名单校验，args 会附加）
-	fullCmd := cmd
-	if len(args) > 0 {
-		fullCmd = cmd + " " + strings.Join(args, " ")
-	}
-
-	// 再次校验完整命令（用于如 "go build" 之类的复合命令）
-	if !w.isAllowedCommand(fullCmd) {
-		return "", "", 0, fmt.Errorf("command not allowed: %s", fullCmd)
-	}
-
 	// 执行命令
 	stdout, stderr, exitCode, err = w.Execute(ctxWithTimeout, cmd, args, timeoutSeconds)
 	if err != nil {
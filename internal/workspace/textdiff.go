@@ -0,0 +1,211 @@
+package workspace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// 本文件生成两个版本之间的 unified diff（ifMatch 冲突时展示文件自读取后的变化）：
+//  1. 先去掉公共的首尾行，中间部分用 Myers 算法求最短编辑脚本；编辑距离超过 maxDiffEdits 时中间部分整体视为替换，
+//     避免在低内存设备上为差异很大的文件占用过多内存。
+//  2. 变化附近保留 diffContext 行上下文，相距不超过 2*diffContext 行的变化合并为一个 hunk；输出超过 maxLines 行时截断。
+
+const (
+	diffContext  = 3
+	maxDiffEdits = 500
+)
+
+// diffOp 编辑脚本中的一行：' ' 不变，'-' 删除，'+' 添加
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff 返回 oldData 到 newData 的 unified diff；内容相同时返回空字符串
+func unifiedDiff(path string, oldData, newData []byte, maxLines int) string {
+	if bytes.Equal(oldData, newData) {
+		return ""
+	}
+	a, b := splitFileLines(oldData), splitFileLines(newData)
+	ops := diffLines(a.lines, b.lines)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", path, path)
+	// oldNo/newNo[i]：第 i 个操作之前的旧/新行数
+	oldNo, newNo := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+		if op.kind != '+' {
+			oldNo[i+1]++
+		}
+		if op.kind != '-' {
+			newNo[i+1]++
+		}
+	}
+
+	written, hunks := 0, 0
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		// 向后合并相距不超过 2*diffContext 行的变化
+		end := i
+		for j := i; j < len(ops); {
+			if ops[j].kind != ' ' {
+				j++
+				end = j
+				continue
+			}
+			k := j
+			for k < len(ops) && ops[k].kind == ' ' {
+				k++
+			}
+			if k == len(ops) || k-j > 2*diffContext {
+				break
+			}
+			j = k
+		}
+		start, stop := max(0, i-diffContext), min(len(ops), end+diffContext)
+		hunks++
+
+		oldCount, newCount := oldNo[stop]-oldNo[start], newNo[stop]-newNo[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldNo[start], oldCount), hunkRange(newNo[start], newCount))
+		for _, op := range ops[start:stop] {
+			if maxLines > 0 && written >= maxLines {
+				out.WriteString("... (diff truncated)\n")
+				return out.String()
+			}
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+			written++
+		}
+		i = stop
+	}
+	if hunks == 0 || a.noEOL != b.noEOL {
+		out.WriteString("(newline at end of file changed)\n")
+	}
+	return out.String()
+}
+
+// hunkRange 格式化 @@ 行中的 start,count（count 为 0 时 start 为前一行）
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines 返回 a 到 b 的逐行编辑脚本
+func diffLines(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b)-pre-suf)
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, myersDiff(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+// myersDiff Myers O((N+M)D) 算法；trace[d] 保存第 d 步开始时对角线 -d..d 上的最远 x
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceLines(a, b)
+	}
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(a, b, trace, d)
+			}
+		}
+	}
+	return replaceLines(a, b)
+}
+
+// myersBacktrack 从终点沿 trace 回溯出编辑脚本
+func myersBacktrack(a, b []string, trace [][]int, last int) []diffOp {
+	var rev []diffOp
+	x, y := len(a), len(b)
+	for d := last; d > 0; d-- {
+		prev := trace[d] // 第 d 步开始时（即第 d-1 步结束后）的状态，下标为 k+d
+		get := func(k int) int { return prev[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, diffOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			rev = append(rev, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			rev = append(rev, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, diffOp{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+	ops := make([]diffOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
+
+// replaceLines 把 a 整体替换为 b
+func replaceLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a {
+		ops = append(ops, diffOp{'-', l})
+	}
+	for _, l := range b {
+		ops = append(ops, diffOp{'+', l})
+	}
+	return ops
+}
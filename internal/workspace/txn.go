@@ -0,0 +1,263 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 本文件实现多文件原子写入（apply_unified_diff 使用）：
+//  1. 内容在内存中全部准备好后，先为每个文件在同目录写入临时文件并 fsync（staging），
+//     staging 前确认文件自读取后未被修改；任一文件失败时删除全部临时文件，磁盘不变。
+//  2. 随后逐个 rename（删除的文件为 remove）提交；某一步失败时按读取时的原始内容与权限恢复已提交的文件、删除新建的文件。
+//  3. commitTree 在此基础上创建与删除目录（make_dir / move / copy / delete 使用）：提交前确认待删除的目录在提交后为空，
+//     先由浅到深创建目录，再提交文件，最后由深到浅删除目录；文件提交失败时删除刚创建的目录。
+
+// fileChange 一个待写入的文件
+type fileChange struct {
+	absPath  string
+	data     []byte
+	mode     os.FileMode
+	remove   bool        // 提交时删除文件
+	exists   bool        // 读取时文件是否存在
+	original []byte      // 读取时的内容（用于回滚）
+	origMode os.FileMode // 读取时的权限
+	modTime  time.Time   // 读取时的修改时间
+	size     int64
+	tmpPath  string
+}
+
+// newFileChange 读取文件当前状态，作为提交前校验与回滚的依据
+func newFileChange(absPath string) (*fileChange, error) {
+	c := &fileChange{absPath: absPath, mode: 0644}
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", absPath)
+	}
+	if c.original, err = os.ReadFile(absPath); err != nil {
+		return nil, err
+	}
+	c.exists = true
+	c.mode = info.Mode().Perm()
+	c.origMode = c.mode
+	c.modTime = info.ModTime()
+	c.size = info.Size()
+	return c, nil
+}
+
+// commitFiles 原子地写入全部文件：要么全部写入，要么全部保持原样
+func commitFiles(changes []*fileChange) error {
+	// 1. staging
+	for _, c := range changes {
+		err := c.verify()
+		if err == nil && !c.remove {
+			err = c.writeTemp()
+		}
+		if err != nil {
+			removeStaged(changes)
+			return fmt.Errorf("failed to stage %s: %w", c.absPath, err)
+		}
+	}
+
+	// 2. 提交
+	for i, c := range changes {
+		var err error
+		if c.remove {
+			err = os.Remove(c.absPath)
+		} else {
+			err = os.Rename(c.tmpPath, c.absPath)
+		}
+		if err != nil {
+			removeStaged(changes[i:])
+			err = fmt.Errorf("failed to commit %s: %w", c.absPath, err)
+			if rbErr := rollbackFiles(changes[:i]); rbErr != nil {
+				return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return fmt.Errorf("%w (all changes rolled back)", err)
+		}
+		c.tmpPath = ""
+	}
+	return nil
+}
+
+// verify 确认文件自读取后未被修改
+func (c *fileChange) verify() error {
+	info, err := os.Stat(c.absPath)
+	switch {
+	case c.exists && err != nil:
+		return fmt.Errorf("file changed on disk since it was read: %w", err)
+	case c.exists && (!info.ModTime().Equal(c.modTime) || info.Size() != c.size):
+		return errors.New("file changed on disk since it was read")
+	case !c.exists && err == nil:
+		return errors.New("file was created on disk since the patch was checked")
+	}
+	return nil
+}
+
+// writeTemp 把新内容写入同目录的临时文件并 fsync
+func (c *fileChange) writeTemp() error {
+	f, err := os.CreateTemp(filepath.Dir(c.absPath), "."+filepath.Base(c.absPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	c.tmpPath = f.Name()
+	if _, err := f.Write(c.data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(c.mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// removeStaged 删除尚未提交的临时文件
+func removeStaged(changes []*fileChange) {
+	for _, c := range changes {
+		if c.tmpPath != "" {
+			os.Remove(c.tmpPath)
+			c.tmpPath = ""
+		}
+	}
+}
+
+// rollbackFiles 把已提交的文件恢复为读取时的状态
+func rollbackFiles(changes []*fileChange) error {
+	var errs []error
+	for _, c := range changes {
+		if !c.exists {
+			if err := os.Remove(c.absPath); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		restore := &fileChange{absPath: c.absPath, data: c.original, mode: c.origMode}
+		if err := restore.writeTemp(); err != nil {
+			removeStaged([]*fileChange{restore})
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Rename(restore.tmpPath, c.absPath); err != nil {
+			removeStaged([]*fileChange{restore})
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// present 判断文件在提交后是否存在
+func (c *fileChange) present() bool {
+	return !c.remove && (c.exists || c.data != nil)
+}
+
+// changed 判断提交是否会改变文件（内容、权限或存在与否）；新建文件在 data 为 nil 时视为未创建
+func (c *fileChange) changed() bool {
+	if !c.exists {
+		return !c.remove && c.data != nil
+	}
+	return c.remove || c.mode != c.origMode || !bytes.Equal(c.data, c.original)
+}
+
+// dirChange 一个待创建或删除的目录
+type dirChange struct {
+	absPath string
+	path    string // 工作区相对路径（记入编辑日志）
+	create  bool   // true 为创建，false 为删除
+}
+
+// commitTree 创建与删除目录并原子地写入文件；待删除的目录在提交后必须为空，否则不做任何修改
+func commitTree(dirs []dirChange, changes []*fileChange) error {
+	var mkdirs, rmdirs []string
+	for _, d := range dirs {
+		if d.create {
+			mkdirs = append(mkdirs, d.absPath)
+		} else {
+			rmdirs = append(rmdirs, d.absPath)
+		}
+	}
+	// 父目录是子目录的前缀，按字典序排列即由浅到深
+	sort.Strings(mkdirs)
+	sort.Sort(sort.Reverse(sort.StringSlice(rmdirs)))
+	if err := checkEmptied(rmdirs, changes); err != nil {
+		return err
+	}
+
+	var created []string
+	for _, dir := range mkdirs {
+		if info, err := os.Stat(dir); err == nil {
+			if !info.IsDir() {
+				removeDirs(created)
+				return fmt.Errorf("failed to create directory %s: a file with that name exists", dir)
+			}
+			continue
+		}
+		if err := os.Mkdir(dir, 0755); err != nil {
+			removeDirs(created)
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		created = append(created, dir)
+	}
+	if err := commitFiles(changes); err != nil {
+		removeDirs(created)
+		return err
+	}
+	for _, dir := range rmdirs {
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("files were committed but directory %s could not be removed: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// checkEmptied 确认每个待删除的目录只包含同一次提交中删除的文件与目录
+func checkEmptied(rmdirs []string, changes []*fileChange) error {
+	if len(rmdirs) == 0 {
+		return nil
+	}
+	removed := make(map[string]bool)
+	for _, c := range changes {
+		if c.remove {
+			removed[c.absPath] = true
+		}
+	}
+	for _, dir := range rmdirs {
+		removed[dir] = true
+	}
+	for _, dir := range rmdirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !removed[filepath.Join(dir, e.Name())] {
+				return fmt.Errorf("directory %s is not empty: %s would remain", dir, e.Name())
+			}
+		}
+	}
+	return nil
+}
+
+// removeDirs 由深到浅删除刚创建的目录
+func removeDirs(dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
@@ -0,0 +1,69 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitFiles_RollbackOnCommitFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.txt")
+	os.WriteFile(a, []byte("a\n"), 0600)
+	created := filepath.Join(tmpDir, "new.txt")
+	// 非空目录：staging 校验通过，但 rename 时失败
+	blocker := filepath.Join(tmpDir, "dir")
+	os.MkdirAll(filepath.Join(blocker, "child"), 0755)
+
+	ca, err := newFileChange(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.data = []byte("A\n")
+	cn, _ := newFileChange(created)
+	cn.data = []byte("new\n")
+	info, _ := os.Stat(blocker)
+	cb := &fileChange{absPath: blocker, data: []byte("x"), mode: 0644, exists: true, modTime: info.ModTime(), size: info.Size()}
+
+	err = commitFiles([]*fileChange{ca, cn, cb})
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rolled back commit error, got %v", err)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "a\n" {
+		t.Errorf("a.txt not restored: %q", data)
+	}
+	if info, _ := os.Stat(a); info.Mode().Perm() != 0600 {
+		t.Errorf("a.txt mode = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("new.txt should have been removed, stat err = %v", err)
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("leftover temp file %s", e.Name())
+		}
+	}
+}
+
+func TestCommitFiles_ConcurrentModification(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	os.WriteFile(a, []byte("a\n"), 0644)
+	os.WriteFile(b, []byte("b\n"), 0644)
+	ca, _ := newFileChange(a)
+	ca.data = []byte("A\n")
+	cb, _ := newFileChange(b)
+	cb.data = []byte("B\n")
+
+	// 读取后 b.txt 被其他进程修改：整个提交放弃
+	os.WriteFile(b, []byte("changed elsewhere\n"), 0644)
+	if err := commitFiles([]*fileChange{ca, cb}); err == nil || !strings.Contains(err.Error(), "changed on disk") {
+		t.Fatalf("expected concurrent modification error, got %v", err)
+	}
+	if data, _ := os.ReadFile(a); string(data) != "a\n" {
+		t.Errorf("a.txt modified: %q", data)
+	}
+}
@@ -8,27 +8,87 @@ type Workspace interface {
 	// ReadFile 读取文件内容，限制最大字节数，返回 []byte 或 error
 	ReadFile(ctx context.Context, path string, maxBytes int64) ([]byte, error)
 
-	// WriteFile 写入文件，allowCreate 表示是否允许创建新文件
-	WriteFile(ctx context.Context, path string, data []byte, allowCreate bool) error
+	// ReadFileHashed 读取文件内容，同时返回全文的 sha256（写入类方法的 ifMatch）
+	ReadFileHashed(ctx context.Context, path string, maxBytes int64) ([]byte, string, error)
+
+	// WriteFile 写入文件，allowCreate 表示是否允许创建新文件；ifMatch 不为空时文件须未被修改，否则返回 *ConflictError
+	WriteFile(ctx context.Context, path string, data []byte, allowCreate bool, ifMatch string) error
 
 	// Execute 执行命令，返回 stdout、stderr、exit code 和 error
 	Execute(ctx context.Context, cmd string, args []string, timeoutSeconds int64) (stdout string, stderr string, exitCode int, err error)
 
+	// ExecuteStream 执行命令并实时回调输出，完整输出写入可分段读取的日志（见 ReadExecLog）
+	ExecuteStream(ctx context.Context, cmd string, args []string, opts ExecOptions) (*ExecResult, error)
+
 	// InspectWorkspace 扫描工作区目录树
 	InspectWorkspace(ctx context.Context, relPath string, maxDepth int) ([]*TreeNode, error)
 
+	// InspectWorkspaceTree 扫描工作区目录树，返回带目录聚合大小与文件数的嵌套树
+	InspectWorkspaceTree(ctx context.Context, relPath string, maxDepth int) (*TreeNode, error)
+
+	// Grep 按正则或字面量搜索工作区文件内容，结果数量受 MaxSearchResults 限制
+	Grep(ctx context.Context, opts GrepOptions) (*GrepResult, error)
+
+	// FindFiles 按 doublestar glob 查找文件/目录，支持大小与修改时间过滤和分页
+	FindFiles(ctx context.Context, opts FindOptions) (*FindResult, error)
+
 	// ReadCodeFragment 按行范围读取文件
 	ReadCodeFragment(ctx context.Context, path string, startLine, endLine int) (lines []string, truncated bool, err error)
 
+	// ReadCodeFragmentHashed 按行范围读取文件，同时返回全文的 sha256
+	ReadCodeFragmentHashed(ctx context.Context, path string, startLine, endLine int) (lines []string, truncated bool, hash string, err error)
+
 	// ApplyUnifiedDiff 应用补丁
 	ApplyUnifiedDiff(ctx context.Context, diffText string, dryRun bool) (appliedFiles []string, err error)
 
-	// SearchAndReplace 搜索并替换
-	SearchAndReplace(ctx context.Context, path, oldStr, newStr string, expectedOccurrences int) (actualOccurrences int, err error)
+	// ApplyPatch 应用补丁并返回逐 hunk 的结果（可配置 fuzz、偏移搜索与 .rej 输出）
+	ApplyPatch(ctx context.Context, diffText string, opts PatchOptions) (*PatchResult, error)
+
+	// SearchAndReplace 在单个文件或按 glob 选择的文件中按顺序执行一组或多组替换（字面量或正则），全部文件一并写入
+	SearchAndReplace(ctx context.Context, opts ReplaceOptions) (*ReplaceResult, error)
+
+	// ReplaceLines 把 startLine..endLine 替换为 text（text 为空时删除），返回新内容所在的行号
+	ReplaceLines(ctx context.Context, path string, startLine, endLine int, text string, opts LineEditOptions) (*LineEditResult, error)
+
+	// InsertLines 在第 afterLine 行之后插入 text（afterLine 为 0 时插入到开头），返回新内容所在的行号
+	InsertLines(ctx context.Context, path string, afterLine int, text string, opts LineEditOptions) (*LineEditResult, error)
+
+	// MakeDir 创建目录及缺失的父目录
+	MakeDir(ctx context.Context, path string) (*FSResult, error)
+
+	// Move 移动文件或目录到 dst，自动创建父目录；目标文件已存在时需要 overwrite
+	Move(ctx context.Context, src, dst string, overwrite bool) (*FSResult, error)
+
+	// Copy 复制文件或目录到 dst，自动创建父目录；目标文件已存在时需要 overwrite
+	Copy(ctx context.Context, src, dst string, overwrite bool) (*FSResult, error)
+
+	// Delete 删除文件或目录，可先复制到回收站；files.disable_delete 时返回 ErrDeleteDisabled
+	Delete(ctx context.Context, path string, opts DeleteOptions) (*FSResult, error)
+
+	// History 列出编辑日志中最近的编辑（最新的在前），limit <= 0 时返回全部
+	History(ctx context.Context, limit int) (*HistoryReport, error)
+
+	// Undo 撤销编辑：id > 0 时撤销该条目，否则从最新的编辑开始撤销 steps 条
+	Undo(ctx context.Context, steps int, id int64) ([]EditEntry, error)
+
+	// Redo 重做被撤销的编辑：id > 0 时重做该条目，否则从最近撤销的编辑开始重做 steps 条
+	Redo(ctx context.Context, steps int, id int64) ([]EditEntry, error)
 
 	// SecureExec 安全执行命令（带白名单和截断）
 	SecureExec(ctx context.Context, cmd string, args []string, timeoutSeconds int64) (stdout string, stderr string, exitCode int, err error)
 
+	// RunTests 执行 go test -json（命令策略同 SecureExec），返回逐个测试的结构化结果
+	RunTests(ctx context.Context, opts TestOptions) (*TestReport, error)
+
+	// Diagnostics 运行 go build、go vet 及可用的 gofmt、staticcheck（命令策略同 SecureExec），返回结构化诊断
+	Diagnostics(ctx context.Context, opts DiagnosticsOptions) (*DiagnosticsReport, error)
+
+	// ExplainCommand 按命令策略判断命令是否允许执行（不实际执行），拒绝时说明命中的规则
+	ExplainCommand(cmd string, args []string) PolicyDecision
+
+	// IgnoreRules 返回遍历类工具共用的根级忽略规则（内置、.git/info/exclude、.gitignore、.agentignore）
+	IgnoreRules() []IgnoreRule
+
 	// PhysicalFileSize 返回文件的物理磁盘占用大小（不需要上下文）
 	PhysicalFileSize(path string) (int64, error)
 }